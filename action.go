@@ -7,73 +7,72 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
-func handleRename(item TreeItem, screen tcell.Screen) error {
+func handleRename(item TreeItem, rootItemPath string, screen tcell.Screen) ([]FileMove, error) {
 	currentName := filepath.Base(item.Path)
 	prompt := "Enter new name: "
 	newName, ok := getUserInput(prompt, currentName, screen)
 	if !ok || newName == "" || newName == currentName {
-		return nil
+		return nil, nil
 	}
 
 	newPath := filepath.Join(filepath.Dir(item.Path), newName)
 	if _, err := os.Stat(newPath); err == nil {
 		confirmPrompt := "A file or directory with that name already exists. Overwrite? (y/N): "
 		if !getConfirmation(confirmPrompt, screen) {
-			return nil
+			return nil, nil
 		}
 	}
 
-	err := os.Rename(item.Path, newPath)
-	if err != nil {
-		return fmt.Errorf("error renaming directory %s to %s: %v", item.Path, newPath, err)
-	}
-
-	return nil
+	return renameUndoable(item.Path, newPath, rootItemPath)
 }
 
-func handleMove(item TreeItem, rootItemPath string, screen tcell.Screen) error {
+func handleMove(item TreeItem, rootItemPath string, screen tcell.Screen) ([]FileMove, error) {
 	if item.Path == rootItemPath {
-		return userErr{"Cannot move to root directory"}
+		return nil, userErr{"Cannot move to root directory"}
 	}
 
 	currentRelPath, err := filepath.Rel(rootItemPath, item.Path)
 	if err != nil {
-		return fmt.Errorf("error calculating relative path of %s against basepath %s", item.Path, rootItemPath)
+		return nil, fmt.Errorf("error calculating relative path of %s against basepath %s", item.Path, rootItemPath)
 	}
 
-	prompt := "Enter new path: "
-	inputPath, ok := getUserInput(prompt, currentRelPath, screen)
-	if !ok || inputPath == "" || inputPath == currentRelPath {
-		return nil
+	destination, ok := pickDirectory("Move "+currentRelPath+" to", rootItemPath, item.Path, screen)
+	if !ok {
+		return nil, nil
+	}
+	inputPath := filepath.Join(destination, filepath.Base(item.Path))
+	if inputPath == currentRelPath {
+		return nil, nil
 	}
 
 	newPath, err := resolveAndValidatePath(inputPath, rootItemPath)
 	if err != nil {
-		var userErr *userErr
+		var userErr userErr
 		if errors.As(err, &userErr) {
-			return err
+			return nil, err
 		}
-		return fmt.Errorf("error resolving & validating path %s against %s: %v", inputPath, rootItemPath, err)
+		return nil, fmt.Errorf("error resolving & validating path %s against %s: %v", inputPath, rootItemPath, err)
 	}
 
 	itemAbsPath, err := filepath.Abs(item.Path)
 	if err != nil {
-		return fmt.Errorf("error getting absolute path for %s: %v", item.Path, err)
+		return nil, fmt.Errorf("error getting absolute path for %s: %v", item.Path, err)
 	}
 	newAbsPath, err := filepath.Abs(newPath)
 	if err != nil {
-		return fmt.Errorf("error getting absolute path for %s: %v", item.Path, err)
+		return nil, fmt.Errorf("error getting absolute path for %s: %v", item.Path, err)
 	}
 	if strings.HasPrefix(newAbsPath, itemAbsPath+string(os.PathSeparator)) {
-		return userErr{"Cannot move a directory into itself or its subdirectory"}
+		return nil, userErr{"Cannot move a directory into itself or its subdirectory"}
 	}
 
 	if _, err := os.Stat(newPath); err == nil {
 		confirmPrompt := "Destination exists. Overwrite? (y/N): "
 		if !getConfirmation(confirmPrompt, screen) {
-			return nil
+			return nil, nil
 		}
 	}
 
@@ -81,48 +80,43 @@ func handleMove(item TreeItem, rootItemPath string, screen tcell.Screen) error {
 	if _, err := os.Stat(dir); os.IsNotExist(err) {
 		confirmPrompt := "Directory does not exist. Create parent directories and move? (y/N): "
 		if !getConfirmation(confirmPrompt, screen) {
-			return nil
+			return nil, nil
 		}
 		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
-			return fmt.Errorf("error creating parent directory %s: %v", dir, err)
+			return nil, fmt.Errorf("error creating parent directory %s: %v", dir, err)
 		}
 	}
 
-	err = os.Rename(item.Path, newPath)
-	if err != nil {
-		return fmt.Errorf("error moving directory %s to %s: %v", item.Path, newAbsPath, err)
-	}
-
-	return nil
+	return renameUndoable(item.Path, newPath, rootItemPath)
 }
 
-func handleDelete(item TreeItem, rootItemPath string, screen tcell.Screen) error {
+// Move a file or directory to the backups, from where it's removed for good
+// once the retention settings no longer keep it
+func handleDelete(item TreeItem, rootItemPath string, screen tcell.Screen) ([]FileMove, error) {
 	if item.Path == rootItemPath {
-		return userErr{"Cannot delete the root directory"}
+		return nil, userErr{"Cannot delete the root directory"}
 	}
 	prompt := "Are you sure you want to delete " + item.Path + "? (y/N): "
-	if getConfirmation(prompt, screen) {
-		var err error
-		if isDir(item.Path) {
-			err = os.RemoveAll(item.Path)
-		} else {
-			err = os.Remove(item.Path)
-		}
-		if err != nil {
-			return fmt.Errorf("error deleting file: %v", err)
-		}
+	if !getConfirmation(prompt, screen) {
+		return nil, nil
+	}
+	move, err := moveToBackups(rootItemPath, "delete", item.Path)
+	if err != nil {
+		return nil, err
 	}
-	return nil
+	return []FileMove{move}, nil
 }
 
-func handleNew(item TreeItem, rootItemPath string, screen tcell.Screen) error {
+// Create a new file or directory, returning the path of the created file or an
+// empty string when nothing or a directory was created
+func handleNew(item TreeItem, rootItemPath string, screen tcell.Screen) (string, error) {
 	if !isDir(item.Path) {
-		return fmt.Errorf("cannot create new file or directory inside a file: %s", item.Path)
+		return "", fmt.Errorf("cannot create new file or directory inside a file: %s", item.Path)
 	}
 
 	currentRelPath, err := filepath.Rel(rootItemPath, item.Path)
 	if err != nil {
-		return fmt.Errorf("error calculating relative path of %s against basepath %s", item.Path, rootItemPath)
+		return "", fmt.Errorf("error calculating relative path of %s against basepath %s", item.Path, rootItemPath)
 	}
 
 	var defaultInput string
@@ -135,41 +129,53 @@ func handleNew(item TreeItem, rootItemPath string, screen tcell.Screen) error {
 	prompt := "Enter new name: "
 	name, ok := getUserInput(prompt, defaultInput, screen)
 	if !ok || name == "" {
-		return nil
+		return "", nil
 	}
 
 	newPath, err := resolveAndValidatePath(name, rootItemPath)
 	if err != nil {
-		var userErr *userErr
+		var userErr userErr
 		if errors.As(err, &userErr) {
-			return err
+			return "", err
 		}
-		return fmt.Errorf("error resolving & validating path %s against %s: %v", name, rootItemPath, err)
+		return "", fmt.Errorf("error resolving & validating path %s against %s: %v", name, rootItemPath, err)
 	}
+	title := ""
+	if !strings.HasSuffix(name, "/") {
+		newPath, title = nameNewNote(newPath, time.Now())
+	}
+	newPath = expandSeq(newPath)
 
 	if strings.HasSuffix(name, "/") {
 		err := os.MkdirAll(newPath, os.ModePerm)
 		if err != nil {
-			return fmt.Errorf("error creating directory %s: %v", newPath, err)
-		}
-	} else {
-		dirPath := filepath.Dir(newPath)
-		if _, err := os.Stat(dirPath); os.IsNotExist(err) {
-			err = os.MkdirAll(dirPath, os.ModePerm)
-			if err != nil {
-				return fmt.Errorf("error creating directory %s: %v", dirPath, err)
-			}
+			return "", fmt.Errorf("error creating directory %s: %v", newPath, err)
 		}
+		return "", nil
+	}
 
-		file, err := os.Create(newPath)
+	dirPath := filepath.Dir(newPath)
+	if _, err := os.Stat(dirPath); os.IsNotExist(err) {
+		err = os.MkdirAll(dirPath, os.ModePerm)
 		if err != nil {
-			return fmt.Errorf("error creating file %s: %v", newPath, err)
+			return "", fmt.Errorf("error creating directory %s: %v", dirPath, err)
 		}
-		err = file.Close()
-		if err != nil {
-			return fmt.Errorf("error closing file %s: %v", newPath, err)
+	}
+
+	file, err := os.Create(newPath)
+	if err != nil {
+		return "", fmt.Errorf("error creating file %s: %v", newPath, err)
+	}
+	err = file.Close()
+	if err != nil {
+		return "", fmt.Errorf("error closing file %s: %v", newPath, err)
+	}
+	// The title slugged for the name is kept in the note
+	if ext := strings.ToLower(filepath.Ext(newPath)); title != "" && (ext == ".md" || ext == ".markdown") {
+		if err := os.WriteFile(newPath, setFrontmatterField(nil, "title", title), 0o644); err != nil {
+			return "", fmt.Errorf("error writing %s: %v", newPath, err)
 		}
 	}
 
-	return nil
+	return newPath, nil
 }
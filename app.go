@@ -0,0 +1,508 @@
+package main
+
+import (
+	"fmt"
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+type App struct {
+	screen            tcell.Screen
+	dir               string
+	vaultName         string
+	rootItem          TreeItem
+	flatTree          []TreeItem
+	currentSelection  int
+	previewScroll     int
+	previewHidden     bool
+	showMetadata      bool
+	treeScroll        int
+	previewQuery      string
+	layoutMode        LayoutMode
+	treeOffset        int
+	reader            *Reader
+	outline           *Outline
+	search            *Search
+	board             *Board
+	backups           *Backups
+	revisions         *Revisions
+	qrCode            *QRCode
+	sidecarPins       []string
+	title             string
+	notice            string
+	toast             string
+	idle              bool
+	idleTimer         *time.Timer
+	lastInput         time.Time
+	focus             Focus
+	config            Config
+	state             State
+	keymaps           map[Focus]Keymap
+	commands          map[string]Command
+	operations        []string
+	undoStack         []UndoStep
+	redoStack         []UndoStep
+	labelFilter       string
+	tools             map[string]string
+	tagFilter         string
+	fieldFilters      []FieldFilter
+	marked            map[string]bool
+	sortField         string
+	sortDescending    bool
+	index             *Index
+	indexing          bool
+	indexPending      bool
+	indexProgress     *indexProgress
+	indexDone         chan *Index
+	remote            *RemoteVault
+	remoteSyncing     bool
+	remotePending     bool
+	remoteDone        chan remoteEvent
+	tmuxEdits         chan tmuxEditEvent
+	selectionStamp    FileStamp
+	lastMove          time.Time
+	previewDeferred   bool
+	previewTimer      *time.Timer
+	lastFrame         treeFrame
+	wordCount         wordCount
+	vaultStatus       VaultStatus
+	breadcrumbTargets []Breadcrumb
+	vaultLocked       bool
+	lockTimer         *time.Timer
+	quit              bool
+}
+
+const maxOperations = 50
+
+// Moves closer together than this, e.g. while an arrow key is held, only
+// render the preview of notes that were rendered before until they stop
+const previewDelay = 75 * time.Millisecond
+
+type previewEvent struct{}
+
+// Posted by background work once it handed its result to a channel. Results
+// are collected after every event, so a wake-up posted to a screen replaced
+// meanwhile, e.g. by opening vim, only delays them until the next event.
+type wakeEvent struct{}
+
+// Redraw only after events that can change what's on screen, so an untouched
+// app sleeps in PollEvent without waking the CPU
+func (app *App) run() {
+	app.resetIdleTimer()
+	redraw := true
+	for !app.quit {
+		if redraw {
+			app.render()
+		}
+		redraw = true
+		ev := app.screen.PollEvent()
+		app.collectIndex()
+		app.collectRemoteSync()
+		app.collectTmuxEdits()
+		switch ev := ev.(type) {
+		case *tcell.EventInterrupt:
+			switch data := ev.Data().(type) {
+			case previewEvent:
+				if !app.previewDeferred || time.Since(app.lastMove) < previewDelay {
+					redraw = false
+				} else {
+					app.previewDeferred = false
+				}
+			case idleEvent:
+				// A timer that fired just before a key press arrives late
+				if app.idle || !app.idleElapsed() {
+					redraw = false
+				} else {
+					app.idle = true
+				}
+			case lockEvent:
+				if app.vaultLocked || !app.lockElapsed() {
+					redraw = false
+				} else {
+					app.lockVault()
+				}
+			case wakeEvent:
+			case indexEvent:
+				if app.tagFilter != "" && !app.indexing {
+					app.rebuildTree()
+				}
+			case sizeEvent:
+				app.notice = data.warning
+			case updateEvent:
+				app.notice = fmt.Sprintf("notes %s available, run notes self-update", data.release.TagName)
+			}
+			continue
+		case *tcell.EventKey, *tcell.EventResize, *tcell.EventMouse:
+		default:
+			redraw = false
+			continue
+		}
+		app.resetIdleTimer()
+		if _, ok := ev.(*tcell.EventMouse); ok && (app.vaultLocked || app.idle) {
+			continue
+		}
+		if _, ok := ev.(*tcell.EventKey); ok && app.vaultLocked {
+			app.idle = false
+			if !app.unlockVault() {
+				return
+			}
+			app.rebuild()
+			continue
+		}
+		if _, ok := ev.(*tcell.EventKey); ok && app.idle {
+			app.idle = false
+			continue
+		}
+		if _, ok := ev.(*tcell.EventResize); ok {
+			app.screen.Sync()
+		}
+		if isScreenTooSmall(app.screen) {
+			if ev, ok := ev.(*tcell.EventKey); ok {
+				if ev.Key() == tcell.KeyEscape || ev.Key() == tcell.KeyCtrlC || ev.Rune() == 'q' || ev.Rune() == 'Q' {
+					return
+				}
+			}
+			continue
+		}
+		// Toasts show the result of an action until the next input
+		app.toast = ""
+		switch ev := ev.(type) {
+		case *tcell.EventKey:
+			app.handleKey(ev)
+		case *tcell.EventMouse:
+			app.handleMouse(ev)
+		}
+	}
+}
+
+func (app *App) render() {
+	app.updateTitle()
+	if isScreenTooSmall(app.screen) {
+		renderTooSmall(app.screen)
+		return
+	}
+	if app.focus == FocusReader && app.reader != nil {
+		renderReader(app)
+		if app.idle {
+			obscureArea(app.readerArea(), app.config.IdleAction, app.screen)
+			app.screen.Show()
+		}
+		return
+	}
+	if app.focus == FocusSearch && app.search != nil {
+		renderSearch(app)
+		return
+	}
+	if app.focus == FocusBoard && app.board != nil {
+		renderBoard(app)
+		return
+	}
+	if app.focus == FocusBackups && app.backups != nil {
+		renderBackups(app)
+		return
+	}
+	if app.focus == FocusRevisions && app.revisions != nil {
+		renderRevisions(app)
+		return
+	}
+	renderTree(app)
+	if app.vaultLocked {
+		renderLocked(app.layout().Preview, app.screen)
+		app.screen.Show()
+	} else if app.idle {
+		obscureArea(app.layout().Preview, app.config.IdleAction, app.screen)
+		app.screen.Show()
+	}
+}
+
+// Dispatch a key press to the keymap of the currently focused pane
+func (app *App) handleKey(ev *tcell.EventKey) {
+	action, ok := app.keymaps[app.focus][keyOf(ev)]
+	if !ok {
+		return
+	}
+	if app.config.ReadOnly && writingActions[action.Name] {
+		handleError(errReadOnly, app.screen)
+		return
+	}
+	if err := action.Run(app); err != nil {
+		handleError(err, app.screen)
+	}
+	// Actions may have replaced the screen the idle timer posts to
+	app.resetIdleTimer()
+}
+
+// Remember a file operation for bug reports, keeping only the most recent ones
+func (app *App) recordOperation(name string, path string) {
+	op := time.Now().Format(time.RFC3339) + " " + name + " " + path
+	logger.Print(op)
+	app.operations = append(app.operations, op)
+	if len(app.operations) > maxOperations {
+		app.operations = app.operations[len(app.operations)-maxOperations:]
+	}
+}
+
+func (app *App) selectedItem() TreeItem {
+	return app.flatTree[app.currentSelection]
+}
+
+func (app *App) setFocus(focus Focus) {
+	app.focus = focus
+}
+
+func (app *App) moveSelection(delta int) {
+	selection := app.currentSelection + delta
+	if selection < 0 {
+		selection = 0
+	}
+	if selection > len(app.flatTree)-1 {
+		selection = len(app.flatTree) - 1
+	}
+	if selection != app.currentSelection {
+		app.currentSelection = selection
+		app.previewScroll = 0
+		app.previewQuery = ""
+		app.qrCode = nil
+		app.stampSelection()
+		app.deferPreview()
+	}
+}
+
+// Defer rendering the preview when the selection moves again soon after the
+// last move, posting an interrupt once moves stop so it's rendered then
+func (app *App) deferPreview() {
+	now := time.Now()
+	app.previewDeferred = now.Sub(app.lastMove) < previewDelay
+	app.lastMove = now
+	if !app.previewDeferred {
+		return
+	}
+	if app.previewTimer != nil {
+		app.previewTimer.Stop()
+	}
+	screen := app.screen
+	app.previewTimer = time.AfterFunc(previewDelay, func() {
+		_ = screen.PostEvent(tcell.NewEventInterrupt(previewEvent{}))
+	})
+}
+
+func (app *App) searchPreview() error {
+	query, ok := getUserInput("/", app.previewQuery, app.screen)
+	if !ok {
+		return nil
+	}
+	app.previewQuery = query
+	return app.jumpToPreviewMatch(app.previewScroll, 1)
+}
+
+func (app *App) jumpToPreviewMatch(from int, direction int) error {
+	if app.previewQuery == "" {
+		return nil
+	}
+	path := app.selectedItem().Path
+	if !isFile(path) {
+		return nil
+	}
+	content, err := renderNote(path, app.layout().Preview.Width)
+	if err != nil {
+		return fmt.Errorf("error rendering %s: %v", path, err)
+	}
+	line := findMatch(strings.Split(string(content), "\n"), app.previewQuery, from, direction)
+	if line == -1 {
+		return userErr{fmt.Sprintf("Pattern not found: %s", app.previewQuery)}
+	}
+	app.previewScroll = line
+	return nil
+}
+
+func (app *App) scrollPreview(delta int) {
+	app.previewScroll += delta
+	if app.previewScroll < 0 {
+		app.previewScroll = 0
+	}
+}
+
+// Suspend the screen to edit path in vim and pick up any changes afterwards
+func (app *App) openEditor(path string, args ...string) error {
+	// Keep the version being replaced, the current one is shown with them
+	app.snapshotNote(path)
+	stamp := stampFile(path)
+	defer func() {
+		if stamp.changed() {
+			if err := app.formatNote(path); err != nil {
+				handleError(err, app.screen)
+			}
+			app.fireHook(hookNoteEdited, path, "")
+		}
+	}()
+	if isEncryptedFile(path) {
+		return app.editEncrypted(path, args...)
+	}
+	if command, ok := app.editorFor(path); ok {
+		if command != editorBuiltin && app.useTmuxEditor() {
+			return app.openTmuxEditor(editorCommand(command, path, args), path, stamp)
+		}
+		return app.runEditorCommand(command, path, args...)
+	}
+	if !app.hasTool("vim") {
+		return app.editBuiltin(path, args...)
+	}
+	if app.useTmuxEditor() {
+		return app.openTmuxEditor(exec.Command("vim", append(args, path)...), path, stamp)
+	}
+	screen, err := openVim(path, app.screen, args...)
+	if screen != nil {
+		app.screen = screen
+	}
+	if err != nil {
+		resetScreen(app.screen)
+		exitWithError(err)
+	}
+	app.updateTitle()
+	app.rebuild()
+	return nil
+}
+
+func (app *App) relativePath(path string) string {
+	rel, err := filepath.Rel(app.rootItem.Path, path)
+	if err != nil {
+		return path
+	}
+	return rel
+}
+
+// Select the entry of path, in the tree rather than among the pinned entries
+// unless it's filtered out of the tree
+func (app *App) selectPath(path string) {
+	i := slices.IndexFunc(app.flatTree, func(item TreeItem) bool {
+		return item.Path == path && !item.Pinned
+	})
+	if i == -1 {
+		i = slices.IndexFunc(app.flatTree, func(item TreeItem) bool {
+			return item.Path == path
+		})
+	}
+	if i != -1 {
+		app.currentSelection = i
+		app.previewScroll = 0
+		app.stampSelection()
+	}
+}
+
+// Scroll the tree sideways by delta columns, up to where the widest entry ends
+// at the edge of the pane
+func (app *App) scrollTreeHorizontally(delta int) {
+	widest := 0
+	for _, item := range app.flatTree {
+		widest = max(widest, runewidth.StringWidth(formatTreeItem(item)))
+	}
+	app.treeScroll = max(min(app.treeScroll+delta, widest-app.layout().Tree.Width+1), 0)
+}
+
+// Keep the selected item within the visible rows of the tree pane
+func (app *App) scrollTreeToSelection(rows int) {
+	if app.currentSelection < app.treeOffset {
+		app.treeOffset = app.currentSelection
+	}
+	if app.currentSelection >= app.treeOffset+rows {
+		app.treeOffset = app.currentSelection - rows + 1
+	}
+	app.treeOffset = max(min(app.treeOffset, len(app.flatTree)-rows), 0)
+}
+
+// Share of the screen used by the tree pane in the active layout
+func (app *App) splitRatio() float64 {
+	if app.layoutMode == LayoutHorizontal {
+		if app.state.HorizontalSplitRatio != 0 {
+			return app.state.HorizontalSplitRatio
+		}
+		return app.config.HorizontalSplitRatio
+	}
+	if app.state.SplitRatio != 0 {
+		return app.state.SplitRatio
+	}
+	return app.config.SplitRatio
+}
+
+func (app *App) adjustSplitRatio(delta float64) error {
+	ratio := app.splitRatio() + delta
+	ratio = min(max(ratio, minSplitRatio), maxSplitRatio)
+	if app.layoutMode == LayoutHorizontal {
+		app.state.HorizontalSplitRatio = ratio
+	} else {
+		app.state.SplitRatio = ratio
+	}
+	return saveState(stateFilePath(), app.state)
+}
+
+// Show the selected note in the terminal window title, writing only when it
+// changed since the last update
+func (app *App) updateTitle() {
+	if !app.config.TerminalTitle {
+		return
+	}
+	title := "notes: " + app.vaultTitle()
+	if len(app.flatTree) > 0 && app.selectedItem().Path != app.rootItem.Path {
+		if rel, err := filepath.Rel(app.rootItem.Path, app.selectedItem().Path); err == nil {
+			title = "notes: " + rel
+		}
+	}
+	if title != app.title || !terminalTitleSaved {
+		setTerminalTitle(title)
+		app.title = title
+	}
+}
+
+func (app *App) vaultTitle() string {
+	if app.vaultName != "" {
+		return app.vaultName
+	}
+	if app.config.Title != "" {
+		return app.config.Title
+	}
+	return filepath.Base(app.dir)
+}
+
+// Reload the tree after files changed and bring the index up to date
+func (app *App) rebuild() {
+	if err := app.sealLockedDir(); err != nil {
+		handleError(err, app.screen)
+	}
+	app.vaultStatus = VaultStatus{}
+	app.pruneMarks()
+	app.rebuildTree()
+	app.restampSelection()
+	app.refreshIndex()
+	app.syncRemote()
+}
+
+func (app *App) rebuildTree() {
+	app.rootItem = buildTree(app.dir, app.config.TreeSort)
+	app.rootItem.Display = app.vaultTitle()
+	app.sidecarPins = sidecarPins(app.rootItem)
+	if app.labelFilter != "" {
+		app.rootItem, _ = filterTree(app.rootItem, func(item TreeItem) bool {
+			return item.Label == app.labelFilter
+		})
+	}
+	if app.tagFilter != "" && app.index != nil {
+		app.rootItem, _ = filterTree(app.rootItem, func(item TreeItem) bool {
+			return app.index.hasTag(item.Path, app.tagFilter)
+		})
+	}
+	for _, filter := range app.fieldFilters {
+		app.rootItem, _ = filterTree(app.rootItem, filter.matches)
+	}
+	if app.sortField != "" {
+		app.rootItem = sortTreeByField(app.rootItem, app.sortField, app.sortDescending)
+	}
+	app.flatTree = append(app.pinnedItems(), flattenTree(app.rootItem, []bool{})...)
+	if app.currentSelection >= len(app.flatTree) {
+		app.currentSelection = len(app.flatTree) - 1
+	}
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ArchiveFile is a regular file read from an archive, with its slash separated
+// name relative to the archive root
+type ArchiveFile struct {
+	Name    string
+	Mode    fs.FileMode
+	Content []byte
+}
+
+func isZipArchive(archivePath string) bool {
+	return strings.EqualFold(filepath.Ext(archivePath), ".zip")
+}
+
+func isTarGzArchive(archivePath string) bool {
+	lower := strings.ToLower(archivePath)
+	return strings.HasSuffix(lower, ".tar.gz") || strings.HasSuffix(lower, ".tgz")
+}
+
+// The directory an action on the selected item works in: the item itself when
+// it's a directory, otherwise the directory holding it
+func (app *App) selectedDir() string {
+	item := app.selectedItem()
+	if item.IsDir {
+		return item.Path
+	}
+	return filepath.Dir(item.Path)
+}
+
+// Write dir to a zip or tar.gz archive with the directory's name as the top
+// level entry. Hidden files and encrypted notes are left out, like in search
+// and the index.
+func writeArchive(archivePath string, dir string) (int, error) {
+	absArchive, err := filepath.Abs(archivePath)
+	if err != nil {
+		return 0, fmt.Errorf("error resolving %s: %v", archivePath, err)
+	}
+	out, err := os.Create(archivePath)
+	if err != nil {
+		return 0, fmt.Errorf("error creating %s: %v", archivePath, err)
+	}
+	defer out.Close()
+
+	var zw *zip.Writer
+	var gz *gzip.Writer
+	var tw *tar.Writer
+	if isZipArchive(archivePath) {
+		zw = zip.NewWriter(out)
+	} else {
+		gz = gzip.NewWriter(out)
+		tw = tar.NewWriter(gz)
+	}
+
+	count := 0
+	err = walkNotes(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if abs, _ := filepath.Abs(p); abs == absArchive {
+			return nil
+		}
+		rel, err := filepath.Rel(filepath.Dir(dir), p)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		if zw != nil {
+			header, err := zip.FileInfoHeader(info)
+			if err != nil {
+				return err
+			}
+			header.Name = name
+			header.Method = zip.Deflate
+			w, err := zw.CreateHeader(header)
+			if err != nil {
+				return err
+			}
+			_, err = w.Write(content)
+			count++
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = name
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		_, err = tw.Write(content)
+		count++
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("error archiving %s: %v", dir, err)
+	}
+	if zw != nil {
+		err = zw.Close()
+	} else if err = tw.Close(); err == nil {
+		err = gz.Close()
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error writing %s: %v", archivePath, err)
+	}
+	return count, nil
+}
+
+// Read the regular files of a zip or tar.gz archive, rejecting names that
+// would escape the directory the archive is unpacked to
+func readArchive(archivePath string) ([]ArchiveFile, error) {
+	content, err := os.ReadFile(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", archivePath, err)
+	}
+	var files []ArchiveFile
+	add := func(name string, mode fs.FileMode, r io.Reader) error {
+		clean := path.Clean(strings.ReplaceAll(name, "\\", "/"))
+		if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+			return fmt.Errorf("error: unsafe path in archive: %s", name)
+		}
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return fmt.Errorf("error reading archive: %v", err)
+		}
+		files = append(files, ArchiveFile{clean, mode.Perm(), data})
+		return nil
+	}
+
+	if isZipArchive(archivePath) {
+		zr, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+		if err != nil {
+			return nil, fmt.Errorf("error reading archive: %v", err)
+		}
+		for _, f := range zr.File {
+			if !f.Mode().IsRegular() {
+				continue
+			}
+			r, err := f.Open()
+			if err != nil {
+				return nil, fmt.Errorf("error reading archive: %v", err)
+			}
+			err = add(f.Name, f.Mode(), r)
+			r.Close()
+			if err != nil {
+				return nil, err
+			}
+		}
+		return files, nil
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("error reading archive: %v", err)
+	}
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading archive: %v", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := add(header.Name, header.FileInfo().Mode(), tr); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func runExportArchive(app *App, args []string) error {
+	if len(args) != 1 || (!isZipArchive(args[0]) && !isTarGzArchive(args[0])) {
+		return userErr{"Usage: export-archive <file.zip|file.tar.gz>"}
+	}
+	if app.config.ReadOnly {
+		return errReadOnly
+	}
+	archivePath := expandHome(args[0])
+	dir := app.selectedDir()
+	if isFile(archivePath) && !getConfirmation(fmt.Sprintf("%s exists. Overwrite? (y/N): ", archivePath), app.screen) {
+		return nil
+	}
+	app.recordOperation("export-archive", dir+" "+archivePath)
+	count, err := writeArchive(archivePath, dir)
+	if err != nil {
+		return err
+	}
+	app.rebuild()
+	renderMessage(fmt.Sprintf("Exported %d files to %s", count, archivePath), app.screen)
+	return nil
+}
+
+// Unpack an archive into the selected directory. When files already exist the
+// user picks once whether to overwrite, rename or skip them.
+func runImportArchive(app *App, args []string) error {
+	if len(args) != 1 || (!isZipArchive(args[0]) && !isTarGzArchive(args[0])) {
+		return userErr{"Usage: import-archive <file.zip|file.tar.gz>"}
+	}
+	archivePath := expandHome(args[0])
+	files, err := readArchive(archivePath)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return userErr{"The archive has no files"}
+	}
+	dir := app.selectedDir()
+
+	existing := 0
+	for _, f := range files {
+		if _, err := os.Lstat(filepath.Join(dir, filepath.FromSlash(f.Name))); err == nil {
+			existing++
+		}
+	}
+	choice := 'o'
+	if existing > 0 {
+		prompt := fmt.Sprintf("%d of %d files exist: (o)verwrite, (r)ename, (s)kip, (c)ancel: ", existing, len(files))
+		choice = getChoice(prompt, "orsc", app.screen)
+		if choice == 0 || choice == 'c' {
+			return nil
+		}
+	}
+
+	app.recordOperation("import-archive", archivePath+" "+dir)
+	if choice == 'o' && existing > 0 {
+		var paths []string
+		for _, f := range files {
+			paths = append(paths, filepath.Join(dir, filepath.FromSlash(f.Name)))
+		}
+		if err := app.backup("import-archive", paths...); err != nil {
+			return err
+		}
+	}
+	defer app.rebuild()
+	imported := 0
+	for _, f := range files {
+		target := filepath.Join(dir, filepath.FromSlash(f.Name))
+		if _, err := os.Lstat(target); err == nil {
+			switch choice {
+			case 's':
+				continue
+			case 'r':
+				target = uniquePath(target)
+			}
+		}
+		if err := os.MkdirAll(filepath.Dir(target), os.ModePerm); err != nil {
+			return fmt.Errorf("error creating directory %s: %v", filepath.Dir(target), err)
+		}
+		mode := f.Mode
+		if mode == 0 {
+			mode = 0o644
+		}
+		if err := os.WriteFile(target, f.Content, mode); err != nil {
+			return fmt.Errorf("error writing %s: %v", target, err)
+		}
+		imported++
+	}
+	renderMessage(fmt.Sprintf("Imported %d files to %s", imported, dir), app.screen)
+	return nil
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// Copies of files are kept here before operations change them, and
+	// deleted and overwritten files are moved here, in a directory per
+	// operation
+	backupsDirName     = ".backups"
+	backupManifestName = ".backup.json"
+	backupTimeFormat   = "20060102-150405"
+)
+
+// BackupSet is the copies taken before one operation, with vault relative
+// slash separated file names
+type BackupSet struct {
+	Dir       string    `json:"-"`
+	Time      time.Time `json:"time"`
+	Operation string    `json:"operation"`
+	Files     []string  `json:"files"`
+}
+
+// Backups lists the backup sets, newest first, in place of the tree
+type Backups struct {
+	sets      []BackupSet
+	selection int
+}
+
+// Copy the files at paths, and the files in directories among them, into a
+// new backup set. Paths that don't exist or aren't in the vault are skipped.
+func backupFiles(rootItemPath string, operation string, paths ...string) error {
+	now := time.Now()
+	set := BackupSet{Dir: uniquePath(filepath.Join(rootItemPath, backupsDirName, now.Format(backupTimeFormat))), Time: now, Operation: operation}
+	for _, path := range paths {
+		rel, err := filepath.Rel(rootItemPath, path)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") || isInBackups(path, rootItemPath) {
+			continue
+		}
+		err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if d.IsDir() && isInBackups(p, rootItemPath) {
+				return filepath.SkipDir
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			rel, err := filepath.Rel(rootItemPath, p)
+			if err != nil {
+				return err
+			}
+			if err := copyFile(p, filepath.Join(set.Dir, rel)); err != nil {
+				return err
+			}
+			set.Files = append(set.Files, filepath.ToSlash(rel))
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("error backing up %s: %v", path, err)
+		}
+	}
+	if len(set.Files) == 0 {
+		return nil
+	}
+	return writeBackupManifest(set)
+}
+
+func writeBackupManifest(set BackupSet) error {
+	manifest, err := json.MarshalIndent(set, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding backup manifest: %v", err)
+	}
+	manifestPath := filepath.Join(set.Dir, backupManifestName)
+	if err := os.WriteFile(manifestPath, manifest, 0o644); err != nil {
+		return fmt.Errorf("error writing %s: %v", manifestPath, err)
+	}
+	return nil
+}
+
+// Move a file or directory into a new backup set instead of deleting it,
+// keeping its path relative to the vault. Undoing renames it back, and after
+// a restart it's restored from the backups.
+func moveToBackups(rootItemPath string, operation string, path string) (FileMove, error) {
+	rel, err := filepath.Rel(rootItemPath, path)
+	if err != nil {
+		return FileMove{}, fmt.Errorf("error calculating relative path of %s against basepath %s", path, rootItemPath)
+	}
+	dir := uniquePath(filepath.Join(rootItemPath, backupsDirName, time.Now().Format(backupTimeFormat)))
+	backupPath := filepath.Join(dir, rel)
+	if err := os.MkdirAll(filepath.Dir(backupPath), os.ModePerm); err != nil {
+		return FileMove{}, fmt.Errorf("error creating directory %s: %v", filepath.Dir(backupPath), err)
+	}
+	if err := os.Rename(path, backupPath); err != nil {
+		return FileMove{}, fmt.Errorf("error moving %s to the backups: %v", path, err)
+	}
+	if err := updateBackupSet(dir, operation); err != nil {
+		logger.Printf("%v", err)
+	}
+	return FileMove{path, backupPath}, nil
+}
+
+// List the files of a backup set in its manifest after files were moved in
+// or out of it, removing the set once it's empty. The time and operation of
+// an existing manifest are kept.
+func updateBackupSet(dir string, operation string) error {
+	set := BackupSet{Time: time.Now(), Operation: operation}
+	if content, err := os.ReadFile(filepath.Join(dir, backupManifestName)); err == nil {
+		_ = json.Unmarshal(content, &set)
+	}
+	set.Dir, set.Files = dir, nil
+	if !isDir(dir) {
+		return nil
+	}
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() || p == filepath.Join(dir, backupManifestName) {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		set.Files = append(set.Files, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error reading backup %s: %v", dir, err)
+	}
+	if len(set.Files) == 0 {
+		if err := os.RemoveAll(dir); err != nil {
+			return fmt.Errorf("error removing backup %s: %v", dir, err)
+		}
+		return nil
+	}
+	return writeBackupManifest(set)
+}
+
+// Update the backup sets files were moved in or out of by an operation, its
+// undo or redo, and drop the sets the retention settings no longer keep
+func (app *App) updateBackupSets(moves []FileMove, operation string) {
+	root := app.rootItem.Path
+	backups := filepath.Join(root, backupsDirName)
+	updated := make(map[string]bool)
+	for _, move := range moves {
+		for _, path := range []string{move.From, move.To} {
+			rel, err := filepath.Rel(backups, path)
+			if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+				continue
+			}
+			dir := filepath.Join(backups, strings.Split(filepath.ToSlash(rel), "/")[0])
+			if updated[dir] {
+				continue
+			}
+			updated[dir] = true
+			if err := updateBackupSet(dir, operation); err != nil {
+				logger.Printf("%v", err)
+			}
+		}
+	}
+	if err := pruneBackups(root, app.config.BackupRetentionDays, app.config.MaxBackups); err != nil {
+		logger.Printf("%v", err)
+	}
+}
+
+func isInBackups(path string, rootItemPath string) bool {
+	backups := filepath.Join(rootItemPath, backupsDirName)
+	return path == backups || strings.HasPrefix(path, backups+string(os.PathSeparator))
+}
+
+// Back up files before an operation changes them and drop backups the
+// retention settings no longer keep
+func (app *App) backup(operation string, paths ...string) error {
+	if err := backupFiles(app.rootItem.Path, operation, paths...); err != nil {
+		return err
+	}
+	return pruneBackups(app.rootItem.Path, app.config.BackupRetentionDays, app.config.MaxBackups)
+}
+
+// Backup sets of the vault, newest first
+func readBackupSets(rootItemPath string) ([]BackupSet, error) {
+	entries, err := os.ReadDir(filepath.Join(rootItemPath, backupsDirName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading backups: %v", err)
+	}
+	var sets []BackupSet
+	for _, entry := range entries {
+		dir := filepath.Join(rootItemPath, backupsDirName, entry.Name())
+		content, err := os.ReadFile(filepath.Join(dir, backupManifestName))
+		if !entry.IsDir() || err != nil {
+			continue
+		}
+		var set BackupSet
+		if json.Unmarshal(content, &set) != nil {
+			continue
+		}
+		set.Dir = dir
+		sets = append(sets, set)
+	}
+	sort.SliceStable(sets, func(i, j int) bool {
+		return sets[i].Time.After(sets[j].Time)
+	})
+	return sets, nil
+}
+
+// Remove backup sets older than retentionDays and beyond the newest
+// maxBackups, where 0 keeps them regardless
+func pruneBackups(rootItemPath string, retentionDays int, maxBackups int) error {
+	sets, err := readBackupSets(rootItemPath)
+	if err != nil {
+		return err
+	}
+	cutoff := time.Now().AddDate(0, 0, -retentionDays)
+	for i, set := range sets {
+		if (retentionDays > 0 && set.Time.Before(cutoff)) || (maxBackups > 0 && i >= maxBackups) {
+			if err := os.RemoveAll(set.Dir); err != nil {
+				return fmt.Errorf("error removing backup %s: %v", set.Dir, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (app *App) openBackups() error {
+	sets, err := readBackupSets(app.rootItem.Path)
+	if err != nil {
+		return err
+	}
+	if len(sets) == 0 {
+		return userErr{"No backups yet"}
+	}
+	app.backups = &Backups{sets: sets}
+	app.setFocus(FocusBackups)
+	return nil
+}
+
+func runBackups(app *App, args []string) error {
+	return app.openBackups()
+}
+
+func (app *App) closeBackups() {
+	app.backups = nil
+	app.setFocus(FocusTree)
+}
+
+func (app *App) moveBackupSelection(delta int) {
+	b := app.backups
+	b.selection = max(min(b.selection+delta, len(b.sets)-1), 0)
+}
+
+// Copy the files of the selected backup set back into the vault, backing up
+// the versions they replace first so restoring can be reverted the same way
+func (app *App) restoreBackup() error {
+	set := app.backups.sets[app.backups.selection]
+	prompt := fmt.Sprintf("Restore %d files from %s, replacing the current versions? (y/N): ", len(set.Files), set.Time.Format("2006-01-02 15:04:05"))
+	if !getConfirmation(prompt, app.screen) {
+		return nil
+	}
+	app.recordOperation("restore", set.Dir)
+	var targets []string
+	for _, name := range set.Files {
+		targets = append(targets, filepath.Join(app.rootItem.Path, filepath.FromSlash(name)))
+	}
+	if err := app.backup("restore", targets...); err != nil {
+		return err
+	}
+	defer app.rebuild()
+	for i, name := range set.Files {
+		if err := copyFile(filepath.Join(set.Dir, filepath.FromSlash(name)), targets[i]); err != nil {
+			return err
+		}
+	}
+	app.closeBackups()
+	renderMessage(fmt.Sprintf("Restored %d files", len(set.Files)), app.screen)
+	return nil
+}
+
+func renderBackups(app *App) {
+	screen := app.screen
+	b := app.backups
+	screen.Clear()
+	width, height := screen.Size()
+	area := Rect{0, 0, width, height - 2}
+
+	offset := max(b.selection-area.Height+1, 0)
+	for i, set := range b.sets[offset:] {
+		if i >= area.Height {
+			break
+		}
+		row := area.Y + i
+		heading := fmt.Sprintf("%s  %-12s %3d files  ", set.Time.Format("2006-01-02 15:04:05"), set.Operation, len(set.Files))
+		headingStyle := theme.Accent
+		filesStyle := tcell.StyleDefault
+		if offset+i == b.selection {
+			headingStyle = theme.Selection
+			filesStyle = theme.Selection
+		}
+		renderText(area.X, row, heading, headingStyle, screen)
+		col := area.X + runewidth.StringWidth(heading)
+		files := runewidth.Truncate(strings.Join(set.Files, ", "), max(area.Width-col, 0), "…")
+		renderText(col, row, files, filesStyle, screen)
+	}
+
+	renderHorizontalSeparator(0, height-2, width, separatorRune(app.config.HorizontalSeparator), screen)
+	label := " BACKUPS "
+	status := fmt.Sprintf("%d/%d  ↑/↓: Select | Enter: Restore | Esc: Tree", b.selection+1, len(b.sets))
+	if app.config.ReadOnly {
+		status = fmt.Sprintf("%d/%d  ↑/↓: Select | Esc: Tree", b.selection+1, len(b.sets))
+	}
+	renderClearArea(0, height-1, width, height, screen)
+	renderText(0, height-1, label, theme.FooterLabel, screen)
+	renderText(len(label)+1, height-1, status, theme.Footer, screen)
+	screen.Show()
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"fmt"
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var (
+	boardHeadingRegex  = regexp.MustCompile(`^#{1,6}\s+(.*?)\s*#*$`)
+	boardListItemRegex = regexp.MustCompile(`^[-*+]\s+(.*)$`)
+	boardTaskRegex     = regexp.MustCompile(`^(\s*[-*+]\s+\[)([ xX/])(\]\s*)(.*)$`)
+)
+
+// Task states of the checkbox board, written as - [ ], - [/] and - [x]
+var (
+	boardTaskColumns = []string{"Todo", "Doing", "Done"}
+	boardTaskMarkers = []byte{' ', '/', 'x'}
+)
+
+// KanbanCard is a task on the board spanning lines [Start, End) of the note,
+// 0-based, including any indented lines below it
+type KanbanCard struct {
+	Start int
+	End   int
+	Text  string
+}
+
+// Board shows the tasks of a note in columns. When the note has headings named
+// like the configured columns, the list items under them are the cards and
+// moving a card moves its lines; otherwise checkbox tasks are sorted by state.
+type Board struct {
+	path      string
+	headings  bool
+	columns   []string
+	cards     [][]KanbanCard
+	column    int
+	selection int
+}
+
+func parseBoard(lines []string, columns []string) (headings bool, cards [][]KanbanCard) {
+	cards = make([][]KanbanCard, len(columns))
+	current := -1
+	for i := 0; i < len(lines); i++ {
+		if m := boardHeadingRegex.FindStringSubmatch(lines[i]); m != nil {
+			current = -1
+			for c, name := range columns {
+				if strings.EqualFold(m[1], name) {
+					current = c
+					headings = true
+				}
+			}
+			continue
+		}
+		if current == -1 {
+			continue
+		}
+		if m := boardListItemRegex.FindStringSubmatch(lines[i]); m != nil {
+			end := i + 1
+			for end < len(lines) && (strings.HasPrefix(lines[end], " ") || strings.HasPrefix(lines[end], "\t")) {
+				end++
+			}
+			text := m[1]
+			if task := boardTaskRegex.FindStringSubmatch(lines[i]); task != nil {
+				text = task[4]
+			}
+			cards[current] = append(cards[current], KanbanCard{i, end, text})
+			i = end - 1
+		}
+	}
+	if headings {
+		return true, cards
+	}
+
+	cards = make([][]KanbanCard, len(boardTaskColumns))
+	for i, line := range lines {
+		if m := boardTaskRegex.FindStringSubmatch(line); m != nil {
+			state := strings.IndexByte(string(boardTaskMarkers), strings.ToLower(m[2])[0])
+			cards[state] = append(cards[state], KanbanCard{i, i + 1, m[4]})
+		}
+	}
+	return false, cards
+}
+
+func readLines(path string) ([]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", path, err)
+	}
+	return strings.Split(string(content), "\n"), nil
+}
+
+func (app *App) openBoard() error {
+	item := app.selectedItem()
+	if !isFile(item.Path) || isStructuredFile(item.Path) {
+		return userErr{"Select a note to show its tasks as a board"}
+	}
+	board := &Board{path: item.Path}
+	if err := app.loadBoard(board); err != nil {
+		return err
+	}
+	total := 0
+	for _, cards := range board.cards {
+		total += len(cards)
+	}
+	if total == 0 {
+		return userErr{"No tasks in this note"}
+	}
+	app.board = board
+	app.setFocus(FocusBoard)
+	return nil
+}
+
+func (app *App) loadBoard(board *Board) error {
+	lines, err := readLines(board.path)
+	if err != nil {
+		return err
+	}
+	board.headings, board.cards = parseBoard(lines, app.config.KanbanColumns)
+	board.columns = boardTaskColumns
+	if board.headings {
+		board.columns = app.config.KanbanColumns
+	}
+	board.clampSelection()
+	return nil
+}
+
+func (b *Board) clampSelection() {
+	b.column = max(min(b.column, len(b.columns)-1), 0)
+	b.selection = max(min(b.selection, len(b.cards[b.column])-1), 0)
+}
+
+func (app *App) closeBoard() {
+	app.board = nil
+	app.setFocus(FocusTree)
+}
+
+func (app *App) moveBoardSelection(columns int, cards int) {
+	b := app.board
+	b.column += columns
+	b.selection += cards
+	b.clampSelection()
+}
+
+func (b *Board) selectedCard() (KanbanCard, bool) {
+	if len(b.cards[b.column]) == 0 {
+		return KanbanCard{}, false
+	}
+	return b.cards[b.column][b.selection], true
+}
+
+// Move the selected card to the neighbouring column and write the note back
+func (app *App) moveBoardCard(delta int) error {
+	b := app.board
+	card, ok := b.selectedCard()
+	target := b.column + delta
+	if !ok || target < 0 || target >= len(b.columns) {
+		return nil
+	}
+	lines, err := readLines(b.path)
+	if err != nil {
+		return err
+	}
+	if b.headings {
+		lines = moveCardLines(lines, card, b.cards[target], app.config.KanbanColumns[target])
+	} else {
+		m := boardTaskRegex.FindStringSubmatch(lines[card.Start])
+		if m == nil {
+			return userErr{"The note changed, reopen the board"}
+		}
+		lines[card.Start] = m[1] + string(boardTaskMarkers[target]) + m[3] + m[4]
+	}
+
+	info, err := os.Stat(b.path)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %v", b.path, err)
+	}
+	app.recordOperation("board-move", b.path)
+	if err := os.WriteFile(b.path, []byte(strings.Join(lines, "\n")), info.Mode()); err != nil {
+		return fmt.Errorf("error writing %s: %v", b.path, err)
+	}
+	defer app.rebuild()
+
+	b.column = target
+	if err := app.loadBoard(b); err != nil {
+		return err
+	}
+	for i, moved := range b.cards[target] {
+		if moved.Text == card.Text {
+			b.selection = i
+		}
+	}
+	return nil
+}
+
+// Cut the card's lines and insert them after the last card of the target
+// column, or below its heading when the column is empty
+func moveCardLines(lines []string, card KanbanCard, targetCards []KanbanCard, heading string) []string {
+	block := append([]string{}, lines[card.Start:card.End]...)
+	insert := -1
+	if len(targetCards) > 0 {
+		insert = targetCards[len(targetCards)-1].End
+	} else {
+		for i, line := range lines {
+			if m := boardHeadingRegex.FindStringSubmatch(line); m != nil && strings.EqualFold(m[1], heading) {
+				insert = i + 1
+				if insert < len(lines) && strings.TrimSpace(lines[insert]) == "" {
+					insert++
+				}
+				break
+			}
+		}
+	}
+	if insert == -1 {
+		return lines
+	}
+	if insert < len(lines) && boardHeadingRegex.MatchString(lines[insert]) {
+		block = append(block, "")
+	}
+
+	var result []string
+	for i := 0; i <= len(lines); i++ {
+		if i == insert {
+			result = append(result, block...)
+		}
+		if i < len(lines) && (i < card.Start || i >= card.End) {
+			result = append(result, lines[i])
+		}
+	}
+	return result
+}
+
+func (app *App) editBoardCard() error {
+	card, ok := app.board.selectedCard()
+	if !ok {
+		return nil
+	}
+	app.recordOperation("edit", app.board.path)
+	if err := app.openEditor(app.board.path, fmt.Sprintf("+%d", card.Start+1)); err != nil {
+		return err
+	}
+	return app.loadBoard(app.board)
+}
+
+func renderBoard(app *App) {
+	screen := app.screen
+	b := app.board
+	screen.Clear()
+	width, height := screen.Size()
+	columnWidth := width / len(b.columns)
+
+	for c, name := range b.columns {
+		x := c * columnWidth
+		header := fmt.Sprintf(" %s (%d)", name, len(b.cards[c]))
+		headerStyle := theme.Heading
+		if c == b.column {
+			headerStyle = overlayStyle(headerStyle, theme.Accent)
+		}
+		renderText(x, 0, header, headerStyle, screen)
+		renderHorizontalSeparator(x, 1, x+columnWidth-1, separatorRune(app.config.HorizontalSeparator), screen)
+
+		area := height - 4
+		offset := 0
+		if c == b.column {
+			offset = max(b.selection-area+1, 0)
+		}
+		for i, card := range b.cards[c][offset:] {
+			if i >= area {
+				break
+			}
+			style := tcell.StyleDefault
+			if c == b.column && offset+i == b.selection {
+				style = theme.Selection
+			}
+			text := runewidth.Truncate(" "+card.Text, columnWidth-1, "…")
+			renderText(x, 2+i, runewidth.FillRight(text, columnWidth-1), style, screen)
+		}
+	}
+
+	renderHorizontalSeparator(0, height-2, width, separatorRune(app.config.HorizontalSeparator), screen)
+	label := " BOARD "
+	status := "←/→: Column | ↑/↓: Card | </>: Move card | Enter: Edit | Esc: Tree"
+	if app.config.ReadOnly {
+		status = "←/→: Column | ↑/↓: Card | Esc: Tree"
+	}
+	renderClearArea(0, height-1, width, height, screen)
+	renderText(0, height-1, label, theme.FooterLabel, screen)
+	renderText(len(label)+1, height-1, status, theme.Footer, screen)
+	screen.Show()
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const breadcrumbSeparator = " › "
+
+// Breadcrumb is a path segment of the header with the columns it was drawn
+// at, so clicks can select the directory it stands for
+type Breadcrumb struct {
+	Text  string
+	Path  string
+	Start int
+	End   int
+}
+
+// The vault and the directories leading to the selection, ending with the
+// selection itself
+func (app *App) breadcrumbs() []Breadcrumb {
+	crumbs := []Breadcrumb{{Text: app.vaultTitle(), Path: app.rootItem.Path}}
+	path := app.selectedItem().Path
+	if path == app.rootItem.Path {
+		return crumbs
+	}
+	current := app.rootItem.Path
+	for _, name := range strings.Split(app.relativePath(path), string(os.PathSeparator)) {
+		current = filepath.Join(current, name)
+		crumbs = append(crumbs, Breadcrumb{Text: name, Path: current})
+	}
+	return crumbs
+}
+
+// Render the breadcrumbs on the header line, leaving out the leading ones
+// that don't fit, and remember where each was drawn
+func renderBreadcrumbs(app *App, y int) {
+	screen := app.screen
+	width, _ := screen.Size()
+	crumbs := app.breadcrumbs()
+	sepWidth := runewidth.StringWidth(breadcrumbSeparator)
+	total := 0
+	for _, crumb := range crumbs {
+		total += runewidth.StringWidth(crumb.Text) + sepWidth
+	}
+	skipped := 0
+	for total-sepWidth > width && len(crumbs)-skipped > 1 {
+		total -= runewidth.StringWidth(crumbs[skipped].Text) + sepWidth
+		skipped++
+	}
+
+	renderClearArea(0, y, width, y+1, screen)
+	x := 0
+	if skipped > 0 {
+		renderText(x, y, "…"+breadcrumbSeparator, theme.Separator, screen)
+		x += runewidth.StringWidth("…" + breadcrumbSeparator)
+	}
+	app.breadcrumbTargets = nil
+	for i, crumb := range crumbs[skipped:] {
+		if i > 0 {
+			renderText(x, y, breadcrumbSeparator, theme.Separator, screen)
+			x += sepWidth
+		}
+		style := theme.Heading
+		if skipped+i == len(crumbs)-1 {
+			style = theme.Accent
+		}
+		text := runewidth.Truncate(crumb.Text, max(width-x, 0), "…")
+		renderText(x, y, text, style, screen)
+		crumb.Start, crumb.End = x, x+runewidth.StringWidth(text)
+		app.breadcrumbTargets = append(app.breadcrumbTargets, crumb)
+		x = crumb.End
+	}
+}
+
+// Follow a click on a breadcrumb, a tree row or a table of contents entry in
+// the preview
+func (app *App) handleMouse(ev *tcell.EventMouse) {
+	if ev.Buttons()&tcell.Button1 == 0 || (app.focus != FocusTree && app.focus != FocusPreview) {
+		return
+	}
+	x, y := ev.Position()
+	layout := app.layout()
+	if y == layout.HeaderY {
+		for _, crumb := range app.breadcrumbTargets {
+			if x >= crumb.Start && x < crumb.End {
+				app.selectPath(crumb.Path)
+				app.setFocus(FocusTree)
+				return
+			}
+		}
+		return
+	}
+	tree := layout.Tree
+	if x >= tree.X && x < tree.X+tree.Width && y >= tree.Y && y < tree.Y+tree.Height {
+		if row := app.treeOffset + y - tree.Y; row < len(app.flatTree) {
+			app.moveSelection(row - app.currentSelection)
+			app.setFocus(FocusTree)
+		}
+		return
+	}
+	preview := layout.Preview
+	if !app.previewHidden && x >= preview.X && x < preview.X+preview.Width && y >= preview.Y && y < preview.Y+preview.Height {
+		app.followTOCEntry(y - preview.Y)
+	}
+}
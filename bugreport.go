@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// Config keys holding secrets or commands printing them, such as token or
+// password_command. The secret word must be a whole _ separated part, so keys
+// like keymap or author are kept.
+var secretKeyRegex = regexp.MustCompile(`(?i)^(.*_)?(token|secret|password|passphrase|api_key|auth)(_.*)?$`)
+
+var (
+	// Secret looking key=value or key: value pairs in free text
+	secretPairRegex = regexp.MustCompile(`(?i)\b((?:\w+_)?(?:token|secret|password|passphrase|api_key|auth)(?:_\w+)?)("?\s*[:=]\s*)("[^"]*"|\S+)`)
+	urlRegex        = regexp.MustCompile(`(?i)\b[a-z][a-z0-9+.-]*://[^\s"'<>]+`)
+)
+
+func runBugReport(app *App, args []string) error {
+	path := filepath.Join(os.TempDir(), "notes-bugreport-"+time.Now().Format("20060102-150405")+".md")
+	if len(args) > 0 {
+		path = args[0]
+	}
+
+	err := os.WriteFile(path, []byte(buildBugReport(app)), 0o600)
+	if err != nil {
+		return fmt.Errorf("error writing bug report %s: %v", path, err)
+	}
+	renderMessage("Bug report written to "+path, app.screen)
+	return nil
+}
+
+func buildBugReport(app *App) string {
+	var b strings.Builder
+
+	b.WriteString("# Bug report\n\n## Versions\n\n")
+	fmt.Fprintf(&b, "- notes: %s (%s)\n", version, buildRevision())
+	fmt.Fprintf(&b, "- go: %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(&b, "- TERM: %s\n", os.Getenv("TERM"))
+	width, height := app.screen.Size()
+	fmt.Fprintf(&b, "- screen: %dx%d, %d colors\n", width, height, app.screen.Colors())
+
+	b.WriteString("\n## Config\n\n```\n")
+	b.WriteString(sanitizedConfig(app.rootItem.Path))
+	b.WriteString("\n```\n")
+
+	files, dirs, size := vaultStats(app.rootItem.Path)
+	b.WriteString("\n## Vault\n\n")
+	fmt.Fprintf(&b, "- files: %d\n- directories: %d\n- size: %d bytes\n", files, dirs, size)
+
+	b.WriteString("\n## Recent operations\n\n")
+	for _, op := range app.operations {
+		b.WriteString("- " + redactText(op, app.rootItem.Path) + "\n")
+	}
+
+	b.WriteString("\n## Log\n\n```\n")
+	for _, line := range tailLog(200) {
+		b.WriteString(redactText(line, app.rootItem.Path) + "\n")
+	}
+	b.WriteString("```\n")
+
+	return b.String()
+}
+
+func buildRevision() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" {
+			return setting.Value
+		}
+	}
+	return "unknown"
+}
+
+// Read the config file as generic JSON and redact any values whose key looks
+// like a secret, and the URLs and paths in the others
+func sanitizedConfig(vault string) string {
+	path := configFilePath()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "no config file at " + path
+	}
+	var config any
+	if err := json.Unmarshal(content, &config); err != nil {
+		return "unparsable config file at " + path
+	}
+	out, err := json.MarshalIndent(redactSecrets(config, vault), "", "  ")
+	if err != nil {
+		return "unprintable config file at " + path
+	}
+	return path + "\n" + string(out)
+}
+
+func redactSecrets(value any, vault string) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, child := range v {
+			if secretKeyRegex.MatchString(key) {
+				v[key] = "REDACTED"
+			} else {
+				v[key] = redactSecrets(child, vault)
+			}
+		}
+	case []any:
+		for i, child := range v {
+			v[i] = redactSecrets(child, vault)
+		}
+	case string:
+		return redactText(v, vault)
+	}
+	return value
+}
+
+// Redact free text such as log lines: secret looking pairs lose their value,
+// URLs are cut down to their host since shared notes are secret by their
+// path, and the vault and home directories are replaced
+func redactText(text string, vault string) string {
+	text = secretPairRegex.ReplaceAllString(text, "${1}${2}REDACTED")
+	text = urlRegex.ReplaceAllStringFunc(text, func(raw string) string {
+		u, err := url.Parse(raw)
+		if err != nil || u.Host == "" {
+			return "REDACTED"
+		}
+		return u.Scheme + "://" + u.Hostname() + "/REDACTED"
+	})
+	if vault != "" {
+		text = strings.ReplaceAll(text, vault, "<vault>")
+	}
+	if home, err := os.UserHomeDir(); err == nil && home != "" && home != "/" {
+		text = strings.ReplaceAll(text, home, "~")
+	}
+	return text
+}
+
+func vaultStats(root string) (files int, dirs int, size int64) {
+	_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			dirs++
+			return nil
+		}
+		files++
+		if info, err := d.Info(); err == nil {
+			size += info.Size()
+		}
+		return nil
+	})
+	return files, dirs, size
+}
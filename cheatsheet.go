@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const defaultCheatsheetName = "cheatsheet.md"
+
+// Render the effective keymaps and commands as a markdown document
+func buildCheatsheet(keymaps map[Focus]Keymap, commands map[string]Command) string {
+	var b strings.Builder
+	b.WriteString("# Keybindings\n")
+
+	focuses := make([]Focus, 0, len(keymaps))
+	for focus := range keymaps {
+		focuses = append(focuses, focus)
+	}
+	sort.Slice(focuses, func(i, j int) bool { return focuses[i] < focuses[j] })
+
+	for _, focus := range focuses {
+		name := focus.String()
+		fmt.Fprintf(&b, "\n## %s\n\n| Key | Action |\n| --- | --- |\n", strings.ToUpper(name[:1])+name[1:])
+		names, keys := keymaps[focus].actionKeys()
+		for _, action := range names {
+			fmt.Fprintf(&b, "| %s | %s |\n", strings.ReplaceAll(strings.Join(keys[action], ", "), "|", "\\|"), action)
+		}
+	}
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	b.WriteString("\n## Commands\n\nPress `:` and enter one of:\n\n")
+	for _, name := range names {
+		fmt.Fprintf(&b, "- `%s`\n", commands[name].Usage)
+	}
+	return b.String()
+}
+
+func runCheatsheet(app *App, args []string) error {
+	name := defaultCheatsheetName
+	if len(args) > 0 {
+		name = args[0]
+	}
+	path, err := resolveAndValidatePath(name, app.rootItem.Path)
+	if err != nil {
+		return err
+	}
+	if isFile(path) && !getConfirmation("A file with that name already exists. Overwrite? (y/N): ", app.screen) {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return fmt.Errorf("error creating directory %s: %v", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, []byte(buildCheatsheet(app.keymaps, app.commands)), 0o644); err != nil {
+		return fmt.Errorf("error writing cheatsheet %s: %v", path, err)
+	}
+	app.recordOperation("cheatsheet", path)
+	app.rebuild()
+	app.selectPath(path)
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+)
+
+// Subcommand is run from the command line instead of starting the TUI, e.g.
+// notes cheatsheet
+type Subcommand struct {
+	Name  string
+	Usage string
+	Run   func(args []string) error
+}
+
+func subcommands() map[string]Subcommand {
+	list := []Subcommand{
+		{"cheatsheet", "cheatsheet", runCheatsheetSubcommand},
+		{"publish", "publish -d dir <outdir>", runPublishSubcommand},
+		{"serve", "serve --web -d dir [--addr host:port]", runServeSubcommand},
+		{"watch", "watch -d dir --export-html <outdir>", runWatchSubcommand},
+		{"tree", "tree -d dir [--json]", runTreeSubcommand},
+		{"search", "search -d dir [--json] <query>", runSearchSubcommand},
+		{"meta", "meta -d dir [--json] <note>", runMetaSubcommand},
+		{"jex", "jex export|import -d dir <file.jex>", runJexSubcommand},
+		{"profile", "profile export|import [-d dir] [--force] <file.tar.gz>", runProfileSubcommand},
+		{"self-update", "self-update", runSelfUpdateSubcommand},
+		{"version", "version [--verbose] [--json] [-d dir]", runVersionSubcommand},
+	}
+	m := make(map[string]Subcommand, len(list))
+	for _, s := range list {
+		m[s.Name] = s
+	}
+	return m
+}
+
+// Load the config and effective keymaps for subcommands that need them
+func loadConfigAndKeymaps() (Config, map[Focus]Keymap, error) {
+	config, err := loadConfig(configFilePath())
+	if err != nil {
+		return config, nil, err
+	}
+	keymaps := defaultKeymaps()
+	if err := applyKeymapOverrides(keymaps, config.Keymap); err != nil {
+		return config, nil, err
+	}
+	return config, keymaps, nil
+}
+
+func runCheatsheetSubcommand(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("usage: notes cheatsheet")
+	}
+	_, keymaps, err := loadConfigAndKeymaps()
+	if err != nil {
+		return err
+	}
+	fmt.Print(buildCheatsheet(keymaps, defaultCommands()))
+	return nil
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Pasted images are saved here, next to the note they're attached to
+const assetsDirName = "assets"
+
+// How long to wait for a clipboard tool's output once it has exited
+const clipboardWaitDelay = time.Second
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// Commands writing their standard input to the system clipboard, tried in
+// order; the first one installed for the current session is used
+func clipboardCopyCommands() [][]string {
+	switch {
+	case runtime.GOOS == "darwin":
+		return [][]string{{"pbcopy"}}
+	case runtime.GOOS == "windows":
+		return [][]string{{"clip"}}
+	}
+	var commands [][]string
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		commands = append(commands, []string{"wl-copy"})
+	}
+	return append(commands,
+		[]string{"xclip", "-selection", "clipboard"},
+		[]string{"xsel", "--clipboard", "--input"},
+		[]string{"clip.exe"},
+	)
+}
+
+// Put text on the system clipboard. Without a clipboard tool, e.g. over SSH,
+// the terminal is asked to set it with an OSC 52 escape sequence.
+func copyToClipboard(text string) error {
+	for _, args := range clipboardCopyCommands() {
+		if _, err := exec.LookPath(args[0]); err != nil {
+			continue
+		}
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		// wl-copy and xclip leave a daemon serving the clipboard behind, which
+		// inherits the stderr pipe and would keep Run waiting for it to close
+		cmd.WaitDelay = clipboardWaitDelay
+		if err := cmd.Run(); err != nil && !errors.Is(err, exec.ErrWaitDelay) {
+			return fmt.Errorf("error running %s: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+		}
+		return nil
+	}
+	tty, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0)
+	if err != nil {
+		return userErr{"No clipboard tool found, install wl-clipboard, xclip or xsel"}
+	}
+	defer tty.Close()
+	sequence := "\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte(text)) + "\a"
+	if os.Getenv("TMUX") != "" {
+		sequence = "\x1bPtmux;\x1b" + sequence + "\x1b\\"
+	}
+	_, err = tty.WriteString(sequence)
+	return err
+}
+
+// Copy the selected item's path, relative to the vault root or absolute
+func (app *App) copyPath(absolute bool) error {
+	path, err := filepath.Abs(app.selectedItem().Path)
+	if err != nil {
+		return fmt.Errorf("error resolving %s: %v", app.selectedItem().Path, err)
+	}
+	if !absolute {
+		root, err := filepath.Abs(app.rootItem.Path)
+		if err != nil {
+			return fmt.Errorf("error resolving %s: %v", app.rootItem.Path, err)
+		}
+		if path, err = filepath.Rel(root, path); err != nil {
+			return fmt.Errorf("error resolving %s: %v", app.selectedItem().Path, err)
+		}
+	}
+	if err := copyToClipboard(path); err != nil {
+		return err
+	}
+	renderMessage("Copied "+path, app.screen)
+	return nil
+}
+
+// Commands printing the PNG image on the system clipboard
+func clipboardImageCommands() [][]string {
+	if runtime.GOOS == "darwin" {
+		return [][]string{{"pngpaste", "-"}}
+	}
+	var commands [][]string
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		commands = append(commands, []string{"wl-paste", "--no-newline", "--type", "image/png"})
+	}
+	return append(commands, []string{"xclip", "-selection", "clipboard", "-target", "image/png", "-out"})
+}
+
+func readClipboardImage() ([]byte, error) {
+	for _, args := range clipboardImageCommands() {
+		if _, err := exec.LookPath(args[0]); err != nil {
+			continue
+		}
+		var stdout bytes.Buffer
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Stdout = &stdout
+		if err := cmd.Run(); err != nil || !bytes.HasPrefix(stdout.Bytes(), pngSignature) {
+			return nil, userErr{"No image on the clipboard"}
+		}
+		return stdout.Bytes(), nil
+	}
+	return nil, userErr{"No clipboard tool found, install pngpaste, wl-clipboard or xclip"}
+}
+
+// Save the clipboard image to assets/ next to the selected note and append a
+// markdown image link to the note
+func (app *App) pasteImage() error {
+	item := app.selectedItem()
+	if !isFile(item.Path) || isStructuredFile(item.Path) {
+		return userErr{"Select a note to attach the image to"}
+	}
+	if isEncryptedFile(item.Path) {
+		return userErr{"Images can't be attached to encrypted notes"}
+	}
+	image, err := readClipboardImage()
+	if err != nil {
+		return err
+	}
+
+	note := strings.TrimSuffix(filepath.Base(item.Path), filepath.Ext(item.Path))
+	target := uniquePath(filepath.Join(filepath.Dir(item.Path), assetsDirName, note+"-"+time.Now().Format("20060102-150405")+".png"))
+	if err := os.MkdirAll(filepath.Dir(target), os.ModePerm); err != nil {
+		return fmt.Errorf("error creating directory %s: %v", filepath.Dir(target), err)
+	}
+	app.recordOperation("paste-image", target)
+	defer app.rebuild()
+	if err := os.WriteFile(target, image, 0o644); err != nil {
+		return fmt.Errorf("error writing %s: %v", target, err)
+	}
+
+	content, err := os.ReadFile(item.Path)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %v", item.Path, err)
+	}
+	if len(content) > 0 && !bytes.HasSuffix(content, []byte("\n")) {
+		content = append(content, '\n')
+	}
+	link := filepath.ToSlash(filepath.Join(assetsDirName, filepath.Base(target)))
+	content = append(content, fmt.Sprintf("\n![%s](%s)\n", filepath.Base(target), strings.ReplaceAll(link, " ", "%20"))...)
+	info, err := os.Stat(item.Path)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %v", item.Path, err)
+	}
+	if err := os.WriteFile(item.Path, content, info.Mode()); err != nil {
+		return fmt.Errorf("error writing %s: %v", item.Path, err)
+	}
+	return nil
+}
+
+// Commands printing the text on the system clipboard
+func clipboardPasteCommands() [][]string {
+	switch {
+	case runtime.GOOS == "darwin":
+		return [][]string{{"pbpaste"}}
+	case runtime.GOOS == "windows":
+		return [][]string{{"powershell", "-NoProfile", "-Command", "Get-Clipboard"}}
+	}
+	var commands [][]string
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		commands = append(commands, []string{"wl-paste", "--no-newline"})
+	}
+	return append(commands,
+		[]string{"xclip", "-selection", "clipboard", "-out"},
+		[]string{"xsel", "--clipboard", "--output"},
+	)
+}
+
+func readClipboardText() (string, error) {
+	for _, args := range clipboardPasteCommands() {
+		if _, err := exec.LookPath(args[0]); err != nil {
+			continue
+		}
+		var stdout bytes.Buffer
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Stdout = &stdout
+		if err := cmd.Run(); err != nil || strings.TrimSpace(stdout.String()) == "" {
+			return "", userErr{"No text on the clipboard"}
+		}
+		return strings.ReplaceAll(stdout.String(), "\r\n", "\n"), nil
+	}
+	return "", userErr{"No clipboard tool found, install wl-clipboard, xclip or xsel"}
+}
+
+// Create a note holding the clipboard text in the selected directory, asking
+// only for its name
+func (app *App) newFromClipboard() error {
+	text, err := readClipboardText()
+	if err != nil {
+		return err
+	}
+	dir := app.selectedDir()
+	app.recordOperation("new-from-clipboard", dir)
+	defer app.rebuild()
+	path, err := handleNew(TreeItem{Path: dir, IsDir: true}, app.rootItem.Path, app.screen)
+	if err != nil || path == "" {
+		return err
+	}
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+		return fmt.Errorf("error writing %s: %v", path, err)
+	}
+	app.fireHook(hookNoteCreated, path, "")
+	app.rebuildTree()
+	app.selectPath(path)
+	return nil
+}
+
+func runNewFromClipboard(app *App, args []string) error {
+	return app.newFromClipboard()
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Command struct {
+	Name  string
+	Usage string
+	Run   func(app *App, args []string) error
+}
+
+func defaultCommands() map[string]Command {
+	commands := []Command{
+		{"backlinks", "backlinks", runBacklinks},
+		{"backups", "backups", runBackups},
+		{"bugreport", "bugreport [file]", runBugReport},
+		{"cheatsheet", "cheatsheet [path]", runCheatsheet},
+		{"daily", "daily [date]", runDaily},
+		{"diff", "diff <path>", runDiff},
+		{"disk-usage", "disk-usage", runDiskUsage},
+		{"export-archive", "export-archive <file.zip|file.tar.gz>", runExportArchive},
+		{"export-document", "export-document <file.md|file.html|file.pdf>", runExportDocument},
+		{"filter-label", "filter-label [color]", runFilterLabel},
+		{"filter-field", "filter-field [key value]", runFilterField},
+		{"filter-tag", "filter-tag [tag]", runFilterTag},
+		{"import-archive", "import-archive <file.zip|file.tar.gz>", runImportArchive},
+		{"new-from-clipboard", "new-from-clipboard", runNewFromClipboard},
+		{"rename-tag", "rename-tag <old> <new>", runRenameTag},
+		{"replace", "replace [regexp [replacement]]", runReplace},
+		{"search", "search [text]", runSearch},
+		{"suggest-links", "suggest-links", runSuggestLinks},
+		{"vault", "vault [name]", runVault},
+		{"sync", "sync", runSync},
+		{"sort-field", "sort-field [field [asc|desc]]", runSortField},
+	}
+	m := make(map[string]Command, len(commands))
+	for _, c := range commands {
+		m[c.Name] = c
+	}
+	return m
+}
+
+func handleCommand(app *App) error {
+	input, ok := getUserInput(":", "", app.screen)
+	if !ok {
+		return nil
+	}
+	fields := strings.Fields(input)
+	if len(fields) == 0 {
+		return nil
+	}
+	command, ok := app.commands[fields[0]]
+	if !ok {
+		return userErr{fmt.Sprintf("Unknown command: %s", fields[0])}
+	}
+	if app.config.ReadOnly && writingCommands[command.Name] {
+		return errReadOnly
+	}
+	return command.Run(app, fields[1:])
+}
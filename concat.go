@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var headingLineRegex = regexp.MustCompile(`^(#{1,6})(\s)`)
+
+// Move the headings of a note down by levels, at most to level 6, leaving
+// fenced code blocks alone
+func shiftHeadings(body string, levels int) string {
+	lines := strings.Split(body, "\n")
+	inFence := false
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inFence = !inFence
+		}
+		if inFence {
+			continue
+		}
+		if m := headingLineRegex.FindStringSubmatch(line); m != nil {
+			lines[i] = strings.Repeat("#", min(len(m[1])+levels, 6)) + line[len(m[1]):]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Append the markdown notes of a directory in tree order, each under a
+// heading with its title, and its subdirectories as sections at the next
+// level. Hidden directories and encrypted notes are left out. Returns the
+// number of notes appended.
+func concatSection(b *strings.Builder, root string, item TreeItem, level int) int {
+	count := 0
+	heading := strings.Repeat("#", min(level, 6))
+	for _, child := range item.Children {
+		if strings.HasPrefix(child.Display, ".") {
+			continue
+		}
+		if child.IsDir {
+			var section strings.Builder
+			if n := concatSection(&section, root, child, level+1); n > 0 {
+				fmt.Fprintf(b, "%s %s\n\n%s", heading, child.Display, section.String())
+				count += n
+			}
+			continue
+		}
+		if !isMarkdownFile(child.Path) {
+			continue
+		}
+		content, err := os.ReadFile(child.Path)
+		if err != nil {
+			continue
+		}
+		lines, body, _ := splitFrontmatter(content)
+		rel, _ := filepath.Rel(root, child.Path)
+		title := publishTitle(filepath.ToSlash(rel), parseFrontmatter(lines), string(body))
+		text := strings.TrimSpace(string(body))
+		// The title heading of the note is replaced by the section heading
+		if first, rest, _ := strings.Cut(text, "\n"); strings.TrimSpace(strings.TrimPrefix(first, "# ")) == title && strings.HasPrefix(first, "# ") {
+			text = strings.TrimSpace(rest)
+		}
+		fmt.Fprintf(b, "%s %s\n\n", heading, title)
+		if text != "" {
+			b.WriteString(shiftHeadings(text, level) + "\n\n")
+		}
+		count++
+	}
+	return count
+}
+
+// The notes under dir as one markdown document titled with the directory name
+func concatDirectory(dir string, order TreeSort) (string, int) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", filepath.Base(dir))
+	count := concatSection(&b, dir, buildTree(dir, order), 2)
+	return strings.TrimRight(b.String(), "\n") + "\n", count
+}
+
+// Write the notes under the selected directory to a single markdown, HTML or,
+// with pandoc, PDF document chosen by the extension of the file given
+func runExportDocument(app *App, args []string) error {
+	usage := userErr{"Usage: export-document <file.md|file.html|file.pdf>"}
+	if len(args) != 1 {
+		return usage
+	}
+	path, err := filepath.Abs(expandHome(args[0]))
+	if err != nil {
+		return fmt.Errorf("error resolving %s: %v", args[0], err)
+	}
+	ext := strings.ToLower(filepath.Ext(path))
+	switch ext {
+	case ".md", ".markdown", ".html", ".htm":
+	case ".pdf":
+		if !app.hasTool("pandoc") {
+			return userErr{"pandoc not found, it's needed for PDF documents"}
+		}
+	default:
+		return usage
+	}
+	dir := app.selectedDir()
+	document, count := concatDirectory(dir, app.config.TreeSort)
+	if count == 0 {
+		return userErr{"No notes in " + app.relativePath(dir)}
+	}
+	if isFile(path) && !getConfirmation(fmt.Sprintf("%s exists. Overwrite? (y/N): ", path), app.screen) {
+		return nil
+	}
+	app.recordOperation("export-document", dir+" "+path)
+
+	switch ext {
+	case ".html", ".htm":
+		var b bytes.Buffer
+		page := publishPage{Title: filepath.Base(dir), Body: template.HTML(renderMarkdownHTML(convertHTML(document)))}
+		if err := publishTemplate.Execute(&b, page); err != nil {
+			return fmt.Errorf("error rendering %s: %v", path, err)
+		}
+		document = b.String()
+	case ".pdf":
+		// Relative image links of notes in the directory itself resolve
+		cmd := exec.Command("pandoc", "--from", "markdown", "--output", path)
+		cmd.Dir = dir
+		cmd.Stdin = strings.NewReader(document)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			message := strings.TrimSpace(string(output))
+			if message == "" {
+				message = err.Error()
+			}
+			return userErr{"pandoc: " + strings.Split(message, "\n")[0]}
+		}
+	}
+	if ext != ".pdf" {
+		if err := os.WriteFile(path, []byte(document), 0o644); err != nil {
+			return fmt.Errorf("error writing %s: %v", path, err)
+		}
+	}
+	app.rebuild()
+	renderMessage(fmt.Sprintf("Exported %d notes to %s", count, path), app.screen)
+	return nil
+}
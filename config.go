@@ -0,0 +1,290 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/mattn/go-runewidth"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	defaultSplitRatio           = 0.2
+	defaultHorizontalSplitRatio = 0.4
+	minSplitRatio               = 0.1
+	maxSplitRatio               = 0.8
+	maxPreviewPadding           = 10
+)
+
+// Config is read from config.json in the user config directory
+type Config struct {
+	SplitRatio           float64                        `json:"split_ratio"`
+	HorizontalSplitRatio float64                        `json:"horizontal_split_ratio"`
+	Layout               LayoutMode                     `json:"layout"`
+	DiffTool             string                         `json:"diff_tool"`
+	Title                string                         `json:"title"`
+	TerminalTitle        bool                           `json:"terminal_title"`
+	IdleTimeout          int                            `json:"idle_timeout"`
+	IdleAction           string                         `json:"idle_action"`
+	CheckUpdates         bool                           `json:"check_updates"`
+	SearchBackend        string                         `json:"search_backend"`
+	PreviewPadding       int                            `json:"preview_padding"`
+	VerticalSeparator    string                         `json:"vertical_separator"`
+	HorizontalSeparator  string                         `json:"horizontal_separator"`
+	KanbanColumns        []string                       `json:"kanban_columns"`
+	AgeIdentity          string                         `json:"age_identity"`
+	AgeRecipients        []string                       `json:"age_recipients"`
+	GPGRecipients        []string                       `json:"gpg_recipients"`
+	Editors              map[string]string              `json:"editors"`
+	Formatters           map[string]string              `json:"formatters"`
+	ReadOnly             bool                           `json:"read_only"`
+	LockedDir            string                         `json:"locked_dir"`
+	LockTimeout          int                            `json:"lock_timeout"`
+	BackupRetentionDays  int                            `json:"backup_retention_days"`
+	MaxBackups           int                            `json:"max_backups"`
+	Hooks                map[string]string              `json:"hooks"`
+	UserCommands         []UserCommand                  `json:"user_commands"`
+	Previewers           []Previewer                    `json:"previewers"`
+	Theme                string                         `json:"theme"`
+	StatusLine           string                         `json:"status_line"`
+	VaultStatusLine      string                         `json:"vault_status_line"`
+	Breadcrumbs          bool                           `json:"breadcrumbs"`
+	Mouse                bool                           `json:"mouse"`
+	ShowMetadata         bool                           `json:"show_metadata"`
+	Icons                string                         `json:"icons"`
+	IconOverrides        map[string]string              `json:"icon_overrides"`
+	TreeSort             TreeSort                       `json:"tree_sort"`
+	Inbox                string                         `json:"inbox"`
+	TOCMinHeadings       int                            `json:"toc_min_headings"`
+	FolderCounts         string                         `json:"folder_counts"`
+	TreeColors           string                         `json:"tree_colors"`
+	Vaults               []Vault                        `json:"vaults"`
+	TmuxEditor           string                         `json:"tmux_editor"`
+	DatePrefixNames      bool                           `json:"date_prefix_names"`
+	SlugNames            string                         `json:"slug_names"`
+	DefaultExtension     string                         `json:"default_extension"`
+	MetadataSidecars     bool                           `json:"metadata_sidecars"`
+	VaultWarningMB       int                            `json:"vault_warning_mb"`
+	AttachmentsWarningMB int                            `json:"attachments_warning_mb"`
+	Share                ShareConfig                    `json:"share"`
+	Colors               map[string]string              `json:"colors"`
+	Keymap               map[string]map[string][]string `json:"keymap"`
+}
+
+// State holds choices made at runtime, persisted next to the config
+type State struct {
+	SplitRatio           float64  `json:"split_ratio,omitempty"`
+	HorizontalSplitRatio float64  `json:"horizontal_split_ratio,omitempty"`
+	Pinned               []string `json:"pinned,omitempty"`
+}
+
+func configFilePath() string {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(configDir, "notes", "config.json")
+}
+
+func stateFilePath() string {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(configDir, "notes", "state.json")
+}
+
+func defaultConfig() Config {
+	return Config{
+		SplitRatio:           defaultSplitRatio,
+		HorizontalSplitRatio: defaultHorizontalSplitRatio,
+		Layout:               LayoutVertical,
+		DiffTool:             "vimdiff {left} {right}",
+		TerminalTitle:        true,
+		IdleAction:           IdleDim,
+		SearchBackend:        SearchBackendAuto,
+		PreviewPadding:       2,
+		VerticalSeparator:    "│",
+		HorizontalSeparator:  "─",
+		KanbanColumns:        []string{"Todo", "Doing", "Done"},
+		LockTimeout:          300,
+		BackupRetentionDays:  30,
+		MaxBackups:           100,
+		Theme:                autoThemeName,
+		StatusLine:           defaultStatusLine,
+		Breadcrumbs:          true,
+		Icons:                iconsNone,
+		Inbox:                "inbox.md",
+		TOCMinHeadings:       8,
+		FolderCounts:         folderCountsNotes,
+		TreeColors:           treeColorsNone,
+		TmuxEditor:           tmuxEditorOff,
+		SlugNames:            slugNone,
+	}
+}
+
+func loadConfig(path string) (Config, error) {
+	config := defaultConfig()
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return config, fmt.Errorf("error reading config %s: %v", path, err)
+	}
+	if err := json.Unmarshal(content, &config); err != nil {
+		return config, fmt.Errorf("error parsing config %s: %v", path, err)
+	}
+	return config, validateConfig(config)
+}
+
+func validateConfig(config Config) error {
+	for _, ratio := range []float64{config.SplitRatio, config.HorizontalSplitRatio} {
+		if ratio < minSplitRatio || ratio > maxSplitRatio {
+			return fmt.Errorf("error: split ratios must be between %.1f and %.1f", minSplitRatio, maxSplitRatio)
+		}
+	}
+	if config.Layout != LayoutVertical && config.Layout != LayoutHorizontal {
+		return fmt.Errorf("error: layout must be %s or %s", LayoutVertical, LayoutHorizontal)
+	}
+	if config.IdleAction != IdleDim && config.IdleAction != IdleBlank {
+		return fmt.Errorf("error: idle_action must be %s or %s", IdleDim, IdleBlank)
+	}
+	if config.PreviewPadding < 0 || config.PreviewPadding > maxPreviewPadding {
+		return fmt.Errorf("error: preview_padding must be between 0 and %d", maxPreviewPadding)
+	}
+	for _, separator := range []string{config.VerticalSeparator, config.HorizontalSeparator} {
+		if utf8.RuneCountInString(separator) > 1 || runewidth.StringWidth(separator) > 1 {
+			return fmt.Errorf("error: separators must be a single narrow character or empty")
+		}
+	}
+	if len(config.KanbanColumns) < 2 {
+		return fmt.Errorf("error: kanban_columns needs at least two columns")
+	}
+	for ext, command := range config.Editors {
+		if !strings.HasPrefix(ext, ".") || strings.TrimSpace(command) == "" {
+			return fmt.Errorf("error: editors must map extensions like .md to commands")
+		}
+	}
+	for ext, command := range config.Formatters {
+		if !strings.HasPrefix(ext, ".") || strings.TrimSpace(command) == "" {
+			return fmt.Errorf("error: formatters must map extensions like .md to commands")
+		}
+	}
+	for event, command := range config.Hooks {
+		if !slices.Contains(hookEvents, event) || strings.TrimSpace(command) == "" {
+			return fmt.Errorf("error: hooks must map events (%s) to commands", strings.Join(hookEvents, ", "))
+		}
+	}
+	for _, uc := range config.UserCommands {
+		if _, err := parseKey(uc.Key); err != nil || strings.TrimSpace(uc.Command) == "" {
+			return fmt.Errorf("error: user_commands need a valid key and a command")
+		}
+	}
+	for _, p := range config.Previewers {
+		if _, err := filepath.Match(p.Pattern, ""); err != nil || p.Pattern == "" || strings.TrimSpace(p.Command) == "" {
+			return fmt.Errorf("error: previewers need a valid file pattern such as *.pdf and a command")
+		}
+	}
+	themeName := config.Theme
+	if themeName == autoThemeName {
+		themeName = defaultThemeName
+	}
+	if _, err := buildTheme(themeName, config.Colors); err != nil {
+		return err
+	}
+	if _, err := buildIcons(config.Icons, config.IconOverrides); err != nil {
+		return err
+	}
+	for _, template := range []string{config.StatusLine, config.VaultStatusLine} {
+		if err := validateStatusLine(template); err != nil {
+			return err
+		}
+	}
+	switch config.FolderCounts {
+	case folderCountsNone, folderCountsNotes, folderCountsAll:
+	default:
+		return fmt.Errorf("error: folder_counts must be %s, %s or %s", folderCountsNone, folderCountsNotes, folderCountsAll)
+	}
+	switch config.TreeColors {
+	case treeColorsNone, treeColorsAge, treeColorsType:
+	default:
+		return fmt.Errorf("error: tree_colors must be %s, %s or %s", treeColorsNone, treeColorsAge, treeColorsType)
+	}
+	if config.Share.Backend != "" {
+		if err := validateShare(config.Share); err != nil {
+			return err
+		}
+	}
+	switch config.SlugNames {
+	case slugNone, slugDashes, slugLowercase:
+	default:
+		return fmt.Errorf("error: slug_names must be %s, %s or %s", slugNone, slugDashes, slugLowercase)
+	}
+	if config.DefaultExtension != "" && (!strings.HasPrefix(config.DefaultExtension, ".") || strings.ContainsAny(config.DefaultExtension, "/\\ ")) {
+		return fmt.Errorf("error: default_extension must be an extension like .md")
+	}
+	switch config.TmuxEditor {
+	case tmuxEditorOff, tmuxEditorSplit, tmuxEditorWindow:
+	default:
+		return fmt.Errorf("error: tmux_editor must be %s, %s or %s", tmuxEditorOff, tmuxEditorSplit, tmuxEditorWindow)
+	}
+	if err := validateVaults(config.Vaults); err != nil {
+		return err
+	}
+	if config.TOCMinHeadings < 0 {
+		return fmt.Errorf("error: toc_min_headings can't be negative")
+	}
+	if config.LockTimeout < 0 {
+		return fmt.Errorf("error: lock_timeout can't be negative")
+	}
+	if config.VaultWarningMB < 0 || config.AttachmentsWarningMB < 0 {
+		return fmt.Errorf("error: vault_warning_mb and attachments_warning_mb can't be negative")
+	}
+	if config.BackupRetentionDays < 0 || config.MaxBackups < 0 {
+		return fmt.Errorf("error: backup_retention_days and max_backups can't be negative")
+	}
+	if config.Inbox == "" || filepath.IsAbs(config.Inbox) || strings.HasPrefix(filepath.Clean(config.Inbox), "..") {
+		return fmt.Errorf("error: inbox must be a note inside the notes directory")
+	}
+	if filepath.IsAbs(config.LockedDir) || strings.HasPrefix(filepath.Clean(config.LockedDir), "..") {
+		return fmt.Errorf("error: locked_dir must be a path inside the notes directory")
+	}
+	switch config.SearchBackend {
+	case SearchBackendAuto, SearchBackendBuiltin, SearchBackendIndex, SearchBackendRipgrep, SearchBackendAg:
+	default:
+		return fmt.Errorf("error: search_backend must be %s, %s, %s, %s or %s", SearchBackendAuto, SearchBackendBuiltin, SearchBackendIndex, SearchBackendRipgrep, SearchBackendAg)
+	}
+	return nil
+}
+
+// Load the persisted state, starting fresh when it's missing or unreadable
+func loadState(path string) State {
+	var state State
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return state
+	}
+	if err := json.Unmarshal(content, &state); err != nil {
+		logger.Printf("ignoring unparsable state %s: %v", path, err)
+		return State{}
+	}
+	return state
+}
+
+func saveState(path string, state State) error {
+	content, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding state: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return fmt.Errorf("error creating state directory %s: %v", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		return fmt.Errorf("error writing state %s: %v", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Notes larger than this are compared by time and size only, without keeping
+// a copy to show in the diff
+const maxStampContent = 1 << 20
+
+// FileStamp is what a file looked like when it was selected or opened, to
+// notice when another program changes it in the meantime
+type FileStamp struct {
+	Path    string
+	ModTime time.Time
+	Size    int64
+	Content []byte
+}
+
+func stampFile(path string) FileStamp {
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return FileStamp{}
+	}
+	stamp := FileStamp{Path: path, ModTime: info.ModTime(), Size: info.Size()}
+	if info.Size() <= maxStampContent {
+		stamp.Content, _ = readNote(path)
+	}
+	return stamp
+}
+
+func (stamp FileStamp) changed() bool {
+	if stamp.Path == "" {
+		return false
+	}
+	info, err := os.Stat(stamp.Path)
+	if err != nil {
+		return false
+	}
+	return !info.ModTime().Equal(stamp.ModTime) || info.Size() != stamp.Size
+}
+
+// Remember the selected file unless it's the one already remembered
+func (app *App) stampSelection() {
+	item := app.selectedItem()
+	if item.Path == app.selectionStamp.Path {
+		return
+	}
+	app.restampSelection()
+}
+
+func (app *App) restampSelection() {
+	app.selectionStamp = FileStamp{}
+	if item := app.selectedItem(); isFile(item.Path) {
+		app.selectionStamp = stampFile(item.Path)
+	}
+}
+
+// Ask before acting on a file that changed on disk since it was selected,
+// offering to compare the remembered version with the current one
+func (app *App) confirmUnchanged(path string) bool {
+	stamp := app.selectionStamp
+	if stamp.Path != path || !stamp.changed() {
+		return true
+	}
+	current, err := readNote(path)
+	if err != nil {
+		current = nil
+	}
+	prompt := fmt.Sprintf("%s changed on disk since it was selected: (c)ontinue, (d)iff, (a)bort: ", filepath.Base(path))
+	if !app.resolveConflict(prompt, "cda", stamp.Content, current, path) {
+		return false
+	}
+	app.restampSelection()
+	return true
+}
+
+// Ask before saving edited content over a file another program changed while
+// it was being edited
+func (app *App) confirmOverwrite(stamp FileStamp, edited []byte) bool {
+	if !stamp.changed() {
+		return true
+	}
+	current, _ := readNote(stamp.Path)
+	if bytes.Equal(current, edited) {
+		return true
+	}
+	prompt := fmt.Sprintf("%s changed on disk while editing: (o)verwrite, (d)iff, (c)ancel: ", filepath.Base(stamp.Path))
+	return app.resolveConflict(prompt, "odc", current, edited, stamp.Path)
+}
+
+// Prompt with choices made of proceed, diff and give up keys in that order,
+// showing the two versions in the diff tool until the user decides
+func (app *App) resolveConflict(prompt string, choices string, left []byte, right []byte, path string) bool {
+	for {
+		switch getChoice(prompt, choices, app.screen) {
+		case rune(choices[0]):
+			return true
+		case rune(choices[1]):
+			if err := app.diffVersions(left, right, path); err != nil {
+				handleError(err, app.screen)
+			}
+		default:
+			return false
+		}
+	}
+}
+
+// Compare two versions of a note in the diff tool through copies in a private
+// temporary directory, so encrypted notes aren't written out elsewhere
+func (app *App) diffVersions(left []byte, right []byte, path string) error {
+	dir, err := os.MkdirTemp("", "notes-")
+	if err != nil {
+		return fmt.Errorf("error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Base(plainPath(path))
+	leftPath := filepath.Join(dir, "before-"+name)
+	rightPath := filepath.Join(dir, "after-"+name)
+	if err := os.WriteFile(leftPath, left, 0o600); err != nil {
+		return fmt.Errorf("error writing %s: %v", leftPath, err)
+	}
+	if err := os.WriteFile(rightPath, right, 0o600); err != nil {
+		return fmt.Errorf("error writing %s: %v", rightPath, err)
+	}
+	return app.runDiffTool(leftPath, rightPath)
+}
+
+// Where edits that weren't saved over a changed note are kept instead, e.g.
+// note (conflict).md.age next to note.md.age
+func conflictPath(path string) string {
+	plain := plainPath(path)
+	ext := filepath.Ext(plain)
+	return uniquePath(strings.TrimSuffix(plain, ext) + " (conflict)" + ext + path[len(plain):])
+}
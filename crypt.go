@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	ageExt = ".age"
+	gpgExt = ".gpg"
+)
+
+// Shown after encrypted notes in the tree when no icon marks them
+const lockMarker = " 🔒"
+
+// Keys for encrypted notes, set from the config at startup so every place
+// reading notes can decrypt them
+var encryption struct {
+	ageIdentity   string
+	ageRecipients []string
+	gpgRecipients []string
+	// Notes under lockedDir are encrypted with the vault passphrase, which is
+	// nil while the vault is locked
+	lockedDir  string
+	passphrase []byte
+}
+
+type decryptedNote struct {
+	modTime time.Time
+	content []byte
+}
+
+// Decrypted contents are kept in memory only, so previews don't run the
+// decryption tool on every redraw
+var decryptedNotes = make(map[string]decryptedNote)
+
+func isEncryptedFile(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ageExt || ext == gpgExt
+}
+
+// The path without the encryption extension, e.g. secret.md for secret.md.age
+func plainPath(path string) string {
+	if isEncryptedFile(path) {
+		return strings.TrimSuffix(path, filepath.Ext(path))
+	}
+	return path
+}
+
+// Read a note, decrypting it when it's encrypted
+func readNote(path string) ([]byte, error) {
+	if !isEncryptedFile(path) {
+		return os.ReadFile(path)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if cached, ok := decryptedNotes[path]; ok && cached.modTime.Equal(info.ModTime()) {
+		return cached.content, nil
+	}
+	content, err := decryptFile(path)
+	if err != nil {
+		return nil, err
+	}
+	decryptedNotes[path] = decryptedNote{info.ModTime(), content}
+	return content, nil
+}
+
+func runCrypt(cmd *exec.Cmd, input []byte) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if _, lookErr := exec.LookPath(cmd.Args[0]); lookErr != nil {
+			return nil, userErr{fmt.Sprintf("%s isn't installed", cmd.Args[0])}
+		}
+		return nil, fmt.Errorf("error running %s: %v: %s", cmd.Args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+func decryptFile(path string) ([]byte, error) {
+	if inLockedDir(path) {
+		if encryption.passphrase == nil {
+			return nil, userErr{"The vault is locked"}
+		}
+		return runGPGWithPassphrase(nil, "--decrypt", path)
+	}
+	if strings.ToLower(filepath.Ext(path)) == gpgExt {
+		return runCrypt(exec.Command("gpg", "--batch", "--quiet", "--decrypt", path), nil)
+	}
+	if encryption.ageIdentity == "" {
+		return nil, userErr{"Set age_identity in the config to decrypt .age notes"}
+	}
+	return runCrypt(exec.Command("age", "--decrypt", "--identity", expandHome(encryption.ageIdentity), path), nil)
+}
+
+// Encrypt content to path with the tool matching its extension and the
+// configured recipients
+func encryptFile(path string, content []byte) error {
+	if inLockedDir(path) {
+		if encryption.passphrase == nil {
+			return userErr{"The vault is locked"}
+		}
+		_, err := runGPGWithPassphrase(content, "--yes", "--symmetric", "--output", path)
+		delete(decryptedNotes, path)
+		return err
+	}
+	var cmd *exec.Cmd
+	if strings.ToLower(filepath.Ext(path)) == gpgExt {
+		if len(encryption.gpgRecipients) == 0 {
+			return userErr{"Set gpg_recipients in the config to encrypt .gpg notes"}
+		}
+		args := []string{"--batch", "--yes", "--encrypt", "--output", path}
+		for _, recipient := range encryption.gpgRecipients {
+			args = append(args, "--recipient", recipient)
+		}
+		cmd = exec.Command("gpg", args...)
+	} else {
+		if len(encryption.ageRecipients) == 0 {
+			return userErr{"Set age_recipients in the config to encrypt .age notes"}
+		}
+		args := []string{"--encrypt", "--output", path}
+		for _, recipient := range encryption.ageRecipients {
+			args = append(args, "--recipient", recipient)
+		}
+		cmd = exec.Command("age", args...)
+	}
+	_, err := runCrypt(cmd, content)
+	delete(decryptedNotes, path)
+	return err
+}
+
+func expandHome(path string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	return path
+}
+
+// Edit an encrypted note through a decrypted copy in a private temporary
+// directory, re-encrypting it when vim changed it. Vim runs without swap,
+// backup and viminfo files so no plaintext is left behind.
+func (app *App) editEncrypted(path string, args ...string) error {
+	content, err := readNote(path)
+	if err != nil {
+		return err
+	}
+	stamp := stampFile(path)
+	if !app.hasTool("vim") {
+		// The built-in editor keeps the plaintext in memory only
+		return app.editContent(path, content, func(edited []byte) error {
+			if !app.confirmOverwrite(stamp, edited) {
+				return userErr{"Not saved"}
+			}
+			if err := encryptFile(path, edited); err != nil {
+				return err
+			}
+			stamp = stampFile(path)
+			return nil
+		}, args...)
+	}
+	dir, err := os.MkdirTemp("", "notes-")
+	if err != nil {
+		return fmt.Errorf("error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	tmp := filepath.Join(dir, filepath.Base(plainPath(path)))
+	if err := os.WriteFile(tmp, content, 0o600); err != nil {
+		return fmt.Errorf("error writing %s: %v", tmp, err)
+	}
+
+	args = append([]string{"-n", "-i", "NONE", "+set nobackup nowritebackup noundofile"}, args...)
+	screen, err := openVim(tmp, app.screen, args...)
+	if screen != nil {
+		app.screen = screen
+	}
+	if err != nil {
+		resetScreen(app.screen)
+		exitWithError(err)
+	}
+	app.updateTitle()
+	defer app.rebuild()
+
+	edited, err := os.ReadFile(tmp)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %v", tmp, err)
+	}
+	if bytes.Equal(edited, content) {
+		return nil
+	}
+	if !app.confirmOverwrite(stamp, edited) {
+		copyPath := conflictPath(path)
+		if err := encryptFile(copyPath, edited); err != nil {
+			return err
+		}
+		return userErr{fmt.Sprintf("Your changes were saved to %s", filepath.Base(copyPath))}
+	}
+	return encryptFile(path, edited)
+}
+
+// Encrypt a plain note to <name>.age, or decrypt an encrypted one back to its
+// plain name, removing the original once the new file is written
+func (app *App) toggleEncryption() error {
+	item := app.selectedItem()
+	if !isFile(item.Path) {
+		return nil
+	}
+	source := item.Path
+	var target, prompt string
+	if isEncryptedFile(source) {
+		target = plainPath(source)
+		prompt = fmt.Sprintf("Decrypt to %s? (y/N): ", filepath.Base(target))
+	} else {
+		target = source + ageExt
+		if len(encryption.ageRecipients) == 0 && len(encryption.gpgRecipients) > 0 {
+			target = source + gpgExt
+		}
+		prompt = fmt.Sprintf("Encrypt to %s? (y/N): ", filepath.Base(target))
+	}
+	if isFile(target) {
+		return userErr{fmt.Sprintf("%s already exists", filepath.Base(target))}
+	}
+	if !getConfirmation(prompt, app.screen) {
+		return nil
+	}
+
+	content, err := readNote(source)
+	if err != nil {
+		return err
+	}
+	app.recordOperation("encrypt", source)
+	defer app.rebuild()
+	if isEncryptedFile(source) {
+		err = os.WriteFile(target, content, 0o600)
+	} else {
+		err = encryptFile(target, content)
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(source); err != nil {
+		return fmt.Errorf("error removing %s: %v", source, err)
+	}
+	app.selectPath(target)
+	return nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const dailyDirName = "journal"
+
+// Open the daily note for a date expression such as tomorrow or next mon,
+// creating it when it doesn't exist yet
+func runDaily(app *App, args []string) error {
+	date, err := parseDateExpr(strings.Join(args, " "), time.Now())
+	if err != nil {
+		return err
+	}
+	name := date.Format(dateLayout)
+	path := filepath.Join(app.rootItem.Path, dailyDirName, name+".md")
+
+	if !isFile(path) {
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			return fmt.Errorf("error creating directory %s: %v", filepath.Dir(path), err)
+		}
+		if err := os.WriteFile(path, []byte("# "+name+"\n\n"), 0o644); err != nil {
+			return fmt.Errorf("error creating daily note %s: %v", path, err)
+		}
+		app.fireHook(hookNoteCreated, path, "")
+	}
+
+	app.recordOperation("daily", path)
+	if err := app.openEditor(path); err != nil {
+		return err
+	}
+	app.selectPath(path)
+	return nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+
+var (
+	relativeDateRegex = regexp.MustCompile(`^([+-]\d+)([dwmy])$`)
+	weekdays          = map[string]time.Weekday{
+		"sun": time.Sunday, "sunday": time.Sunday,
+		"mon": time.Monday, "monday": time.Monday,
+		"tue": time.Tuesday, "tuesday": time.Tuesday,
+		"wed": time.Wednesday, "wednesday": time.Wednesday,
+		"thu": time.Thursday, "thursday": time.Thursday,
+		"fri": time.Friday, "friday": time.Friday,
+		"sat": time.Saturday, "saturday": time.Saturday,
+	}
+)
+
+// Parse a date expression relative to now: today, tomorrow, yesterday, a weekday
+// name optionally preceded by next or last, offsets like +3d, -2w, +1m, +1y, or
+// an ISO date
+func parseDateExpr(expr string, now time.Time) (time.Time, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	expr = strings.ToLower(strings.TrimSpace(expr))
+
+	switch expr {
+	case "", "today":
+		return today, nil
+	case "tomorrow":
+		return today.AddDate(0, 0, 1), nil
+	case "yesterday":
+		return today.AddDate(0, 0, -1), nil
+	}
+
+	if m := relativeDateRegex.FindStringSubmatch(expr); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		switch m[2] {
+		case "d":
+			return today.AddDate(0, 0, n), nil
+		case "w":
+			return today.AddDate(0, 0, 7*n), nil
+		case "m":
+			return today.AddDate(0, n, 0), nil
+		case "y":
+			return today.AddDate(n, 0, 0), nil
+		}
+	}
+
+	fields := strings.Fields(expr)
+	direction := "next"
+	if len(fields) == 2 && (fields[0] == "next" || fields[0] == "last") {
+		direction = fields[0]
+		fields = fields[1:]
+	}
+	if len(fields) == 1 {
+		if weekday, ok := weekdays[fields[0]]; ok {
+			if direction == "last" {
+				days := (int(today.Weekday()) - int(weekday) + 7) % 7
+				if days == 0 {
+					days = 7
+				}
+				return today.AddDate(0, 0, -days), nil
+			}
+			days := (int(weekday) - int(today.Weekday()) + 7) % 7
+			if days == 0 {
+				days = 7
+			}
+			return today.AddDate(0, 0, days), nil
+		}
+	}
+
+	if date, err := time.ParseInLocation(dateLayout, expr, now.Location()); err == nil {
+		return date, nil
+	}
+	return time.Time{}, userErr{fmt.Sprintf("Unknown date: %s", expr)}
+}
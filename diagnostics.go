@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+)
+
+func runVersionSubcommand(args []string) error {
+	flags := flag.NewFlagSet("version", flag.ContinueOnError)
+	verbose := flags.Bool("verbose", false, "Print build and environment diagnostics")
+	d := flags.String("d", "", "Path to directory with notes")
+	asJSON := flags.Bool("json", false, "Print the version and build as a JSON object")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *asJSON {
+		return printJSON(versionJSON())
+	}
+	if !*verbose {
+		fmt.Printf("notes %s\n", version)
+		return nil
+	}
+	writeDiagnostics(os.Stdout, *d)
+	return nil
+}
+
+func writeDiagnostics(w io.Writer, dir string) {
+	fmt.Fprintf(w, "notes %s\n", version)
+	fmt.Fprintf(w, "commit: %s\n", buildRevision())
+	fmt.Fprintf(w, "go: %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(w, "TERM: %s\n", os.Getenv("TERM"))
+	fmt.Fprintf(w, "truecolor: %s\n", yesNo(supportsTruecolor()))
+	fmt.Fprintf(w, "sixel: %s\n", sixelSupport())
+	fmt.Fprintf(w, "tmux: %s\n", yesNo(os.Getenv("TMUX") != ""))
+	writeToolDiagnostics(w, detectTools())
+
+	configPath := configFilePath()
+	if isFile(configPath) {
+		fmt.Fprintf(w, "config: %s\n", configPath)
+	} else {
+		fmt.Fprintf(w, "config: %s (not found, using defaults)\n", configPath)
+	}
+	fmt.Fprintf(w, "state: %s\n", stateFilePath())
+	fmt.Fprintf(w, "log: %s\n", logFilePath())
+	if dir == "" {
+		fmt.Fprintln(w, "vault: none (pass -d)")
+	} else {
+		files, dirs, size := vaultStats(dir)
+		fmt.Fprintf(w, "vault: %s (%d files, %d directories, %d bytes)\n", dir, files, dirs, size)
+	}
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+// Detect truecolor the same way tcell does, from COLORTERM or a direct-color TERM
+func supportsTruecolor() bool {
+	colorTerm := strings.ToLower(os.Getenv("COLORTERM"))
+	return colorTerm == "truecolor" || colorTerm == "24bit" || strings.HasSuffix(os.Getenv("TERM"), "-direct")
+}
+
+// Guess sixel support from the terminal identity, since querying the terminal
+// would need it in raw mode
+func sixelSupport() string {
+	term := strings.ToLower(os.Getenv("TERM"))
+	program := strings.ToLower(os.Getenv("TERM_PROGRAM"))
+	for _, known := range []string{"foot", "mlterm", "contour", "wezterm", "mintty", "yaft"} {
+		if strings.Contains(term, known) || strings.Contains(program, known) {
+			return "likely"
+		}
+	}
+	return "unknown"
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Build the configured diff tool command, substituting {left} and {right} or
+// appending both paths when the command has no placeholders
+func diffToolCommand(tool string, left string, right string) (*exec.Cmd, error) {
+	fields := strings.Fields(tool)
+	if len(fields) == 0 {
+		return nil, userErr{"No diff tool configured"}
+	}
+	if !strings.Contains(tool, "{left}") && !strings.Contains(tool, "{right}") {
+		fields = append(fields, left, right)
+	}
+	args := make([]string, len(fields))
+	for i, field := range fields {
+		field = strings.ReplaceAll(field, "{left}", left)
+		args[i] = strings.ReplaceAll(field, "{right}", right)
+	}
+	if _, err := exec.LookPath(args[0]); err != nil {
+		return nil, userErr{fmt.Sprintf("Diff tool not found: %s", args[0])}
+	}
+	return exec.Command(args[0], args[1:]...), nil
+}
+
+// Compare two files in the external diff tool with the screen suspended, then
+// re-scan the tree since merge tools may have changed either file
+func (app *App) runDiffTool(left string, right string) error {
+	cmd, err := diffToolCommand(app.config.DiffTool, left, right)
+	if err != nil {
+		return err
+	}
+	app.recordOperation("diff", left+" "+right)
+	screen, err := runSuspended(cmd, app.screen)
+	if screen != nil {
+		app.screen = screen
+	}
+	app.updateTitle()
+	app.rebuild()
+
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return userErr{fmt.Sprintf("Diff tool failed: %v", err)}
+	}
+	return nil
+}
+
+func runDiff(app *App, args []string) error {
+	if len(args) != 1 {
+		return userErr{"Usage: diff <path>"}
+	}
+	item := app.selectedItem()
+	if !isFile(item.Path) {
+		return userErr{"Select a file to diff"}
+	}
+	other, err := resolveAndValidatePath(args[0], app.rootItem.Path)
+	if err != nil {
+		return err
+	}
+	if !isFile(other) {
+		return userErr{fmt.Sprintf("Not a file: %s", args[0])}
+	}
+	return app.runDiffTool(item.Path, other)
+}
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"fmt"
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	editorTabWidth = 4
+	// Name of the built-in editor in the editors config
+	editorBuiltin = "builtin"
+)
+
+// Vim arguments placing the cursor, as passed to openEditor: +12 or
+// +call cursor(12, 3)
+var editorCursorArgRegex = regexp.MustCompile(`^\+(?:call cursor\((\d+),\s*(\d+)\)|(\d+))$`)
+
+// Editor is the built-in editor used when vim isn't installed. It only knows
+// typing, deleting, moving around and saving, enough to fix a note in a
+// minimal container or a recovery shell.
+type Editor struct {
+	name     string
+	lines    [][]rune
+	crlf     bool
+	row      int
+	col      int
+	top      int
+	left     int
+	modified bool
+	message  string
+	save     func(content []byte) error
+}
+
+func newEditor(name string, content []byte, save func(content []byte) error) *Editor {
+	text := string(content)
+	e := &Editor{name: name, crlf: strings.Contains(text, "\r\n"), save: save}
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		e.lines = append(e.lines, []rune(line))
+	}
+	return e
+}
+
+func (e *Editor) content() []byte {
+	lines := make([]string, len(e.lines))
+	for i, line := range e.lines {
+		lines[i] = string(line)
+	}
+	separator := "\n"
+	if e.crlf {
+		separator = "\r\n"
+	}
+	return []byte(strings.Join(lines, separator))
+}
+
+// Move the cursor to the position given by vim style arguments, 1-based
+func (e *Editor) applyCursorArgs(args []string) {
+	for _, arg := range args {
+		m := editorCursorArgRegex.FindStringSubmatch(arg)
+		if m == nil {
+			continue
+		}
+		line, col := m[3], "1"
+		if m[1] != "" {
+			line, col = m[1], m[2]
+		}
+		row, _ := strconv.Atoi(line)
+		column, _ := strconv.Atoi(col)
+		e.row = max(min(row-1, len(e.lines)-1), 0)
+		e.col = max(min(column-1, len(e.lines[e.row])), 0)
+	}
+}
+
+// The screen column of rune index col in line, expanding tabs
+func visualColumn(line []rune, col int) int {
+	x := 0
+	for _, r := range line[:col] {
+		if r == '\t' {
+			x += editorTabWidth - x%editorTabWidth
+		} else {
+			x += runewidth.RuneWidth(r)
+		}
+	}
+	return x
+}
+
+func (e *Editor) moveTo(row int, col int) {
+	e.row = max(min(row, len(e.lines)-1), 0)
+	e.col = max(min(col, len(e.lines[e.row])), 0)
+}
+
+func (e *Editor) insert(r rune) {
+	line := e.lines[e.row]
+	e.lines[e.row] = append(line[:e.col:e.col], append([]rune{r}, line[e.col:]...)...)
+	e.col++
+	e.modified = true
+}
+
+func (e *Editor) splitLine() {
+	line := e.lines[e.row]
+	rest := append([]rune{}, line[e.col:]...)
+	e.lines[e.row] = line[:e.col:e.col]
+	e.lines = append(e.lines[:e.row+1], append([][]rune{rest}, e.lines[e.row+1:]...)...)
+	e.row++
+	e.col = 0
+	e.modified = true
+}
+
+func (e *Editor) backspace() {
+	switch {
+	case e.col > 0:
+		line := e.lines[e.row]
+		e.lines[e.row] = append(line[:e.col-1:e.col-1], line[e.col:]...)
+		e.col--
+	case e.row > 0:
+		e.col = len(e.lines[e.row-1])
+		e.lines[e.row-1] = append(e.lines[e.row-1], e.lines[e.row]...)
+		e.lines = append(e.lines[:e.row], e.lines[e.row+1:]...)
+		e.row--
+	default:
+		return
+	}
+	e.modified = true
+}
+
+func (e *Editor) delete() {
+	switch {
+	case e.col < len(e.lines[e.row]):
+		line := e.lines[e.row]
+		e.lines[e.row] = append(line[:e.col:e.col], line[e.col+1:]...)
+	case e.row < len(e.lines)-1:
+		e.lines[e.row] = append(e.lines[e.row], e.lines[e.row+1]...)
+		e.lines = append(e.lines[:e.row+1], e.lines[e.row+2:]...)
+	default:
+		return
+	}
+	e.modified = true
+}
+
+func (e *Editor) write() {
+	if err := e.save(e.content()); err != nil {
+		e.message = err.Error()
+		return
+	}
+	e.modified = false
+	e.message = "Saved " + e.name
+}
+
+func (e *Editor) render(screen tcell.Screen) {
+	screen.Clear()
+	width, height := screen.Size()
+	area := height - 2
+	gutter := len(strconv.Itoa(len(e.lines))) + 1
+	textWidth := max(width-gutter-1, 1)
+
+	if e.row < e.top {
+		e.top = e.row
+	} else if e.row >= e.top+area {
+		e.top = e.row - area + 1
+	}
+	cursorX := visualColumn(e.lines[e.row], e.col)
+	if cursorX < e.left {
+		e.left = cursorX
+	} else if cursorX >= e.left+textWidth {
+		e.left = cursorX - textWidth + 1
+	}
+
+	title := " " + e.name
+	if e.modified {
+		title += " [+]"
+	}
+	renderText(0, 0, runewidth.FillRight(title, width), theme.FooterLabel, screen)
+	for y := 0; y < area && e.top+y < len(e.lines); y++ {
+		row := e.top + y
+		renderText(0, y+1, fmt.Sprintf("%*d ", gutter-1, row+1), tcell.StyleDefault.Dim(true), screen)
+		x := 0
+		for _, r := range e.lines[row] {
+			w := runewidth.RuneWidth(r)
+			if r == '\t' {
+				w = editorTabWidth - x%editorTabWidth
+			}
+			if x >= e.left && x+w <= e.left+textWidth && r != '\t' {
+				screen.SetContent(gutter+x-e.left, y+1, r, nil, tcell.StyleDefault)
+			}
+			x += w
+		}
+	}
+
+	status := "Ctrl-S: Save | Esc: Close"
+	if e.message != "" {
+		status = e.message
+	}
+	position := fmt.Sprintf("%d:%d ", e.row+1, e.col+1)
+	renderText(0, height-1, status, tcell.StyleDefault, screen)
+	renderText(width-len(position), height-1, position, tcell.StyleDefault.Dim(true), screen)
+	screen.ShowCursor(gutter+cursorX-e.left, e.row-e.top+1)
+	screen.Show()
+}
+
+// Run the editor until it's closed, asking whether to save unsaved changes
+func (e *Editor) run(screen tcell.Screen) {
+	defer screen.HideCursor()
+	for {
+		e.render(screen)
+		var ev *tcell.EventKey
+		switch event := screen.PollEvent().(type) {
+		case *tcell.EventResize:
+			screen.Sync()
+			continue
+		case *tcell.EventKey:
+			ev = event
+		default:
+			continue
+		}
+		e.message = ""
+		_, height := screen.Size()
+		switch ev.Key() {
+		case tcell.KeyEscape, tcell.KeyCtrlQ:
+			if !e.modified {
+				return
+			}
+			switch getChoice("Save changes? (y)es, (n)o, (c)ancel: ", "ync", screen) {
+			case 'y':
+				e.write()
+				if !e.modified {
+					return
+				}
+			case 'n':
+				return
+			}
+		case tcell.KeyCtrlS:
+			e.write()
+		case tcell.KeyUp:
+			e.moveTo(e.row-1, e.col)
+		case tcell.KeyDown:
+			e.moveTo(e.row+1, e.col)
+		case tcell.KeyLeft:
+			if e.col == 0 && e.row > 0 {
+				e.moveTo(e.row-1, len(e.lines[e.row-1]))
+			} else {
+				e.moveTo(e.row, e.col-1)
+			}
+		case tcell.KeyRight:
+			if e.col == len(e.lines[e.row]) && e.row < len(e.lines)-1 {
+				e.moveTo(e.row+1, 0)
+			} else {
+				e.moveTo(e.row, e.col+1)
+			}
+		case tcell.KeyHome, tcell.KeyCtrlA:
+			e.col = 0
+		case tcell.KeyEnd, tcell.KeyCtrlE:
+			e.col = len(e.lines[e.row])
+		case tcell.KeyPgUp:
+			e.moveTo(e.row-(height-2), e.col)
+		case tcell.KeyPgDn:
+			e.moveTo(e.row+(height-2), e.col)
+		case tcell.KeyEnter:
+			e.splitLine()
+		case tcell.KeyBackspace, tcell.KeyBackspace2:
+			e.backspace()
+		case tcell.KeyDelete:
+			e.delete()
+		case tcell.KeyTab:
+			e.insert('\t')
+		case tcell.KeyRune:
+			e.insert(ev.Rune())
+		}
+	}
+}
+
+// Edit a file in the built-in editor, creating it on the first save when it
+// doesn't exist yet
+func (app *App) editBuiltin(path string, args ...string) error {
+	content, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error reading %s: %v", path, err)
+	}
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode()
+	}
+	stamp := stampFile(path)
+	return app.editContent(path, content, func(edited []byte) error {
+		if !app.confirmOverwrite(stamp, edited) {
+			return userErr{"Not saved"}
+		}
+		if err := os.WriteFile(path, edited, mode); err != nil {
+			return fmt.Errorf("error writing %s: %v", path, err)
+		}
+		stamp = stampFile(path)
+		return nil
+	}, args...)
+}
+
+func (app *App) editContent(path string, content []byte, save func(content []byte) error, args ...string) error {
+	editor := newEditor(app.relativePath(plainPath(path)), content, save)
+	editor.applyCursorArgs(args)
+	editor.run(app.screen)
+	app.rebuild()
+	return nil
+}
+
+// The editor configured for the file's extension. Encrypted notes always use
+// vim or the built-in editor, which don't leave plaintext copies behind.
+func (app *App) editorFor(path string) (string, bool) {
+	for ext, command := range app.config.Editors {
+		if strings.EqualFold(ext, filepath.Ext(path)) {
+			return command, true
+		}
+	}
+	return "", false
+}
+
+// Build an editor command, substituting {file} or appending the path when the
+// command has no placeholder. Vim style cursor arguments are only passed to
+// editors that understand them.
+func editorCommand(command string, path string, args []string) *exec.Cmd {
+	fields := strings.Fields(command)
+	var cmdArgs []string
+	switch filepath.Base(fields[0]) {
+	case "vim", "nvim", "gvim", "vi":
+		cmdArgs = append(cmdArgs, args...)
+	}
+	placeholder := false
+	for _, field := range fields[1:] {
+		if strings.Contains(field, "{file}") {
+			placeholder = true
+		}
+		cmdArgs = append(cmdArgs, strings.ReplaceAll(field, "{file}", path))
+	}
+	if !placeholder {
+		cmdArgs = append(cmdArgs, path)
+	}
+	return exec.Command(fields[0], cmdArgs...)
+}
+
+func (app *App) runEditorCommand(command string, path string, args ...string) error {
+	if command == editorBuiltin {
+		return app.editBuiltin(path, args...)
+	}
+	cmd := editorCommand(command, path, args)
+	if _, err := exec.LookPath(cmd.Args[0]); err != nil {
+		return userErr{fmt.Sprintf("Editor not found: %s", cmd.Args[0])}
+	}
+	screen, err := runSuspended(cmd, app.screen)
+	if screen == nil {
+		exitWithError(err)
+	}
+	app.screen = screen
+	app.updateTitle()
+	app.rebuild()
+	if err != nil {
+		return userErr{fmt.Sprintf("%s failed: %v", cmd.Args[0], err)}
+	}
+	return nil
+}
@@ -21,7 +21,8 @@ func (e userErr) Error() string {
 }
 
 func handleError(err error, screen tcell.Screen) {
-	var userErr *userErr
+	logger.Printf("error: %v", err)
+	var userErr userErr
 	if errors.As(err, &userErr) {
 		renderError(err.Error(), screen)
 	} else {
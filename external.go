@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+)
+
+// The system command opening a file in its default application
+func systemOpenCommand(path string) *exec.Cmd {
+	switch runtime.GOOS {
+	case "darwin":
+		return exec.Command("open", path)
+	case "windows":
+		return exec.Command("cmd", "/c", "start", "", path)
+	}
+	return exec.Command("xdg-open", path)
+}
+
+// Open the selected file with the system handler, e.g. a PDF viewer. The
+// application runs detached so the tree stays usable.
+func (app *App) openExternally() error {
+	item := app.selectedItem()
+	if !isFile(item.Path) {
+		return nil
+	}
+	cmd := systemOpenCommand(item.Path)
+	if _, err := exec.LookPath(cmd.Args[0]); err != nil {
+		return userErr{fmt.Sprintf("%s not found, can't open files externally", cmd.Args[0])}
+	}
+	app.recordOperation("open", item.Path)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("error running %s: %v", cmd.Args[0], err)
+	}
+	go func() {
+		_ = cmd.Wait()
+	}()
+	return nil
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const vaultConfigFileName = ".notes.json"
+
+// VaultConfig is read from .notes.json in the root of the notes directory and
+// holds settings that belong to a particular vault
+type VaultConfig struct {
+	Filter         map[string]string `json:"filter"`
+	SortField      string            `json:"sort_field"`
+	SortDescending bool              `json:"sort_descending"`
+}
+
+// FieldFilter keeps the notes whose frontmatter field has the given value
+type FieldFilter struct {
+	Key   string
+	Value string
+}
+
+func loadVaultConfig(dir string) (VaultConfig, error) {
+	var config VaultConfig
+	path := filepath.Join(dir, vaultConfigFileName)
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return config, fmt.Errorf("error reading vault config %s: %v", path, err)
+	}
+	if err := json.Unmarshal(content, &config); err != nil {
+		return config, fmt.Errorf("error parsing vault config %s: %v", path, err)
+	}
+	return config, nil
+}
+
+func (config VaultConfig) fieldFilters() []FieldFilter {
+	var filters []FieldFilter
+	for key, value := range config.Filter {
+		filters = append(filters, FieldFilter{key, value})
+	}
+	sort.Slice(filters, func(i, j int) bool { return filters[i].Key < filters[j].Key })
+	return filters
+}
+
+// A scalar field matches when equal to the value, a list field when it
+// contains it, ignoring case either way
+func (filter FieldFilter) matches(item TreeItem) bool {
+	for _, value := range frontmatterList(item.Fields, filter.Key) {
+		if strings.EqualFold(value, filter.Value) {
+			return true
+		}
+	}
+	return false
+}
+
+func (filter FieldFilter) String() string {
+	return filter.Key + "=" + filter.Value
+}
+
+// Read a date from the start of a frontmatter field, so both 2024-06-30 and
+// 2024-06-30T10:00:00Z are accepted
+func fieldDate(item TreeItem, field string) (time.Time, bool) {
+	value := frontmatterString(item.Fields, field)
+	if len(value) < len(dateLayout) {
+		return time.Time{}, false
+	}
+	date, err := time.Parse(dateLayout, value[:len(dateLayout)])
+	return date, err == nil
+}
+
+// Order the notes of every directory by a frontmatter date field. Items
+// without the date keep their order after the dated ones.
+func sortTreeByField(item TreeItem, field string, descending bool) TreeItem {
+	for i := range item.Children {
+		item.Children[i] = sortTreeByField(item.Children[i], field, descending)
+	}
+	sort.SliceStable(item.Children, func(i, j int) bool {
+		a, aok := fieldDate(item.Children[i], field)
+		b, bok := fieldDate(item.Children[j], field)
+		if !aok || !bok {
+			return aok && !bok
+		}
+		if descending {
+			return a.After(b)
+		}
+		return a.Before(b)
+	})
+	for i := range item.Children {
+		item.Children[i].IsLast = i == len(item.Children)-1
+	}
+	return item
+}
+
+func runFilterField(app *App, args []string) error {
+	if len(args) == 0 {
+		app.fieldFilters = nil
+		app.rebuild()
+		return nil
+	}
+	// Accept status draft, status: draft and status=draft
+	input := strings.Join(args, " ")
+	i := strings.IndexAny(input, "=: ")
+	if i <= 0 {
+		return userErr{"Usage: filter-field [key value]"}
+	}
+	key, value := input[:i], strings.TrimSpace(strings.TrimLeft(input[i:], "=: "))
+	if value == "" {
+		return userErr{"Usage: filter-field [key value]"}
+	}
+	app.fieldFilters = append(app.fieldFilters, FieldFilter{key, value})
+	app.currentSelection = 0
+	app.rebuild()
+	return nil
+}
+
+func runSortField(app *App, args []string) error {
+	switch len(args) {
+	case 0:
+		app.sortField = ""
+	case 1, 2:
+		if len(args) == 2 && args[1] != "asc" && args[1] != "desc" {
+			return userErr{"Usage: sort-field [field [asc|desc]]"}
+		}
+		app.sortField = args[0]
+		app.sortDescending = len(args) == 2 && args[1] == "desc"
+	default:
+		return userErr{"Usage: sort-field [field [asc|desc]]"}
+	}
+	app.rebuild()
+	return nil
+}
+
+// Describe the active tree filters and marks for the footer
+func (app *App) treeStatus() string {
+	var filters []string
+	if app.labelFilter != "" {
+		filters = append(filters, app.labelFilter)
+	}
+	if app.tagFilter != "" {
+		filters = append(filters, "#"+app.tagFilter)
+	}
+	for _, filter := range app.fieldFilters {
+		filters = append(filters, filter.String())
+	}
+	if len(app.marked) > 0 {
+		filters = append(filters, fmt.Sprintf("%d marked", len(app.marked)))
+	}
+	return strings.Join(filters, " ")
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	seqToken     = "{{seq}}"
+	minSeqDigits = 3
+)
+
+// Slug styles for the names of new notes, set by slug_names
+const (
+	slugNone      = "none"
+	slugDashes    = "dashes"
+	slugLowercase = "lowercase"
+)
+
+// How new notes are named. Set from the config at startup.
+var noteNaming struct {
+	datePrefix bool
+	slug       string
+	extension  string
+}
+
+var (
+	datePrefixRegex = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}`)
+	whitespaceRegex = regexp.MustCompile(`\s+`)
+)
+
+// Name a new note after what was entered for it: the last element of path is
+// slugged, prefixed with the date and given the default extension as
+// configured. The entered title is returned when slugging changed it.
+func nameNewNote(path string, now time.Time) (string, string) {
+	dir, name := filepath.Split(path)
+	ext := filepath.Ext(name)
+	if strings.ContainsAny(ext, " \t") {
+		ext = ""
+	}
+	title := strings.TrimSpace(strings.TrimSuffix(name, ext))
+	base := title
+	if noteNaming.slug == slugDashes || noteNaming.slug == slugLowercase {
+		base = whitespaceRegex.ReplaceAllString(base, "-")
+		if noteNaming.slug == slugLowercase {
+			base = strings.ToLower(base)
+		}
+	}
+	if base == title {
+		title = ""
+	}
+	if noteNaming.datePrefix && !datePrefixRegex.MatchString(base) {
+		base = now.Format("2006-01-02") + "-" + base
+	}
+	if ext == "" {
+		ext = noteNaming.extension
+		if ext == "" && noteNaming.datePrefix {
+			ext = ".md"
+		}
+	}
+	return filepath.Join(dir, base+ext), title
+}
+
+// Replace the {{seq}} token in the last element of path with the next number
+// in the series found among the existing entries of its directory
+func expandSeq(path string) string {
+	name := filepath.Base(path)
+	if !strings.Contains(name, seqToken) {
+		return path
+	}
+	dir := filepath.Dir(path)
+
+	prefix, suffix, _ := strings.Cut(name, seqToken)
+	suffix = strings.ReplaceAll(suffix, seqToken, "")
+	pattern := regexp.MustCompile("^" + regexp.QuoteMeta(prefix) + `(\d+)` + regexp.QuoteMeta(suffix) + "$")
+
+	next, digits := 1, minSeqDigits
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		m := pattern.FindStringSubmatch(entry.Name())
+		if m == nil {
+			continue
+		}
+		n, err := strconv.Atoi(m[1])
+		if err != nil {
+			continue
+		}
+		if n >= next {
+			next = n + 1
+		}
+		if len(m[1]) > digits {
+			digits = len(m[1])
+		}
+	}
+	return filepath.Join(dir, prefix+fmt.Sprintf("%0*d", digits, next)+suffix)
+}
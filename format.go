@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Name of the built-in markdown normalizer in the formatters config
+const formatterBuiltin = "builtin"
+
+var (
+	trailingSpaceRegex = regexp.MustCompile(`[ \t]+$`)
+	atxHeadingRegex    = regexp.MustCompile(`^#{1,6}\s`)
+)
+
+// The formatter configured for the file's extension
+func (app *App) formatterFor(path string) (string, bool) {
+	for ext, command := range app.config.Formatters {
+		if strings.EqualFold(ext, filepath.Ext(path)) {
+			return command, true
+		}
+	}
+	return "", false
+}
+
+// Tidy markdown without changing what it renders to: trailing whitespace is
+// dropped (hard breaks keep two spaces), runs of blank lines become one,
+// headings get a blank line around them and the note ends with one newline.
+// Frontmatter and fenced code blocks are kept as they are.
+func normalizeMarkdown(content []byte) []byte {
+	lines := strings.Split(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n")
+	var out []string
+	blank := func() bool { return len(out) == 0 || out[len(out)-1] == "" }
+	inFrontmatter := len(lines) > 0 && lines[0] == "---"
+	inFence := false
+	afterHeading := false
+	for i, line := range lines {
+		switch {
+		case inFrontmatter:
+			out = append(out, line)
+			if i > 0 && (line == "---" || line == "...") {
+				inFrontmatter = false
+			}
+			continue
+		case strings.HasPrefix(strings.TrimSpace(line), "```"):
+			inFence = !inFence
+		case inFence:
+			out = append(out, line)
+			continue
+		}
+		trimmed := trailingSpaceRegex.ReplaceAllString(line, "")
+		if strings.HasSuffix(line, "  ") && trimmed != "" && !strings.HasPrefix(strings.TrimSpace(line), "```") {
+			trimmed += "  "
+		}
+		if trimmed == "" {
+			if !blank() {
+				out = append(out, "")
+			}
+			afterHeading = false
+			continue
+		}
+		heading := atxHeadingRegex.MatchString(trimmed)
+		if (heading || afterHeading) && !blank() {
+			out = append(out, "")
+		}
+		out = append(out, trimmed)
+		afterHeading = heading
+	}
+	for len(out) > 0 && out[len(out)-1] == "" {
+		out = out[:len(out)-1]
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return []byte(strings.Join(out, "\n") + "\n")
+}
+
+// Run a formatter command with the note on standard input, returning what it
+// printed
+func runFormatter(command string, path string, root string, content []byte) ([]byte, error) {
+	if command == formatterBuiltin {
+		return normalizeMarkdown(content), nil
+	}
+	cmd := shellCommand(command)
+	cmd.Dir = root
+	cmd.Env = append(os.Environ(), "NOTES_PATH="+path)
+	cmd.Stdin = bytes.NewReader(content)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		message := strings.TrimSpace(stderr.String())
+		if message == "" {
+			message = err.Error()
+		}
+		return nil, userErr{fmt.Sprintf("Formatter failed: %s", strings.Split(message, "\n")[0])}
+	}
+	return output, nil
+}
+
+// Run the formatter configured for a note after it was edited and write its
+// result once the user accepted it, with a diff of the changes on request.
+// Encrypted notes aren't handed to formatters.
+func (app *App) formatNote(path string) error {
+	command, ok := app.formatterFor(path)
+	if !ok || isEncryptedFile(path) {
+		return nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %v", path, err)
+	}
+	formatted, err := runFormatter(command, path, app.rootItem.Path, content)
+	if err != nil {
+		return err
+	}
+	if len(bytes.TrimSpace(formatted)) == 0 || bytes.Equal(formatted, content) {
+		return nil
+	}
+	name := app.relativePath(path)
+	for {
+		switch getChoice(fmt.Sprintf("Formatting changes %s: (w)rite, (d)iff, (k)eep as is: ", name), "wdk", app.screen) {
+		case 'w':
+			info, err := os.Stat(path)
+			if err != nil {
+				return fmt.Errorf("error reading %s: %v", path, err)
+			}
+			if err := os.WriteFile(path, formatted, info.Mode()); err != nil {
+				return fmt.Errorf("error writing %s: %v", path, err)
+			}
+			app.toast = "Formatted " + name
+			return nil
+		case 'd':
+			diff := contentDiff(content, formatted)
+			showOutput("Format: "+name, strings.Join(diff, "\n"), app.screen)
+		default:
+			return nil
+		}
+	}
+}
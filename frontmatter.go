@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"strings"
+)
+
+const frontmatterDelimiter = "---"
+
+// Split content into frontmatter lines and the remaining body. ok is false when
+// the content doesn't start with a frontmatter block.
+func splitFrontmatter(content []byte) (lines []string, body []byte, ok bool) {
+	text := string(content)
+	if !strings.HasPrefix(text, frontmatterDelimiter+"\n") && !strings.HasPrefix(text, frontmatterDelimiter+"\r\n") {
+		return nil, content, false
+	}
+	rest := text[strings.Index(text, "\n")+1:]
+	offset := 0
+	for offset <= len(rest) {
+		end := strings.Index(rest[offset:], "\n")
+		var line string
+		if end == -1 {
+			line = rest[offset:]
+		} else {
+			line = rest[offset : offset+end]
+		}
+		if strings.TrimRight(line, "\r") == frontmatterDelimiter {
+			if end == -1 {
+				return lines, nil, true
+			}
+			return lines, []byte(rest[offset+end+1:]), true
+		}
+		lines = append(lines, strings.TrimRight(line, "\r"))
+		if end == -1 {
+			break
+		}
+		offset += end + 1
+	}
+	return nil, content, false
+}
+
+// Parse the simple subset of YAML used in frontmatter: scalar values, inline
+// lists ([a, b]) and block lists (- a). Values are string or []string.
+func parseFrontmatter(lines []string) map[string]any {
+	fields := make(map[string]any)
+	var listKey string
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if listKey != "" && strings.HasPrefix(trimmed, "- ") {
+			list, _ := fields[listKey].([]string)
+			fields[listKey] = append(list, unquote(strings.TrimSpace(strings.TrimPrefix(trimmed, "- "))))
+			continue
+		}
+		listKey = ""
+		if line != trimmed {
+			continue
+		}
+		key, value, found := strings.Cut(trimmed, ":")
+		if !found {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		switch {
+		case value == "":
+			listKey = key
+			fields[key] = []string{}
+		case strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]"):
+			var list []string
+			for _, v := range strings.Split(strings.Trim(value, "[]"), ",") {
+				if v = strings.TrimSpace(v); v != "" {
+					list = append(list, unquote(v))
+				}
+			}
+			fields[key] = list
+		default:
+			fields[key] = unquote(value)
+		}
+	}
+	return fields
+}
+
+func unquote(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// Read only the frontmatter of a file, stopping at its closing delimiter
+func readFrontmatter(path string) map[string]any {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	if !scanner.Scan() || strings.TrimRight(scanner.Text(), "\r") != frontmatterDelimiter {
+		return nil
+	}
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == frontmatterDelimiter {
+			return parseFrontmatter(lines)
+		}
+		lines = append(lines, line)
+	}
+	return nil
+}
+
+func frontmatterString(fields map[string]any, key string) string {
+	value, _ := fields[key].(string)
+	return value
+}
+
+func frontmatterList(fields map[string]any, key string) []string {
+	switch value := fields[key].(type) {
+	case []string:
+		return value
+	case string:
+		if value != "" {
+			return []string{value}
+		}
+	}
+	return nil
+}
+
+// Set a frontmatter field, replacing the key's existing lines and keeping the
+// rest of the file untouched. A nil value removes the key.
+func setFrontmatterField(content []byte, key string, value any) []byte {
+	lines, body, ok := splitFrontmatter(content)
+	if !ok {
+		body = content
+	}
+
+	var out []string
+	replaced := false
+	skipping := false
+	for _, line := range lines {
+		if skipping {
+			if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") || strings.HasPrefix(line, "- ") {
+				continue
+			}
+			skipping = false
+		}
+		if k, _, found := strings.Cut(line, ":"); found && line == strings.TrimLeft(line, " \t") && strings.TrimSpace(k) == key {
+			skipping = true
+			if value != nil && !replaced {
+				out = append(out, formatFrontmatterField(key, value))
+				replaced = true
+			}
+			continue
+		}
+		out = append(out, line)
+	}
+	if value != nil && !replaced {
+		out = append(out, formatFrontmatterField(key, value))
+	}
+
+	if len(out) == 0 {
+		return body
+	}
+	var buf bytes.Buffer
+	buf.WriteString(frontmatterDelimiter + "\n")
+	for _, line := range out {
+		buf.WriteString(line + "\n")
+	}
+	buf.WriteString(frontmatterDelimiter + "\n")
+	buf.Write(body)
+	return buf.Bytes()
+}
+
+func formatFrontmatterField(key string, value any) string {
+	switch v := value.(type) {
+	case []string:
+		return key + ": [" + strings.Join(v, ", ") + "]"
+	case string:
+		return key + ": " + v
+	}
+	return key + ":"
+}
+
+func updateFrontmatterField(path string, key string, value any) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, setFrontmatterField(content, key, value), info.Mode())
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"github.com/mattn/go-runewidth"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Run git in the vault, returning its output or its error message
+func (app *App) git(args ...string) (string, error) {
+	if !app.hasTool("git") {
+		return "", userErr{"git not found"}
+	}
+	cmd := exec.Command("git", append([]string{"-C", app.rootItem.Path}, args...)...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		message := strings.TrimSpace(string(output))
+		if message == "" {
+			message = err.Error()
+		}
+		return "", userErr{"git: " + strings.Split(message, "\n")[0]}
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// The marked notes, or the selected note or directory when none are marked
+func (app *App) gitTargets() []string {
+	if len(app.marked) > 0 {
+		return app.markedOrSelected()
+	}
+	if item := app.selectedItem(); item.Path != "" {
+		return []string{item.Path}
+	}
+	return nil
+}
+
+// Stage or, with unstage, unstage the marked notes or the selection
+func (app *App) gitStage(unstage bool) error {
+	paths := app.gitTargets()
+	if len(paths) == 0 {
+		return nil
+	}
+	args := []string{"add", "--"}
+	verb := "Staged"
+	if unstage {
+		args = []string{"reset", "-q", "--"}
+		verb = "Unstaged"
+	}
+	if _, err := app.git(append(args, paths...)...); err != nil {
+		return err
+	}
+	app.vaultStatus = VaultStatus{}
+	if len(paths) == 1 {
+		app.toast = fmt.Sprintf("%s %s", verb, app.relativePath(paths[0]))
+	} else {
+		app.toast = fmt.Sprintf("%s %d notes", verb, len(paths))
+	}
+	return nil
+}
+
+// Commit the staged changes with a message asked for at a prompt
+func (app *App) gitCommit() error {
+	staged, err := app.git("diff", "--cached", "--name-only")
+	if err != nil {
+		return err
+	}
+	if staged == "" {
+		return userErr{"Nothing staged to commit"}
+	}
+	count := len(strings.Split(staged, "\n"))
+	message, ok := getUserInput(fmt.Sprintf("Commit %d staged files, message: ", count), "", app.screen)
+	if !ok || strings.TrimSpace(message) == "" {
+		return nil
+	}
+	app.recordOperation("git-commit", app.rootItem.Path)
+	output, err := app.git("commit", "-q", "-m", message)
+	if err != nil {
+		return err
+	}
+	if output == "" {
+		output, _ = app.git("log", "-1", "--format=%h %s")
+	}
+	app.vaultStatus = VaultStatus{}
+	app.toast = "Committed " + strings.Split(output, "\n")[0]
+	return nil
+}
+
+// BlameLine is a line of a note with the commit that last changed it
+type BlameLine struct {
+	Commit string
+	Author string
+	Time   time.Time
+	Text   string
+}
+
+// Parse the output of git blame --porcelain, where the author of a commit is
+// only given with its first line
+func parseBlame(output string) []BlameLine {
+	var lines []BlameLine
+	commits := make(map[string]BlameLine)
+	var current BlameLine
+	for _, line := range strings.Split(output, "\n") {
+		switch {
+		case strings.HasPrefix(line, "\t"):
+			current.Text = line[1:]
+			commits[current.Commit] = current
+			lines = append(lines, current)
+		case strings.HasPrefix(line, "author "):
+			current.Author = strings.TrimPrefix(line, "author ")
+		case strings.HasPrefix(line, "author-time "):
+			if seconds, err := strconv.ParseInt(strings.TrimPrefix(line, "author-time "), 10, 64); err == nil {
+				current.Time = time.Unix(seconds, 0)
+			}
+		default:
+			fields := strings.Fields(line)
+			if len(fields) >= 3 && len(fields[0]) == 40 {
+				if known, ok := commits[fields[0]]; ok {
+					current = known
+				} else {
+					current = BlameLine{Commit: fields[0]}
+				}
+			}
+		}
+	}
+	return lines
+}
+
+// Show the lines of the selected note with the date, author and commit that
+// last changed each of them
+func (app *App) showBlame() error {
+	path := app.selectedItem().Path
+	if !isFile(path) {
+		return nil
+	}
+	if isEncryptedFile(path) {
+		return userErr{"Can't blame an encrypted note"}
+	}
+	output, err := app.git("blame", "--porcelain", "--", path)
+	if err != nil {
+		return err
+	}
+	lines := parseBlame(output)
+	authorWidth := 0
+	for i, line := range lines {
+		if strings.Trim(line.Commit, "0") == "" {
+			lines[i].Author = "uncommitted"
+		}
+		authorWidth = max(authorWidth, min(runewidth.StringWidth(lines[i].Author), 16))
+	}
+	var b strings.Builder
+	for _, line := range lines {
+		commit, date := line.Commit[:7], line.Time.Format("2006-01-02")
+		if line.Author == "uncommitted" && strings.Trim(line.Commit, "0") == "" {
+			commit, date = "-------", "----------"
+		}
+		author := runewidth.FillRight(runewidth.Truncate(line.Author, authorWidth, "…"), authorWidth)
+		fmt.Fprintf(&b, "%s %-10s %s │ %s\n", commit, date, author, line.Text)
+	}
+	showOutput("Blame: "+app.relativePath(path), strings.TrimSuffix(b.String(), "\n"), app.screen)
+	return nil
+}
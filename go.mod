@@ -5,7 +5,9 @@ go 1.22.4
 require (
 	github.com/MichaelMure/go-term-markdown v0.1.4
 	github.com/gdamore/tcell/v2 v2.7.4
+	github.com/gomarkdown/markdown v0.0.0-20191123064959-2c17d62f5098
 	github.com/mattn/go-runewidth v0.0.15
+	golang.org/x/term v0.17.0
 )
 
 require (
@@ -17,7 +19,6 @@ require (
 	github.com/eliukblau/pixterm/pkg/ansimage v0.0.0-20191210081756-9fb6cf8c2f75 // indirect
 	github.com/fatih/color v1.9.0 // indirect
 	github.com/gdamore/encoding v1.0.0 // indirect
-	github.com/gomarkdown/markdown v0.0.0-20191123064959-2c17d62f5098 // indirect
 	github.com/kyokomi/emoji/v2 v2.2.8 // indirect
 	github.com/lucasb-eyer/go-colorful v1.2.0 // indirect
 	github.com/mattn/go-colorable v0.1.4 // indirect
@@ -26,6 +27,5 @@ require (
 	golang.org/x/image v0.0.0-20191206065243-da761ea9ff43 // indirect
 	golang.org/x/net v0.6.0 // indirect
 	golang.org/x/sys v0.17.0 // indirect
-	golang.org/x/term v0.17.0 // indirect
 	golang.org/x/text v0.14.0 // indirect
 )
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Events hooks can be configured for
+const (
+	hookNoteCreated = "note-created"
+	hookNoteEdited  = "note-edited"
+	hookNoteDeleted = "note-deleted"
+	hookNoteMoved   = "note-moved"
+	hookAppExit     = "app-exit"
+)
+
+var hookEvents = []string{hookNoteCreated, hookNoteEdited, hookNoteDeleted, hookNoteMoved, hookAppExit}
+
+func shellCommand(command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", command)
+	}
+	return exec.Command("sh", "-c", command)
+}
+
+// Run the shell command configured for an event in the vault directory, with
+// the event and paths in NOTES_EVENT, NOTES_PATH, NOTES_OLD_PATH and
+// NOTES_ROOT. Hooks run in the background, except app-exit which is waited
+// for so it finishes before notes does. Failures only go to the log.
+func (app *App) fireHook(event string, path string, oldPath string) {
+	command, ok := app.config.Hooks[event]
+	if !ok {
+		return
+	}
+	root, _ := filepath.Abs(app.rootItem.Path)
+	absolute := func(p string) string {
+		if p == "" {
+			return ""
+		}
+		abs, _ := filepath.Abs(p)
+		return abs
+	}
+	cmd := shellCommand(command)
+	cmd.Dir = root
+	cmd.Env = append(os.Environ(),
+		"NOTES_EVENT="+event,
+		"NOTES_PATH="+absolute(path),
+		"NOTES_OLD_PATH="+absolute(oldPath),
+		"NOTES_ROOT="+root,
+	)
+	app.recordOperation("hook", event+" "+path)
+	run := func() {
+		if output, err := cmd.CombinedOutput(); err != nil {
+			logger.Printf("error running %s hook: %v: %s", event, err, strings.TrimSpace(string(output)))
+		}
+	}
+	if event == hookAppExit {
+		run()
+		return
+	}
+	go run()
+}
+
+// Fire note-moved for the rename that moved the note, the last of a step
+func (app *App) fireMoveHook(moves []FileMove) {
+	if len(moves) > 0 {
+		move := moves[len(moves)-1]
+		app.fireHook(hookNoteMoved, move.To, move.From)
+	}
+}
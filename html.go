@@ -0,0 +1,85 @@
+package main
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	htmlTableRegex   = regexp.MustCompile(`(?is)<table[^>]*>.*?</table>`)
+	htmlRowRegex     = regexp.MustCompile(`(?is)<tr[^>]*>(.*?)</tr>`)
+	htmlCellRegex    = regexp.MustCompile(`(?is)<(td|th)[^>]*>(.*?)</(?:td|th)>`)
+	htmlImgRegex     = regexp.MustCompile(`(?i)<img\s[^>]*>`)
+	htmlAttrRegex    = regexp.MustCompile(`(?i)(\w+)\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+	htmlLinkRegex    = regexp.MustCompile(`(?is)<a\s[^>]*href\s*=\s*["']([^"']*)["'][^>]*>(.*?)</a>`)
+	htmlSummaryRegex = regexp.MustCompile(`(?is)<summary[^>]*>(.*?)</summary>`)
+	htmlTagRegex     = regexp.MustCompile(`(?i)</?(details|p|div|span|ul|ol|center|font|sup|sub|u)(\s[^>]*)?>`)
+
+	htmlReplacer = strings.NewReplacer(
+		"<br>", "  \n", "<br/>", "  \n", "<br />", "  \n",
+		"<b>", "**", "</b>", "**", "<strong>", "**", "</strong>", "**",
+		"<i>", "*", "</i>", "*", "<em>", "*", "</em>", "*",
+		"<code>", "`", "</code>", "`",
+		"<s>", "~~", "</s>", "~~", "<del>", "~~", "</del>", "~~",
+		"<li>", "- ", "</li>", "",
+		"<hr>", "\n---\n", "<hr/>", "\n---\n", "<hr />", "\n---\n",
+	)
+)
+
+// Convert the common inline HTML found in imported notes to markdown so the
+// preview doesn't show raw tags. Fenced code blocks are left untouched.
+func convertHTML(source string) string {
+	if !strings.Contains(source, "<") {
+		return source
+	}
+	return mapOutsideFences(source, convertHTMLSegment)
+}
+
+func convertHTMLSegment(s string) string {
+	if !strings.Contains(s, "<") {
+		return s
+	}
+	s = htmlTableRegex.ReplaceAllStringFunc(s, convertHTMLTable)
+	s = htmlImgRegex.ReplaceAllStringFunc(s, func(tag string) string {
+		attrs := htmlAttributes(tag)
+		alt := attrs["alt"]
+		if alt == "" {
+			alt = "image"
+		}
+		return "[image: " + alt + "](" + attrs["src"] + ")"
+	})
+	s = htmlLinkRegex.ReplaceAllString(s, "[$2]($1)")
+	s = htmlSummaryRegex.ReplaceAllString(s, "**▸ $1**\n")
+	s = htmlTagRegex.ReplaceAllString(s, "")
+	return htmlReplacer.Replace(s)
+}
+
+func htmlAttributes(tag string) map[string]string {
+	attrs := make(map[string]string)
+	for _, m := range htmlAttrRegex.FindAllStringSubmatch(tag, -1) {
+		attrs[strings.ToLower(m[1])] = m[2] + m[3]
+	}
+	return attrs
+}
+
+func convertHTMLTable(table string) string {
+	var b strings.Builder
+	b.WriteString("\n")
+	for i, row := range htmlRowRegex.FindAllStringSubmatch(table, -1) {
+		cells := htmlCellRegex.FindAllStringSubmatch(row[1], -1)
+		if len(cells) == 0 {
+			continue
+		}
+		b.WriteString("|")
+		for _, cell := range cells {
+			text := strings.Join(strings.Fields(html.UnescapeString(cell[2])), " ")
+			b.WriteString(" " + strings.ReplaceAll(text, "|", "\\|") + " |")
+		}
+		b.WriteString("\n")
+		if i == 0 {
+			b.WriteString("|" + strings.Repeat(" --- |", len(cells)) + "\n")
+		}
+	}
+	return b.String() + "\n"
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Icon sets for the tree
+const (
+	iconsNone  = "none"
+	iconsNerd  = "nerd"
+	iconsASCII = "ascii"
+)
+
+var iconKinds = []string{"directory", "markdown", "image", "code", "encrypted", "file"}
+
+// Icons per kind of tree entry, the nerd font ones from its Font Awesome and
+// Devicons ranges
+var iconSets = map[string]map[string]string{
+	iconsNerd: {
+		"directory": "\uf07b",
+		"markdown":  "\ue73e",
+		"image":     "\uf1c5",
+		"code":      "\uf121",
+		"encrypted": "\uf023",
+		"file":      "\uf15b",
+	},
+	iconsASCII: {
+		"directory": "/",
+		"markdown":  "#",
+		"image":     "*",
+		"code":      "<",
+		"encrypted": "!",
+		"file":      "-",
+	},
+}
+
+var (
+	imageExtensions = []string{".png", ".jpg", ".jpeg", ".gif", ".svg", ".webp", ".bmp"}
+	codeExtensions  = []string{".go", ".py", ".js", ".ts", ".sh", ".rs", ".c", ".h", ".java", ".rb", ".lua", ".json", ".yaml", ".yml", ".toml"}
+)
+
+// Set from the config at startup, like the encryption settings. Maps kinds
+// and extensions to icons, empty when icons are off.
+var icons map[string]string
+
+// Build the icons of an icon set with overrides keyed by kind or by
+// extension, e.g. {"markdown": "M", ".txt": "T"}
+func buildIcons(set string, overrides map[string]string) (map[string]string, error) {
+	if set == iconsNone {
+		return nil, nil
+	}
+	base, ok := iconSets[set]
+	if !ok {
+		return nil, fmt.Errorf("error: icons must be %s, %s or %s", iconsNone, iconsNerd, iconsASCII)
+	}
+	result := make(map[string]string)
+	for kind, icon := range base {
+		result[kind] = icon
+	}
+	for key, icon := range overrides {
+		if !strings.HasPrefix(key, ".") && !slices.Contains(iconKinds, key) {
+			return nil, fmt.Errorf("error: icon_overrides keys must be extensions like .txt or one of %s", strings.Join(iconKinds, ", "))
+		}
+		result[strings.ToLower(key)] = icon
+	}
+	return result, nil
+}
+
+// Kind of a tree entry, one of iconKinds
+func fileKind(item TreeItem) string {
+	ext := strings.ToLower(filepath.Ext(plainPath(item.Path)))
+	switch {
+	case item.IsDir:
+		return "directory"
+	case isEncryptedFile(item.Path):
+		return "encrypted"
+	case ext == ".md" || ext == ".markdown":
+		return "markdown"
+	case slices.Contains(imageExtensions, ext):
+		return "image"
+	case slices.Contains(codeExtensions, ext):
+		return "code"
+	}
+	return "file"
+}
+
+// The icon of a tree entry followed by a space, empty when icons are off
+func iconFor(item TreeItem) string {
+	if icons == nil {
+		return ""
+	}
+	ext := strings.ToLower(filepath.Ext(plainPath(item.Path)))
+	kind := fileKind(item)
+	if icon, ok := icons[ext]; ok && kind != "directory" && kind != "encrypted" {
+		return icon + " "
+	}
+	return icons[kind] + " "
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"github.com/gdamore/tcell/v2"
+	"time"
+)
+
+const (
+	IdleDim   = "dim"
+	IdleBlank = "blank"
+)
+
+type idleEvent struct{}
+
+// Restart the idle countdown. The timer fires once and posts an interrupt to
+// the current screen, so nothing runs while the app is waiting for input.
+func (app *App) resetIdleTimer() {
+	app.lastInput = time.Now()
+	app.resetLockTimer()
+	if app.idleTimer != nil {
+		app.idleTimer.Stop()
+	}
+	if app.config.IdleTimeout <= 0 {
+		return
+	}
+	screen := app.screen
+	app.idleTimer = time.AfterFunc(time.Duration(app.config.IdleTimeout)*time.Second, func() {
+		_ = screen.PostEvent(tcell.NewEventInterrupt(idleEvent{}))
+	})
+}
+
+func (app *App) idleElapsed() bool {
+	timeout := time.Duration(app.config.IdleTimeout) * time.Second
+	return timeout > 0 && time.Since(app.lastInput) >= timeout
+}
+
+// Dim or blank the content of area so sensitive notes aren't left on screen
+func obscureArea(area Rect, action string, screen tcell.Screen) {
+	if action == IdleBlank {
+		renderClearArea(area.X, area.Y, area.X+area.Width, area.Y+area.Height, screen)
+		renderText(area.X, area.Y, "Hidden while idle, press any key", tcell.StyleDefault.Dim(true), screen)
+		return
+	}
+	for y := area.Y; y < area.Y+area.Height; y++ {
+		for x := area.X; x < area.X+area.Width; x++ {
+			mainc, combc, style, _ := screen.GetContent(x, y)
+			screen.SetContent(x, y, mainc, combc, style.Dim(true).Foreground(tcell.ColorGray))
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const inboxTimeFormat = "2006-01-02 15:04"
+
+// Append a line typed at a prompt to the inbox note as a timestamped list
+// item, creating the note when it doesn't exist, without leaving the view
+func (app *App) appendToInbox() error {
+	path := filepath.Join(app.rootItem.Path, app.config.Inbox)
+	if isEncryptedFile(path) {
+		return userErr{"Can't append to an encrypted inbox"}
+	}
+	text, ok := getUserInput("Inbox: ", "", app.screen)
+	if !ok || strings.TrimSpace(text) == "" {
+		return nil
+	}
+
+	content, err := os.ReadFile(path)
+	created := os.IsNotExist(err)
+	if err != nil && !created {
+		return fmt.Errorf("error reading %s: %v", path, err)
+	}
+	if len(content) > 0 && !strings.HasSuffix(string(content), "\n") {
+		content = append(content, '\n')
+	}
+	content = append(content, fmt.Sprintf("- %s %s\n", time.Now().Format(inboxTimeFormat), strings.TrimSpace(text))...)
+
+	app.recordOperation("append-inbox", path)
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return fmt.Errorf("error creating directory %s: %v", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		return fmt.Errorf("error writing %s: %v", path, err)
+	}
+	if created {
+		app.fireHook(hookNoteCreated, path, "")
+		app.rebuild()
+	} else {
+		app.fireHook(hookNoteEdited, path, "")
+	}
+	return nil
+}
@@ -0,0 +1,456 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/gob"
+	"fmt"
+	"github.com/gdamore/tcell/v2"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode"
+)
+
+const indexVersion = 1
+
+var (
+	inlineTagRegex = regexp.MustCompile(`(?:^|\s)#([\p{L}\p{N}_/-]+)`)
+	wikiLinkRegex  = regexp.MustCompile(`\[\[([^\]|#]+)`)
+	mdLinkRegex    = regexp.MustCompile(`\]\(([^)\s]+)\)`)
+)
+
+type IndexLink struct {
+	Target string
+	Line   int
+}
+
+// IndexEntry is what the index knows about a single file. Binary files are
+// kept without terms so they aren't read again until they change.
+type IndexEntry struct {
+	ModTime int64
+	Size    int64
+	Terms   []string
+	Tags    []string
+	Links   []IndexLink
+}
+
+// Index is an inverted index of the vault stored in the user cache directory
+// and refreshed incrementally using file modification times. An Index is never
+// modified once built, so it can be read while a refresh runs in the background.
+type Index struct {
+	Version int
+	Root    string
+	Files   map[string]*IndexEntry
+	terms   map[string][]string
+}
+
+type indexEvent struct{}
+
+// Progress of a running index refresh, written by the indexing goroutine
+type indexProgress struct {
+	done  atomic.Int64
+	total atomic.Int64
+}
+
+// How often a running refresh asks for the footer to be redrawn
+const indexProgressInterval = 200 * time.Millisecond
+
+func indexFilePath(root string) string {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	sum := sha1.Sum([]byte(root))
+	return filepath.Join(cacheDir, "notes", fmt.Sprintf("index-%x.gob", sum[:6]))
+}
+
+// Load the stored index of root, starting empty when it's missing or was
+// written by another version
+func loadIndex(root string) *Index {
+	index := &Index{Version: indexVersion, Root: root, Files: map[string]*IndexEntry{}}
+	file, err := os.Open(indexFilePath(root))
+	if err == nil {
+		defer file.Close()
+		var stored Index
+		if err := gob.NewDecoder(file).Decode(&stored); err != nil {
+			logger.Printf("ignoring unreadable index: %v", err)
+		} else if stored.Version == indexVersion && stored.Root == root && stored.Files != nil {
+			index = &stored
+		}
+	}
+	index.buildTerms()
+	return index
+}
+
+func (index *Index) save() error {
+	path := indexFilePath(index.Root)
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return fmt.Errorf("error creating index directory %s: %v", filepath.Dir(path), err)
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(index); err != nil {
+		return fmt.Errorf("error encoding index: %v", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("error writing index %s: %v", tmp, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("error replacing index %s: %v", path, err)
+	}
+	return nil
+}
+
+func (index *Index) buildTerms() {
+	index.terms = make(map[string][]string)
+	for rel, entry := range index.Files {
+		for _, term := range entry.Terms {
+			index.terms[term] = append(index.terms[term], rel)
+		}
+	}
+}
+
+// Return a new index with changed files re-indexed and removed files dropped,
+// sharing the unchanged entries, and the number of files that changed.
+// progress is called with the number of re-indexed files out of those needing it.
+func (index *Index) update(progress func(done int, total int)) (*Index, int, error) {
+	updated := &Index{Version: indexVersion, Root: index.Root, Files: make(map[string]*IndexEntry, len(index.Files))}
+	type pending struct {
+		path string
+		rel  string
+		info fs.FileInfo
+	}
+	var todo []pending
+	err := walkNotes(index.Root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(index.Root, path)
+		if err != nil {
+			return nil
+		}
+		if entry, ok := index.Files[rel]; ok && entry.ModTime == info.ModTime().UnixNano() && entry.Size == info.Size() {
+			updated.Files[rel] = entry
+		} else {
+			todo = append(todo, pending{path, rel, info})
+		}
+		return nil
+	})
+
+	changed := len(todo)
+	for i, file := range todo {
+		if entry, err := indexFile(index.Root, file.path, file.info); err == nil {
+			updated.Files[file.rel] = entry
+		}
+		progress(i+1, len(todo))
+	}
+	for rel := range index.Files {
+		if _, ok := updated.Files[rel]; !ok {
+			changed++
+		}
+	}
+	updated.buildTerms()
+	if err != nil {
+		return updated, changed, fmt.Errorf("error indexing %s: %v", index.Root, err)
+	}
+	return updated, changed, nil
+}
+
+func indexFile(root string, path string, info fs.FileInfo) (*IndexEntry, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	entry := &IndexEntry{ModTime: info.ModTime().UnixNano(), Size: info.Size()}
+	if bytes.IndexByte(content[:min(len(content), 512)], 0) != -1 {
+		return entry, nil
+	}
+
+	terms := make(map[string]bool)
+	tags := make(map[string]bool)
+	if lines, _, ok := splitFrontmatter(content); ok {
+		for _, tag := range frontmatterList(parseFrontmatter(lines), "tags") {
+			tags[strings.ToLower(tag)] = true
+		}
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		for _, term := range tokenize(text) {
+			terms[term] = true
+		}
+		for _, m := range inlineTagRegex.FindAllStringSubmatch(text, -1) {
+			tags[strings.ToLower(m[1])] = true
+		}
+		for _, m := range wikiLinkRegex.FindAllStringSubmatch(text, -1) {
+			entry.Links = append(entry.Links, IndexLink{Target: wikiTarget(m[1]), Line: line})
+		}
+		for _, m := range mdLinkRegex.FindAllStringSubmatch(text, -1) {
+			if target, ok := markdownTarget(root, path, m[1]); ok {
+				entry.Links = append(entry.Links, IndexLink{Target: target, Line: line})
+			}
+		}
+	}
+	entry.Terms = sortedKeys(terms)
+	entry.Tags = sortedKeys(tags)
+	return entry, nil
+}
+
+// Split text into lowercase runs of letters and digits
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Wiki links name a note without its directory or extension
+func wikiTarget(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	return "[[" + strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+// Resolve a relative markdown link to a path relative to the vault root
+func markdownTarget(root string, path string, link string) (string, bool) {
+	if strings.Contains(link, "://") || strings.HasPrefix(link, "mailto:") || strings.HasPrefix(link, "#") {
+		return "", false
+	}
+	link, _, _ = strings.Cut(link, "#")
+	target := filepath.Join(filepath.Dir(path), filepath.FromSlash(link))
+	rel, err := filepath.Rel(root, target)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return "", false
+	}
+	return rel, true
+}
+
+// Files that may contain query: every word of the query has to appear within
+// a word of the file. A query without words matches every indexed text file.
+func (index *Index) candidates(query string) []string {
+	var matched map[string]bool
+	for _, token := range tokenize(query) {
+		files := make(map[string]bool)
+		for term, rels := range index.terms {
+			if !strings.Contains(term, token) {
+				continue
+			}
+			for _, rel := range rels {
+				if matched == nil || matched[rel] {
+					files[rel] = true
+				}
+			}
+		}
+		matched = files
+	}
+	if matched == nil {
+		matched = make(map[string]bool)
+		for rel, entry := range index.Files {
+			if len(entry.Terms) > 0 {
+				matched[rel] = true
+			}
+		}
+	}
+	paths := make([]string, 0, len(matched))
+	for _, rel := range sortedKeys(matched) {
+		paths = append(paths, filepath.Join(index.Root, rel))
+	}
+	return paths
+}
+
+// Search the files the index names as candidates for query
+func searchIndex(index *Index, query string) []SearchResult {
+	var results []SearchResult
+	needle := strings.ToLower(query)
+	for _, path := range index.candidates(query) {
+		results = searchFile(path, needle, results)
+		if len(results) >= maxSearchResults {
+			break
+		}
+	}
+	return results
+}
+
+func (index *Index) hasTag(path string, tag string) bool {
+	rel, err := filepath.Rel(index.Root, path)
+	if err != nil {
+		return false
+	}
+	entry, ok := index.Files[rel]
+	if !ok {
+		return false
+	}
+	i := sort.SearchStrings(entry.Tags, tag)
+	return i < len(entry.Tags) && entry.Tags[i] == tag
+}
+
+// The lines linking to path, either by a relative markdown link or a wiki link
+func (index *Index) backlinks(path string) []SearchResult {
+	rel, err := filepath.Rel(index.Root, path)
+	if err != nil {
+		return nil
+	}
+	wiki := wikiTarget(filepath.Base(rel))
+	var results []SearchResult
+	for _, source := range sortedFiles(index.Files) {
+		for _, link := range index.Files[source].Links {
+			if link.Target == rel || link.Target == wiki {
+				sourcePath := filepath.Join(index.Root, source)
+				results = append(results, SearchResult{Path: sourcePath, Line: link.Line, Text: readLine(sourcePath, link.Line)})
+			}
+		}
+	}
+	return results
+}
+
+func sortedFiles(files map[string]*IndexEntry) []string {
+	rels := make([]string, 0, len(files))
+	for rel := range files {
+		rels = append(rels, rel)
+	}
+	sort.Strings(rels)
+	return rels
+}
+
+func readLine(path string, n int) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for i := 1; scanner.Scan(); i++ {
+		if i == n {
+			return scanner.Text()
+		}
+	}
+	return ""
+}
+
+// Re-index changed files in the background. The finished index is handed back
+// through indexDone and swapped in by collectIndex.
+func (app *App) refreshIndex() {
+	if app.index == nil {
+		return
+	}
+	if app.indexing {
+		app.indexPending = true
+		return
+	}
+	app.indexing = true
+	progress := &indexProgress{}
+	app.indexProgress = progress
+	index, screen, done := app.index, app.screen, app.indexDone
+	go func() {
+		var posted time.Time
+		updated, changed, err := index.update(func(done int, total int) {
+			progress.done.Store(int64(done))
+			progress.total.Store(int64(total))
+			if time.Since(posted) >= indexProgressInterval {
+				posted = time.Now()
+				_ = screen.PostEvent(tcell.NewEventInterrupt(indexEvent{}))
+			}
+		})
+		if err != nil {
+			logger.Printf("%v", err)
+		}
+		if changed > 0 {
+			if err := updated.save(); err != nil {
+				logger.Printf("%v", err)
+			}
+		}
+		done <- updated
+		_ = screen.PostEvent(tcell.NewEventInterrupt(indexEvent{}))
+	}()
+}
+
+// Swap in a finished index, refreshing again when files changed meanwhile
+func (app *App) collectIndex() {
+	select {
+	case index := <-app.indexDone:
+		app.index = index
+		app.indexing = false
+		app.indexProgress = nil
+		if app.indexPending {
+			app.indexPending = false
+			app.refreshIndex()
+		}
+	default:
+	}
+}
+
+// Footer text while a refresh is re-indexing files, e.g. "indexed 3,214/12,800 notes"
+func (app *App) indexStatus() string {
+	if app.indexProgress == nil {
+		return ""
+	}
+	total := app.indexProgress.total.Load()
+	if total == 0 {
+		return ""
+	}
+	return fmt.Sprintf("indexed %s/%s notes", formatCount(app.indexProgress.done.Load()), formatCount(total))
+}
+
+// Format n with thousands separators
+func formatCount(n int64) string {
+	digits := strconv.FormatInt(n, 10)
+	var b strings.Builder
+	for i, r := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func runBacklinks(app *App, args []string) error {
+	item := app.selectedItem()
+	if app.index == nil || !isFile(item.Path) {
+		return userErr{"Select a note to list its backlinks"}
+	}
+	results := app.index.backlinks(item.Path)
+	if len(results) == 0 {
+		return userErr{fmt.Sprintf("No backlinks to: %s", app.relativePath(item.Path))}
+	}
+	name := filepath.Base(item.Path)
+	app.search = &Search{query: strings.TrimSuffix(name, filepath.Ext(name)), results: results}
+	app.setFocus(FocusSearch)
+	return nil
+}
+
+func runFilterTag(app *App, args []string) error {
+	if len(args) == 0 {
+		app.tagFilter = ""
+		app.rebuild()
+		return nil
+	}
+	if app.index == nil {
+		return userErr{"The index isn't available"}
+	}
+	app.tagFilter = strings.ToLower(strings.TrimPrefix(args[0], "#"))
+	app.currentSelection = 0
+	app.rebuild()
+	return nil
+}
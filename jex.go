@@ -0,0 +1,441 @@
+package main
+
+import (
+	"archive/tar"
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Joplin item types found in a JEX archive
+const (
+	jexNote     = "1"
+	jexFolder   = "2"
+	jexResource = "4"
+	jexTag      = "5"
+	jexNoteTag  = "6"
+
+	jexTimeLayout   = "2006-01-02T15:04:05.000Z"
+	jexResourcesDir = "resources"
+	// Imported resources are kept in one directory like Joplin's own markdown
+	// export does
+	resourcesDirName = "_resources"
+)
+
+var (
+	jexPropertyRegex     = regexp.MustCompile(`^([a-z_]+): ?(.*)$`)
+	jexResourceLinkRegex = regexp.MustCompile(`\(:/([0-9a-f]{32})\)`)
+	jexFileNameReplacer  = strings.NewReplacer("/", "-", "\\", "-", ":", "-", "*", "-", "?", "-", "\"", "-", "<", "-", ">", "-", "|", "-")
+)
+
+// JexItem is a note, folder, resource or tag of a Joplin export: a title line,
+// an optional body and key: value properties at the end
+type JexItem struct {
+	Title      string
+	Body       string
+	Properties map[string]string
+}
+
+func (item JexItem) id() string       { return item.Properties["id"] }
+func (item JexItem) kind() string     { return item.Properties["type_"] }
+func (item JexItem) parentID() string { return item.Properties["parent_id"] }
+
+func parseJexItem(content string) JexItem {
+	item := JexItem{Properties: make(map[string]string)}
+	lines := strings.Split(strings.TrimRight(content, "\n"), "\n")
+	end := len(lines)
+	for end > 0 {
+		m := jexPropertyRegex.FindStringSubmatch(lines[end-1])
+		if m == nil {
+			break
+		}
+		item.Properties[m[1]] = m[2]
+		end--
+	}
+	lines = lines[:end]
+	if len(lines) > 0 {
+		item.Title = lines[0]
+	}
+	if len(lines) > 2 {
+		item.Body = strings.TrimRight(strings.Join(lines[2:], "\n"), "\n")
+	}
+	return item
+}
+
+func (item JexItem) String() string {
+	var b strings.Builder
+	b.WriteString(item.Title + "\n\n")
+	if item.Body != "" {
+		b.WriteString(item.Body + "\n\n")
+	}
+	keys := make([]string, 0, len(item.Properties))
+	for key := range item.Properties {
+		if key != "type_" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		b.WriteString(key + ": " + item.Properties[key] + "\n")
+	}
+	// Joplin reads the type from the last line
+	b.WriteString("type_: " + item.kind())
+	return b.String()
+}
+
+func newJexID() string {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+func jexProperties(id string, kind string, modTime time.Time) map[string]string {
+	stamp := modTime.UTC().Format(jexTimeLayout)
+	return map[string]string{
+		"id":                     id,
+		"type_":                  kind,
+		"created_time":           stamp,
+		"updated_time":           stamp,
+		"user_created_time":      stamp,
+		"user_updated_time":      stamp,
+		"encryption_applied":     "0",
+		"encryption_cipher_text": "",
+	}
+}
+
+// A file name for a Joplin title, which may contain any character
+func jexFileName(title string) string {
+	name := strings.TrimSpace(jexFileNameReplacer.Replace(title))
+	if name == "" || strings.HasPrefix(name, ".") {
+		name = "untitled" + name
+	}
+	return name
+}
+
+// A path that doesn't exist yet, numbering the name like note (2).md
+func uniquePath(p string) string {
+	if _, err := os.Lstat(p); os.IsNotExist(err) {
+		return p
+	}
+	ext := filepath.Ext(p)
+	base := strings.TrimSuffix(p, ext)
+	for n := 2; ; n++ {
+		candidate := fmt.Sprintf("%s (%d)%s", base, n, ext)
+		if _, err := os.Lstat(candidate); os.IsNotExist(err) {
+			return candidate
+		}
+	}
+}
+
+func readJexArchive(archivePath string) (items []JexItem, resources map[string][]byte, err error) {
+	in, err := os.Open(archivePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error opening %s: %v", archivePath, err)
+	}
+	defer in.Close()
+	resources = make(map[string][]byte)
+	archive := tar.NewReader(in)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return items, resources, nil
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("error reading archive: %v", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		content, err := io.ReadAll(archive)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error reading archive: %v", err)
+		}
+		name := path.Clean(header.Name)
+		if dir, file := path.Split(name); dir == jexResourcesDir+"/" {
+			resources[strings.TrimSuffix(file, path.Ext(file))] = content
+		} else if dir == "" && path.Ext(file) == ".md" {
+			items = append(items, parseJexItem(string(content)))
+		}
+	}
+}
+
+// Recreate the notebooks of a JEX archive as directories under dir, with
+// resources in a _resources directory and tags in the notes' frontmatter.
+// Existing files are never replaced; clashing notes get a numbered name.
+func importJex(archivePath string, dir string) (int, error) {
+	items, resources, err := readJexArchive(archivePath)
+	if err != nil {
+		return 0, err
+	}
+	folders := make(map[string]JexItem)
+	tags := make(map[string]string)
+	noteTags := make(map[string][]string)
+	resourceItems := make(map[string]JexItem)
+	for _, item := range items {
+		switch item.kind() {
+		case jexFolder:
+			folders[item.id()] = item
+		case jexTag:
+			tags[item.id()] = item.Title
+		case jexResource:
+			resourceItems[item.id()] = item
+		}
+	}
+	for _, item := range items {
+		if item.kind() == jexNoteTag {
+			if tag, ok := tags[item.Properties["tag_id"]]; ok {
+				noteTags[item.Properties["note_id"]] = append(noteTags[item.Properties["note_id"]], tag)
+			}
+		}
+	}
+
+	var folderPath func(id string, depth int) string
+	folderPath = func(id string, depth int) string {
+		folder, ok := folders[id]
+		if !ok || depth > len(folders) {
+			return dir
+		}
+		return filepath.Join(folderPath(folder.parentID(), depth+1), jexFileName(folder.Title))
+	}
+	for id := range folders {
+		if err := os.MkdirAll(folderPath(id, 0), os.ModePerm); err != nil {
+			return 0, fmt.Errorf("error creating directory %s: %v", folderPath(id, 0), err)
+		}
+	}
+
+	resourcePaths := make(map[string]string)
+	for id, content := range resources {
+		item := resourceItems[id]
+		name := id
+		if item.Title != "" {
+			name = jexFileName(item.Title)
+		}
+		if ext := item.Properties["file_extension"]; ext != "" && !strings.EqualFold(filepath.Ext(name), "."+ext) {
+			name += "." + ext
+		}
+		target := uniquePath(filepath.Join(dir, resourcesDirName, name))
+		if err := os.MkdirAll(filepath.Dir(target), os.ModePerm); err != nil {
+			return 0, fmt.Errorf("error creating directory %s: %v", filepath.Dir(target), err)
+		}
+		if err := os.WriteFile(target, content, 0o644); err != nil {
+			return 0, fmt.Errorf("error writing %s: %v", target, err)
+		}
+		resourcePaths[id] = target
+	}
+
+	count := 0
+	for _, item := range items {
+		if item.kind() != jexNote {
+			continue
+		}
+		folder := folderPath(item.parentID(), 0)
+		target := uniquePath(filepath.Join(folder, jexFileName(item.Title)+".md"))
+		body := jexResourceLinkRegex.ReplaceAllStringFunc(item.Body, func(link string) string {
+			resource, ok := resourcePaths[link[3:len(link)-1]]
+			if !ok {
+				return link
+			}
+			rel, err := filepath.Rel(folder, resource)
+			if err != nil {
+				return link
+			}
+			return "(" + strings.ReplaceAll(filepath.ToSlash(rel), " ", "%20") + ")"
+		})
+		content := []byte(body + "\n")
+		if tags := noteTags[item.id()]; len(tags) > 0 {
+			sort.Strings(tags)
+			content = setFrontmatterField(content, tagsField, tags)
+		}
+		if err := os.WriteFile(target, content, 0o644); err != nil {
+			return count, fmt.Errorf("error writing %s: %v", target, err)
+		}
+		if updated, err := time.Parse(jexTimeLayout, item.Properties["updated_time"]); err == nil {
+			_ = os.Chtimes(target, updated, updated)
+		}
+		count++
+	}
+	return count, nil
+}
+
+type jexWriter struct {
+	archive *tar.Writer
+}
+
+func (w jexWriter) write(name string, content []byte, modTime time.Time) error {
+	header := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), ModTime: modTime, Typeflag: tar.TypeReg}
+	if err := w.archive.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := w.archive.Write(content)
+	return err
+}
+
+// Write the vault as a JEX archive: directories become notebooks, markdown
+// notes become notes with their frontmatter tags as Joplin tags, and the other
+// files become resources that links in the notes point to
+func exportJex(dir string, archivePath string) (int, error) {
+	out, err := os.Create(archivePath)
+	if err != nil {
+		return 0, fmt.Errorf("error creating %s: %v", archivePath, err)
+	}
+	defer out.Close()
+	w := jexWriter{tar.NewWriter(out)}
+
+	folderIDs := map[string]string{dir: ""}
+	resourceIDs := make(map[string]string)
+	var notes []string
+	absArchive, _ := filepath.Abs(archivePath)
+	err = walkNotes(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == dir {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			id := newJexID()
+			folderIDs[p] = id
+			properties := jexProperties(id, jexFolder, info.ModTime())
+			properties["parent_id"] = folderIDs[filepath.Dir(p)]
+			return w.write(id+".md", []byte(JexItem{Title: d.Name(), Properties: properties}.String()), info.ModTime())
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if abs, _ := filepath.Abs(p); abs == absArchive {
+			return nil
+		}
+		if strings.ToLower(filepath.Ext(p)) == ".md" {
+			notes = append(notes, p)
+			return nil
+		}
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		id := newJexID()
+		resourceIDs[p] = id
+		ext := strings.TrimPrefix(filepath.Ext(p), ".")
+		properties := jexProperties(id, jexResource, info.ModTime())
+		properties["mime"] = mime.TypeByExtension(filepath.Ext(p))
+		properties["filename"] = d.Name()
+		properties["file_extension"] = ext
+		properties["size"] = fmt.Sprint(len(content))
+		resourceName := jexResourcesDir + "/" + id
+		if ext != "" {
+			resourceName += "." + ext
+		}
+		if err := w.write(resourceName, content, info.ModTime()); err != nil {
+			return err
+		}
+		return w.write(id+".md", []byte(JexItem{Title: d.Name(), Properties: properties}.String()), info.ModTime())
+	})
+	if err != nil {
+		return 0, fmt.Errorf("error exporting %s: %v", dir, err)
+	}
+
+	tagIDs := make(map[string]string)
+	now := time.Now()
+	for _, p := range notes {
+		info, err := os.Stat(p)
+		if err != nil {
+			return 0, fmt.Errorf("error reading %s: %v", p, err)
+		}
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return 0, fmt.Errorf("error reading %s: %v", p, err)
+		}
+		lines, body, _ := splitFrontmatter(content)
+		id := newJexID()
+		properties := jexProperties(id, jexNote, info.ModTime())
+		properties["parent_id"] = folderIDs[filepath.Dir(p)]
+		properties["markup_language"] = "1"
+		properties["is_todo"] = "0"
+		text := mdLinkRegex.ReplaceAllStringFunc(string(body), func(link string) string {
+			target, err := url.PathUnescape(link[2 : len(link)-1])
+			if err != nil {
+				return link
+			}
+			if id, ok := resourceIDs[filepath.Join(filepath.Dir(p), filepath.FromSlash(target))]; ok {
+				return "](:/" + id + ")"
+			}
+			return link
+		})
+		title := strings.TrimSuffix(filepath.Base(p), filepath.Ext(p))
+		if err := w.write(id+".md", []byte(JexItem{title, strings.TrimRight(text, "\n"), properties}.String()), info.ModTime()); err != nil {
+			return 0, fmt.Errorf("error writing %s: %v", archivePath, err)
+		}
+
+		for _, tag := range frontmatterList(parseFrontmatter(lines), tagsField) {
+			tag = strings.ToLower(tag)
+			if tagIDs[tag] == "" {
+				tagIDs[tag] = newJexID()
+				item := JexItem{Title: tag, Properties: jexProperties(tagIDs[tag], jexTag, now)}
+				if err := w.write(tagIDs[tag]+".md", []byte(item.String()), now); err != nil {
+					return 0, fmt.Errorf("error writing %s: %v", archivePath, err)
+				}
+			}
+			linkID := newJexID()
+			properties := jexProperties(linkID, jexNoteTag, now)
+			properties["note_id"] = id
+			properties["tag_id"] = tagIDs[tag]
+			if err := w.write(linkID+".md", []byte(JexItem{Properties: properties}.String()), now); err != nil {
+				return 0, fmt.Errorf("error writing %s: %v", archivePath, err)
+			}
+		}
+	}
+	if err := w.archive.Close(); err != nil {
+		return 0, fmt.Errorf("error writing %s: %v", archivePath, err)
+	}
+	return len(notes), nil
+}
+
+func runJexSubcommand(args []string) error {
+	usage := fmt.Errorf("usage: notes jex export|import -d dir <file.jex>")
+	if len(args) == 0 {
+		return usage
+	}
+	flags := flag.NewFlagSet("jex", flag.ContinueOnError)
+	d := flags.String("d", "", "Path to directory with notes to export, or to import into")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if *d == "" || flags.NArg() != 1 {
+		return usage
+	}
+	archivePath := flags.Arg(0)
+
+	switch args[0] {
+	case "export":
+		count, err := exportJex(*d, archivePath)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Exported %d notes to %s\n", count, archivePath)
+	case "import":
+		count, err := importJex(archivePath, *d)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Imported %d notes to %s\n", count, *d)
+	default:
+		return usage
+	}
+	return nil
+}
@@ -0,0 +1,673 @@
+package main
+
+import (
+	"fmt"
+	"github.com/gdamore/tcell/v2"
+	"sort"
+	"strings"
+)
+
+// Focus identifies the pane receiving key presses. Prompts are modal and
+// handle their own keys in getUserInput and getConfirmation.
+type Focus int
+
+const (
+	FocusTree Focus = iota
+	FocusPreview
+	FocusReader
+	FocusOutline
+	FocusSearch
+	FocusBoard
+	FocusBackups
+	FocusRevisions
+)
+
+func (f Focus) String() string {
+	switch f {
+	case FocusPreview:
+		return "preview"
+	case FocusReader:
+		return "reader"
+	case FocusOutline:
+		return "outline"
+	case FocusSearch:
+		return "search"
+	case FocusBoard:
+		return "board"
+	case FocusBackups:
+		return "backups"
+	case FocusRevisions:
+		return "revisions"
+	default:
+		return "tree"
+	}
+}
+
+type Key struct {
+	Key  tcell.Key
+	Rune rune
+}
+
+func (k Key) String() string {
+	if k.Key == tcell.KeyRune {
+		if k.Rune == ' ' {
+			return "Space"
+		}
+		return string(k.Rune)
+	}
+	if name, ok := tcell.KeyNames[k.Key]; ok {
+		return name
+	}
+	return fmt.Sprintf("Key(%d)", k.Key)
+}
+
+// Parse a key written as a single character, Space, or a tcell key name such
+// as Enter, Esc, PgDn or Ctrl-C
+func parseKey(s string) (Key, error) {
+	if runes := []rune(s); len(runes) == 1 {
+		return runeKey(runes[0]), nil
+	}
+	if strings.EqualFold(s, "Space") {
+		return runeKey(' '), nil
+	}
+	for k, name := range tcell.KeyNames {
+		if strings.EqualFold(name, s) {
+			return specialKey(k), nil
+		}
+	}
+	return Key{}, fmt.Errorf("unknown key %q", s)
+}
+
+func keyOf(ev *tcell.EventKey) Key {
+	if ev.Key() == tcell.KeyRune {
+		return runeKey(ev.Rune())
+	}
+	return specialKey(ev.Key())
+}
+
+func runeKey(r rune) Key {
+	return Key{Key: tcell.KeyRune, Rune: r}
+}
+
+func specialKey(k tcell.Key) Key {
+	return Key{Key: k}
+}
+
+const splitRatioStep = 0.05
+
+// Columns the tree scrolls sideways per Left or Right
+const treeScrollStep = 4
+
+type Action struct {
+	Name string
+	Run  func(app *App) error
+}
+
+type Keymap map[Key]Action
+
+func (km Keymap) bind(action Action, keys ...Key) {
+	for _, key := range keys {
+		km[key] = action
+	}
+}
+
+// Group the bound keys by action name, both sorted for stable output
+func (km Keymap) actionKeys() ([]string, map[string][]string) {
+	keys := make(map[string][]string)
+	for key, action := range km {
+		keys[action.Name] = append(keys[action.Name], key.String())
+	}
+	names := make([]string, 0, len(keys))
+	for name := range keys {
+		sort.Strings(keys[name])
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, keys
+}
+
+// Apply user overrides from the config, given as focus name to action name to
+// key list, replacing all default keys of each overridden action
+func applyKeymapOverrides(keymaps map[Focus]Keymap, overrides map[string]map[string][]string) error {
+	for focusName, actions := range overrides {
+		var km Keymap
+		for focus, candidate := range keymaps {
+			if focus.String() == focusName {
+				km = candidate
+			}
+		}
+		if km == nil {
+			return fmt.Errorf("error in keymap: unknown pane %q", focusName)
+		}
+		for actionName, keyNames := range actions {
+			var action *Action
+			for key, candidate := range km {
+				if candidate.Name == actionName {
+					found := candidate
+					action = &found
+					delete(km, key)
+				}
+			}
+			if action == nil {
+				return fmt.Errorf("error in keymap: unknown action %q for pane %q", actionName, focusName)
+			}
+			for _, keyName := range keyNames {
+				key, err := parseKey(keyName)
+				if err != nil {
+					return fmt.Errorf("error in keymap for %s.%s: %v", focusName, actionName, err)
+				}
+				km.bind(*action, key)
+			}
+		}
+	}
+	return nil
+}
+
+var (
+	actionQuit = Action{"quit", func(app *App) error {
+		app.quit = true
+		return nil
+	}}
+	actionUp = Action{"up", func(app *App) error {
+		app.moveSelection(-1)
+		return nil
+	}}
+	actionDown = Action{"down", func(app *App) error {
+		app.moveSelection(1)
+		return nil
+	}}
+	actionFocusPreview = Action{"focus-preview", func(app *App) error {
+		app.previewHidden = false
+		app.setFocus(FocusPreview)
+		return nil
+	}}
+	actionTogglePreview = Action{"toggle-preview", func(app *App) error {
+		app.previewHidden = !app.previewHidden
+		app.setFocus(FocusTree)
+		return nil
+	}}
+	actionFocusTree = Action{"focus-tree", func(app *App) error {
+		app.setFocus(FocusTree)
+		return nil
+	}}
+	actionEdit = Action{"edit", func(app *App) error {
+		item := app.selectedItem()
+		if !isFile(item.Path) {
+			return nil
+		}
+		app.recordOperation("edit", item.Path)
+		return app.openEditor(item.Path)
+	}}
+	actionRename = Action{"rename", func(app *App) error {
+		if !app.confirmUnchanged(app.selectedItem().Path) {
+			return nil
+		}
+		app.recordOperation("rename", app.selectedItem().Path)
+		defer app.rebuild()
+		moves, err := handleRename(app.selectedItem(), app.rootItem.Path, app.screen)
+		moves = append(moves, moveSidecars(moves)...)
+		app.pushUndo(UndoStep{"rename", moves})
+		app.fireMoveHook(moves)
+		app.movePins(moves)
+		return err
+	}}
+	actionNew = Action{"new", func(app *App) error {
+		if !isDir(app.selectedItem().Path) {
+			return nil
+		}
+		app.recordOperation("new", app.selectedItem().Path)
+		defer app.rebuild()
+		path, err := handleNew(app.selectedItem(), app.rootItem.Path, app.screen)
+		if err != nil || path == "" {
+			return err
+		}
+		cursor, err := handleTemplate(path, app.rootItem.Path, app.screen)
+		app.fireHook(hookNoteCreated, path, "")
+		if err != nil || cursor == nil {
+			return err
+		}
+		return app.openEditor(path, cursor.vimArg())
+	}}
+	actionDelete = Action{"delete", func(app *App) error {
+		if !app.confirmUnchanged(app.selectedItem().Path) {
+			return nil
+		}
+		app.recordOperation("delete", app.selectedItem().Path)
+		defer app.rebuild()
+		path := app.selectedItem().Path
+		moves, err := handleDelete(app.selectedItem(), app.rootItem.Path, app.screen)
+		moves = append(moves, moveSidecars(moves)...)
+		app.pushUndo(UndoStep{"delete", moves})
+		if !isFile(path) && !isDir(path) {
+			app.fireHook(hookNoteDeleted, path, "")
+		}
+		return err
+	}}
+	actionMove = Action{"move", func(app *App) error {
+		if !app.confirmUnchanged(app.selectedItem().Path) {
+			return nil
+		}
+		app.recordOperation("move", app.selectedItem().Path)
+		defer app.rebuild()
+		moves, err := handleMove(app.selectedItem(), app.rootItem.Path, app.screen)
+		moves = append(moves, moveSidecars(moves)...)
+		app.pushUndo(UndoStep{"move", moves})
+		app.fireMoveHook(moves)
+		app.movePins(moves)
+		return err
+	}}
+	actionLabel = Action{"label", func(app *App) error {
+		defer app.rebuild()
+		return handleLabel(app.selectedItem(), app.screen)
+	}}
+	actionTreeLeft = Action{"scroll-tree-left", func(app *App) error {
+		app.scrollTreeHorizontally(-treeScrollStep)
+		return nil
+	}}
+	actionTreeRight = Action{"scroll-tree-right", func(app *App) error {
+		app.scrollTreeHorizontally(treeScrollStep)
+		return nil
+	}}
+	actionPin = Action{"pin", func(app *App) error {
+		return app.togglePin()
+	}}
+	actionAppendInbox = Action{"append-inbox", func(app *App) error {
+		return app.appendToInbox()
+	}}
+	actionGitStage = Action{"git-stage", func(app *App) error {
+		return app.gitStage(false)
+	}}
+	actionGitUnstage = Action{"git-unstage", func(app *App) error {
+		return app.gitStage(true)
+	}}
+	actionGitCommit = Action{"git-commit", func(app *App) error {
+		return app.gitCommit()
+	}}
+	actionBlame = Action{"blame", func(app *App) error {
+		return app.showBlame()
+	}}
+	actionSwitchVault = Action{"switch-vault", func(app *App) error {
+		return app.pickVault()
+	}}
+	actionMerge = Action{"merge", func(app *App) error {
+		return app.mergeNote()
+	}}
+	actionPrint = Action{"print", func(app *App) error {
+		return app.printNote()
+	}}
+	actionShare = Action{"share", func(app *App) error {
+		return app.shareNote()
+	}}
+	actionQRCode = Action{"qr-code", func(app *App) error {
+		return app.toggleQRCode()
+	}}
+	actionInsertSnippet = Action{"insert-snippet", func(app *App) error {
+		return app.insertSnippet()
+	}}
+	actionToggleMetadata = Action{"toggle-metadata", func(app *App) error {
+		app.showMetadata = !app.showMetadata
+		return nil
+	}}
+	actionToggleLayout = Action{"toggle-layout", func(app *App) error {
+		app.toggleLayout()
+		return nil
+	}}
+	actionShrinkTree = Action{"shrink-tree", func(app *App) error {
+		return app.adjustSplitRatio(-splitRatioStep)
+	}}
+	actionGrowTree = Action{"grow-tree", func(app *App) error {
+		return app.adjustSplitRatio(splitRatioStep)
+	}}
+	actionRead = Action{"read", func(app *App) error {
+		return app.openReader()
+	}}
+	actionCloseReader = Action{"close-reader", func(app *App) error {
+		app.closeReader()
+		return nil
+	}}
+	actionReaderUp = Action{"line-up", func(app *App) error {
+		app.scrollReader(-1)
+		return nil
+	}}
+	actionReaderDown = Action{"line-down", func(app *App) error {
+		app.scrollReader(1)
+		return nil
+	}}
+	actionReaderPageUp = Action{"page-up", func(app *App) error {
+		app.scrollReader(-app.readerArea().Height)
+		return nil
+	}}
+	actionReaderPageDown = Action{"page-down", func(app *App) error {
+		app.scrollReader(app.readerArea().Height)
+		return nil
+	}}
+	actionReaderTop = Action{"top", func(app *App) error {
+		app.reader.scroll = 0
+		return nil
+	}}
+	actionReaderBottom = Action{"bottom", func(app *App) error {
+		app.scrollReader(len(app.reader.lines))
+		return nil
+	}}
+	actionReaderSearch = Action{"search", func(app *App) error {
+		return app.searchReader()
+	}}
+	actionReaderNext = Action{"next-match", func(app *App) error {
+		return app.jumpToMatch(app.reader.scroll+1, 1)
+	}}
+	actionReaderPrev = Action{"previous-match", func(app *App) error {
+		return app.jumpToMatch(app.reader.scroll-1, -1)
+	}}
+	actionOutline = Action{"outline", func(app *App) error {
+		return app.openOutline()
+	}}
+	actionCloseOutline = Action{"close-outline", func(app *App) error {
+		app.closeOutline()
+		return nil
+	}}
+	actionOutlineUp = Action{"up", func(app *App) error {
+		app.moveOutlineSelection(-1)
+		return nil
+	}}
+	actionOutlineDown = Action{"down", func(app *App) error {
+		app.moveOutlineSelection(1)
+		return nil
+	}}
+	actionOutlineEdit = Action{"edit-at-heading", func(app *App) error {
+		return app.editAtHeading()
+	}}
+	actionPreviewSearch = Action{"search", func(app *App) error {
+		return app.searchPreview()
+	}}
+	actionPreviewNext = Action{"next-match", func(app *App) error {
+		return app.jumpToPreviewMatch(app.previewScroll+1, 1)
+	}}
+	actionPreviewPrev = Action{"previous-match", func(app *App) error {
+		return app.jumpToPreviewMatch(app.previewScroll-1, -1)
+	}}
+	actionSearch = Action{"search", func(app *App) error {
+		return app.promptSearch()
+	}}
+	actionCloseSearch = Action{"close-search", func(app *App) error {
+		app.closeSearch()
+		return nil
+	}}
+	actionSearchUp = Action{"up", func(app *App) error {
+		app.moveSearchSelection(-1)
+		return nil
+	}}
+	actionSearchDown = Action{"down", func(app *App) error {
+		app.moveSearchSelection(1)
+		return nil
+	}}
+	actionSearchOpen = Action{"edit-at-line", func(app *App) error {
+		return app.openSearchResult()
+	}}
+	actionCloseBackups = Action{"close-backups", func(app *App) error {
+		app.closeBackups()
+		return nil
+	}}
+	actionBackupsUp = Action{"up", func(app *App) error {
+		app.moveBackupSelection(-1)
+		return nil
+	}}
+	actionBackupsDown = Action{"down", func(app *App) error {
+		app.moveBackupSelection(1)
+		return nil
+	}}
+	actionRestoreBackup = Action{"restore-backup", func(app *App) error {
+		return app.restoreBackup()
+	}}
+	actionRevisions = Action{"revisions", func(app *App) error {
+		return app.openRevisions()
+	}}
+	actionCloseRevisions = Action{"close-revisions", func(app *App) error {
+		app.closeRevisions()
+		return nil
+	}}
+	actionRevisionsUp = Action{"up", func(app *App) error {
+		app.moveRevisionSelection(-1)
+		return nil
+	}}
+	actionRevisionsDown = Action{"down", func(app *App) error {
+		app.moveRevisionSelection(1)
+		return nil
+	}}
+	actionRevisionsPageUp = Action{"page-up", func(app *App) error {
+		_, height := app.screen.Size()
+		app.moveRevisionSelection(-(height - 2))
+		return nil
+	}}
+	actionRevisionsPageDown = Action{"page-down", func(app *App) error {
+		_, height := app.screen.Size()
+		app.moveRevisionSelection(height - 2)
+		return nil
+	}}
+	actionMarkRevision = Action{"mark-revision", func(app *App) error {
+		app.markRevision()
+		return nil
+	}}
+	actionDiffRevisions = Action{"diff-revisions", func(app *App) error {
+		return app.diffRevisions()
+	}}
+	actionBoard = Action{"board", func(app *App) error {
+		return app.openBoard()
+	}}
+	actionCloseBoard = Action{"close-board", func(app *App) error {
+		app.closeBoard()
+		return nil
+	}}
+	actionBoardLeft = Action{"previous-column", func(app *App) error {
+		app.moveBoardSelection(-1, 0)
+		return nil
+	}}
+	actionBoardRight = Action{"next-column", func(app *App) error {
+		app.moveBoardSelection(1, 0)
+		return nil
+	}}
+	actionBoardUp = Action{"up", func(app *App) error {
+		app.moveBoardSelection(0, -1)
+		return nil
+	}}
+	actionBoardDown = Action{"down", func(app *App) error {
+		app.moveBoardSelection(0, 1)
+		return nil
+	}}
+	actionBoardMoveLeft = Action{"move-card-left", func(app *App) error {
+		return app.moveBoardCard(-1)
+	}}
+	actionBoardMoveRight = Action{"move-card-right", func(app *App) error {
+		return app.moveBoardCard(1)
+	}}
+	actionBoardEdit = Action{"edit-at-card", func(app *App) error {
+		return app.editBoardCard()
+	}}
+	actionCommand  = Action{"command", handleCommand}
+	actionScrollUp = Action{"scroll-up", func(app *App) error {
+		app.scrollPreview(-1)
+		return nil
+	}}
+	actionScrollDown = Action{"scroll-down", func(app *App) error {
+		app.scrollPreview(1)
+		return nil
+	}}
+	actionPageUp = Action{"page-up", func(app *App) error {
+		_, height := app.screen.Size()
+		app.scrollPreview(-(height - 3))
+		return nil
+	}}
+	actionEncrypt = Action{"toggle-encryption", func(app *App) error {
+		return app.toggleEncryption()
+	}}
+	actionCopyPath = Action{"copy-path", func(app *App) error {
+		return app.copyPath(false)
+	}}
+	actionCopyAbsolutePath = Action{"copy-absolute-path", func(app *App) error {
+		return app.copyPath(true)
+	}}
+	actionPasteImage = Action{"paste-image", func(app *App) error {
+		return app.pasteImage()
+	}}
+	actionOpenExternally = Action{"open-externally", func(app *App) error {
+		return app.openExternally()
+	}}
+	actionMark = Action{"mark", func(app *App) error {
+		app.toggleMark()
+		return nil
+	}}
+	actionUndo = Action{"undo", func(app *App) error {
+		return app.undo()
+	}}
+	actionRedo = Action{"redo", func(app *App) error {
+		return app.redo()
+	}}
+	actionClearMarks = Action{"clear-marks", func(app *App) error {
+		app.marked = nil
+		return nil
+	}}
+	actionTag = Action{"tag", func(app *App) error {
+		paths := app.markedOrSelected()
+		app.recordOperation("tag", strings.Join(paths, " "))
+		defer app.rebuild()
+		if err := handleBulkTag(paths, app.screen); err != nil {
+			return err
+		}
+		app.marked = nil
+		return nil
+	}}
+	actionPageDown = Action{"page-down", func(app *App) error {
+		_, height := app.screen.Size()
+		app.scrollPreview(height - 3)
+		return nil
+	}}
+)
+
+func defaultKeymaps() map[Focus]Keymap {
+	tree := Keymap{}
+	tree.bind(actionUp, specialKey(tcell.KeyUp))
+	tree.bind(actionDown, specialKey(tcell.KeyDown))
+	tree.bind(actionQuit, specialKey(tcell.KeyEscape), specialKey(tcell.KeyCtrlC), runeKey('q'), runeKey('Q'))
+	tree.bind(actionFocusPreview, specialKey(tcell.KeyTab))
+	tree.bind(actionEdit, runeKey('e'), runeKey('E'))
+	tree.bind(actionRead, specialKey(tcell.KeyEnter))
+	tree.bind(actionOutline, runeKey('t'), runeKey('T'))
+	tree.bind(actionBoard, runeKey('b'), runeKey('B'))
+	tree.bind(actionRevisions, runeKey('h'), runeKey('H'))
+	tree.bind(actionSearch, runeKey('/'))
+	tree.bind(actionRename, runeKey('r'), runeKey('R'))
+	tree.bind(actionNew, runeKey('n'), runeKey('N'))
+	tree.bind(actionDelete, runeKey('d'), runeKey('D'))
+	tree.bind(actionMove, runeKey('m'), runeKey('M'))
+	tree.bind(actionLabel, runeKey('l'), runeKey('L'))
+	tree.bind(actionMark, runeKey(' '))
+	tree.bind(actionClearMarks, runeKey('U'))
+	tree.bind(actionUndo, runeKey('u'))
+	tree.bind(actionRedo, specialKey(tcell.KeyCtrlR))
+	tree.bind(actionTag, runeKey('#'))
+	tree.bind(actionEncrypt, runeKey('x'), runeKey('X'))
+	tree.bind(actionCopyPath, runeKey('y'))
+	tree.bind(actionCopyAbsolutePath, runeKey('Y'))
+	tree.bind(actionPasteImage, runeKey('i'), runeKey('I'))
+	tree.bind(actionOpenExternally, runeKey('O'))
+	tree.bind(actionTogglePreview, runeKey('p'), runeKey('P'))
+	tree.bind(actionToggleLayout, runeKey('|'))
+	tree.bind(actionToggleMetadata, runeKey('v'))
+	tree.bind(actionAppendInbox, runeKey('a'), runeKey('A'))
+	tree.bind(actionPin, runeKey('f'), runeKey('F'))
+	tree.bind(actionInsertSnippet, runeKey('s'), runeKey('S'))
+	tree.bind(actionGitStage, runeKey('g'))
+	tree.bind(actionGitUnstage, runeKey('G'))
+	tree.bind(actionGitCommit, runeKey('c'), runeKey('C'))
+	tree.bind(actionBlame, runeKey('W'))
+	tree.bind(actionSwitchVault, runeKey('V'))
+	tree.bind(actionMerge, runeKey('J'))
+	tree.bind(actionPrint, specialKey(tcell.KeyCtrlP))
+	tree.bind(actionShare, runeKey('w'))
+	tree.bind(actionQRCode, runeKey('K'))
+	tree.bind(actionTreeLeft, specialKey(tcell.KeyLeft))
+	tree.bind(actionTreeRight, specialKey(tcell.KeyRight))
+	tree.bind(actionShrinkTree, runeKey('<'))
+	tree.bind(actionGrowTree, runeKey('>'))
+	tree.bind(actionCommand, runeKey(':'))
+
+	preview := Keymap{}
+	preview.bind(actionScrollUp, specialKey(tcell.KeyUp))
+	preview.bind(actionScrollDown, specialKey(tcell.KeyDown))
+	preview.bind(actionPageUp, specialKey(tcell.KeyPgUp))
+	preview.bind(actionPageDown, specialKey(tcell.KeyPgDn))
+	preview.bind(actionFocusTree, specialKey(tcell.KeyTab), specialKey(tcell.KeyEscape))
+	preview.bind(actionPreviewSearch, runeKey('/'))
+	preview.bind(actionPreviewNext, runeKey('n'))
+	preview.bind(actionPreviewPrev, runeKey('N'))
+	preview.bind(actionTogglePreview, runeKey('p'), runeKey('P'))
+	preview.bind(actionToggleLayout, runeKey('|'))
+	preview.bind(actionAppendInbox, runeKey('a'), runeKey('A'))
+	preview.bind(actionQuit, specialKey(tcell.KeyCtrlC), runeKey('q'), runeKey('Q'))
+	preview.bind(actionCommand, runeKey(':'))
+
+	reader := Keymap{}
+	reader.bind(actionReaderUp, specialKey(tcell.KeyUp), runeKey('k'))
+	reader.bind(actionReaderDown, specialKey(tcell.KeyDown), runeKey('j'), specialKey(tcell.KeyEnter))
+	reader.bind(actionReaderPageUp, specialKey(tcell.KeyPgUp), runeKey('b'))
+	reader.bind(actionReaderPageDown, specialKey(tcell.KeyPgDn), runeKey(' '))
+	reader.bind(actionReaderTop, specialKey(tcell.KeyHome), runeKey('g'))
+	reader.bind(actionReaderBottom, specialKey(tcell.KeyEnd), runeKey('G'))
+	reader.bind(actionReaderSearch, runeKey('/'))
+	reader.bind(actionReaderNext, runeKey('n'))
+	reader.bind(actionReaderPrev, runeKey('N'))
+	reader.bind(actionCloseReader, specialKey(tcell.KeyEscape), runeKey('q'), runeKey('Q'))
+	reader.bind(actionQuit, specialKey(tcell.KeyCtrlC))
+
+	outline := Keymap{}
+	outline.bind(actionOutlineUp, specialKey(tcell.KeyUp))
+	outline.bind(actionOutlineDown, specialKey(tcell.KeyDown))
+	outline.bind(actionOutlineEdit, specialKey(tcell.KeyEnter))
+	outline.bind(actionCloseOutline, specialKey(tcell.KeyEscape), runeKey('t'), runeKey('T'), runeKey('q'), runeKey('Q'))
+	outline.bind(actionQuit, specialKey(tcell.KeyCtrlC))
+
+	search := Keymap{}
+	search.bind(actionSearchUp, specialKey(tcell.KeyUp))
+	search.bind(actionSearchDown, specialKey(tcell.KeyDown))
+	search.bind(actionSearchOpen, specialKey(tcell.KeyEnter))
+	search.bind(actionCloseSearch, specialKey(tcell.KeyEscape), runeKey('q'), runeKey('Q'))
+	search.bind(actionQuit, specialKey(tcell.KeyCtrlC))
+
+	board := Keymap{}
+	board.bind(actionBoardLeft, specialKey(tcell.KeyLeft), runeKey('h'))
+	board.bind(actionBoardRight, specialKey(tcell.KeyRight), runeKey('l'))
+	board.bind(actionBoardUp, specialKey(tcell.KeyUp), runeKey('k'))
+	board.bind(actionBoardDown, specialKey(tcell.KeyDown), runeKey('j'))
+	board.bind(actionBoardMoveLeft, runeKey('<'), runeKey('H'))
+	board.bind(actionBoardMoveRight, runeKey('>'), runeKey('L'))
+	board.bind(actionBoardEdit, specialKey(tcell.KeyEnter))
+	board.bind(actionCloseBoard, specialKey(tcell.KeyEscape), runeKey('q'), runeKey('Q'))
+	board.bind(actionQuit, specialKey(tcell.KeyCtrlC))
+
+	backups := Keymap{}
+	backups.bind(actionBackupsUp, specialKey(tcell.KeyUp))
+	backups.bind(actionBackupsDown, specialKey(tcell.KeyDown))
+	backups.bind(actionRestoreBackup, specialKey(tcell.KeyEnter))
+	backups.bind(actionCloseBackups, specialKey(tcell.KeyEscape), runeKey('q'), runeKey('Q'))
+	backups.bind(actionQuit, specialKey(tcell.KeyCtrlC))
+
+	revisions := Keymap{}
+	revisions.bind(actionRevisionsUp, specialKey(tcell.KeyUp), runeKey('k'))
+	revisions.bind(actionRevisionsDown, specialKey(tcell.KeyDown), runeKey('j'))
+	revisions.bind(actionRevisionsPageUp, specialKey(tcell.KeyPgUp))
+	revisions.bind(actionRevisionsPageDown, specialKey(tcell.KeyPgDn))
+	revisions.bind(actionMarkRevision, runeKey(' '))
+	revisions.bind(actionDiffRevisions, specialKey(tcell.KeyEnter))
+	revisions.bind(actionCloseRevisions, specialKey(tcell.KeyEscape), runeKey('q'), runeKey('Q'))
+	revisions.bind(actionQuit, specialKey(tcell.KeyCtrlC))
+
+	return map[Focus]Keymap{
+		FocusTree:      tree,
+		FocusPreview:   preview,
+		FocusReader:    reader,
+		FocusOutline:   outline,
+		FocusSearch:    search,
+		FocusBoard:     board,
+		FocusBackups:   backups,
+		FocusRevisions: revisions,
+	}
+}
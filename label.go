@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"github.com/gdamore/tcell/v2"
+	"sort"
+	"strings"
+)
+
+const labelField = "color"
+
+var labelColors = map[string]tcell.Color{
+	"red":    tcell.ColorRed,
+	"orange": tcell.ColorOrange,
+	"yellow": tcell.ColorYellow,
+	"green":  tcell.ColorGreen,
+	"blue":   tcell.ColorBlue,
+	"purple": tcell.ColorPurple,
+	"gray":   tcell.ColorGray,
+}
+
+func labelNames() []string {
+	names := make([]string, 0, len(labelColors))
+	for name := range labelColors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func handleLabel(item TreeItem, screen tcell.Screen) error {
+	if !isFile(item.Path) {
+		return userErr{"Only notes can be labeled"}
+	}
+	prompt := "Label (" + strings.Join(labelNames(), ", ") + ", empty to clear): "
+	label, ok := getUserInput(prompt, item.Label, screen)
+	if !ok || label == item.Label {
+		return nil
+	}
+	label = strings.ToLower(strings.TrimSpace(label))
+
+	var value any
+	if label != "" {
+		if _, known := labelColors[label]; !known {
+			return userErr{fmt.Sprintf("Unknown label: %s", label)}
+		}
+		value = label
+	}
+	if metadataSidecars {
+		return updateSidecarField(item.Path, labelField, value)
+	}
+	if err := updateFrontmatterField(item.Path, labelField, value); err != nil {
+		return fmt.Errorf("error writing label to %s: %v", item.Path, err)
+	}
+	return nil
+}
+
+func runFilterLabel(app *App, args []string) error {
+	if len(args) == 0 {
+		app.labelFilter = ""
+		app.rebuild()
+		return nil
+	}
+	label := strings.ToLower(args[0])
+	if _, known := labelColors[label]; !known {
+		return userErr{fmt.Sprintf("Unknown label: %s", label)}
+	}
+	app.labelFilter = label
+	app.currentSelection = 0
+	app.rebuild()
+	return nil
+}
+
+// Keep only the files accepted by keep and the directories leading to them
+func filterTree(item TreeItem, keep func(TreeItem) bool) (TreeItem, bool) {
+	if len(item.Children) == 0 {
+		return item, !item.IsDir && keep(item)
+	}
+	var children []TreeItem
+	for _, child := range item.Children {
+		if filtered, ok := filterTree(child, keep); ok {
+			children = append(children, filtered)
+		}
+	}
+	for i := range children {
+		children[i].IsLast = i == len(children)-1
+	}
+	item.Children = children
+	return item, len(children) > 0
+}
@@ -0,0 +1,103 @@
+package main
+
+type Rect struct {
+	X      int
+	Y      int
+	Width  int
+	Height int
+}
+
+type LayoutMode string
+
+const (
+	LayoutVertical   LayoutMode = "vertical"
+	LayoutHorizontal LayoutMode = "horizontal"
+)
+
+// Layout holds the screen areas of the panes. Separator and header positions
+// are -1 when they aren't drawn.
+type Layout struct {
+	HeaderY          int
+	Tree             Rect
+	Preview          Rect
+	SeparatorX       int
+	SeparatorY       int
+	FooterSeparatorY int
+	FooterY          int
+	VaultStatusY     int
+}
+
+func (app *App) layout() Layout {
+	width, height := app.screen.Size()
+	horizontal := separatorRune(app.config.HorizontalSeparator)
+	bodyHeight := height - 1
+	l := Layout{
+		HeaderY:          -1,
+		SeparatorX:       -1,
+		SeparatorY:       -1,
+		FooterSeparatorY: -1,
+		FooterY:          height - 1,
+		VaultStatusY:     -1,
+	}
+	if app.config.VaultStatusLine != "" {
+		bodyHeight--
+		l.FooterY--
+		l.VaultStatusY = height - 1
+	}
+	if horizontal != 0 {
+		bodyHeight--
+		l.FooterSeparatorY = l.FooterY - 1
+	}
+	top := 0
+	if app.config.Breadcrumbs {
+		l.HeaderY = 0
+		top = 1
+		bodyHeight--
+	}
+	l.Tree = Rect{0, top, width, bodyHeight}
+	if app.previewHidden {
+		return l
+	}
+
+	padding := app.config.PreviewPadding
+	if app.layoutMode == LayoutHorizontal {
+		treeHeight := max(int(float64(bodyHeight)*app.splitRatio()), 3)
+		l.Tree.Height = treeHeight
+		previewY := top + treeHeight
+		if horizontal != 0 {
+			l.SeparatorY = previewY
+			previewY++
+		}
+		// Without a separator on the left the preview is indented one column less
+		previewX := max(padding-1, 0)
+		l.Preview = Rect{previewX, previewY, width - previewX, top + bodyHeight - previewY}
+		return l
+	}
+
+	separatorX := int(float64(width) * app.splitRatio())
+	l.Tree.Width = separatorX
+	previewX := separatorX + padding
+	if separatorRune(app.config.VerticalSeparator) != 0 {
+		l.SeparatorX = separatorX
+		previewX++
+	}
+	// The preview starts a line down, which the header takes when it's shown
+	l.Preview = Rect{previewX, 1, width - previewX, top + bodyHeight - 1}
+	return l
+}
+
+// The character a separator setting draws with, 0 when it's turned off
+func separatorRune(separator string) rune {
+	for _, r := range separator {
+		return r
+	}
+	return 0
+}
+
+func (app *App) toggleLayout() {
+	if app.layoutMode == LayoutHorizontal {
+		app.layoutMode = LayoutVertical
+	} else {
+		app.layoutMode = LayoutHorizontal
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const minLinkPhraseLength = 3
+
+// A place where a note mentions another note by name or alias without linking it
+type linkSuggestion struct {
+	source string
+	line   int
+	target string
+	phrase string
+	text   string
+}
+
+// The phrases a note is known by: its file name with dashes and underscores as
+// spaces, plus the frontmatter title and aliases
+func notePhrases(path string) []string {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	fields := noteFields(path)
+	phrases := []string{strings.NewReplacer("-", " ", "_", " ").Replace(name)}
+	if title := frontmatterString(fields, "title"); title != "" {
+		phrases = append(phrases, title)
+	}
+	phrases = append(phrases, frontmatterList(fields, "aliases")...)
+
+	var result []string
+	for _, phrase := range phrases {
+		if len([]rune(phrase)) >= minLinkPhraseLength && !containsFold(result, phrase) {
+			result = append(result, phrase)
+		}
+	}
+	return result
+}
+
+func phraseRegex(phrase string) *regexp.Regexp {
+	return regexp.MustCompile(`(?i)\b` + regexp.QuoteMeta(phrase) + `\b`)
+}
+
+// Find the first occurrence of re in line that isn't already part of a link,
+// returning its byte range
+func unlinkedMatch(re *regexp.Regexp, line string) (int, int, bool) {
+	for _, m := range re.FindAllStringIndex(line, -1) {
+		before := line[:m[0]]
+		if strings.Count(before, "[") > strings.Count(before, "]") || strings.HasSuffix(before, "](") {
+			continue
+		}
+		return m[0], m[1], true
+	}
+	return 0, 0, false
+}
+
+// Suggest at most one link per pair of notes, skipping notes that already link
+// to the target
+func findLinkSuggestions(index *Index) []linkSuggestion {
+	var suggestions []linkSuggestion
+	for _, targetRel := range sortedFiles(index.Files) {
+		if filepath.Ext(targetRel) != ".md" {
+			continue
+		}
+		target := filepath.Join(index.Root, targetRel)
+		linked := make(map[string]bool)
+		for _, backlink := range index.backlinks(target) {
+			linked[backlink.Path] = true
+		}
+		for _, phrase := range notePhrases(target) {
+			re := phraseRegex(phrase)
+			for _, source := range index.candidates(phrase) {
+				if source == target || linked[source] || filepath.Ext(source) != ".md" {
+					continue
+				}
+				if suggestion, ok := findMention(source, target, phrase, re); ok {
+					suggestions = append(suggestions, suggestion)
+					linked[source] = true
+				}
+			}
+		}
+	}
+	return suggestions
+}
+
+func findMention(source string, target string, phrase string, re *regexp.Regexp) (linkSuggestion, bool) {
+	content, err := os.ReadFile(source)
+	if err != nil {
+		return linkSuggestion{}, false
+	}
+	lines := strings.Split(string(content), "\n")
+	start := 0
+	if frontmatter, _, ok := splitFrontmatter(content); ok {
+		start = len(frontmatter) + 2
+	}
+	for i := start; i < len(lines); i++ {
+		if _, _, ok := unlinkedMatch(re, lines[i]); ok {
+			return linkSuggestion{source: source, line: i + 1, target: target, phrase: phrase, text: strings.TrimSpace(lines[i])}, true
+		}
+	}
+	return linkSuggestion{}, false
+}
+
+// Turn the mention into a wiki link, keeping the original wording as the link
+// text when it differs from the note name
+func applyLinkSuggestion(s linkSuggestion) error {
+	content, err := os.ReadFile(s.source)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %v", s.source, err)
+	}
+	lines := strings.Split(string(content), "\n")
+	if s.line > len(lines) {
+		return nil
+	}
+	line := lines[s.line-1]
+	start, end, ok := unlinkedMatch(phraseRegex(s.phrase), line)
+	if !ok {
+		return nil
+	}
+	name := strings.TrimSuffix(filepath.Base(s.target), filepath.Ext(s.target))
+	link := "[[" + name + "]]"
+	if !strings.EqualFold(line[start:end], name) {
+		link = "[[" + name + "|" + line[start:end] + "]]"
+	}
+	lines[s.line-1] = line[:start] + link + line[end:]
+
+	info, err := os.Stat(s.source)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %v", s.source, err)
+	}
+	if err := os.WriteFile(s.source, []byte(strings.Join(lines, "\n")), info.Mode()); err != nil {
+		return fmt.Errorf("error writing %s: %v", s.source, err)
+	}
+	return nil
+}
+
+// Walk through the suggested links one by one, asking whether to insert each
+func runSuggestLinks(app *App, args []string) error {
+	if app.index == nil {
+		return userErr{"The index isn't available"}
+	}
+	suggestions := findLinkSuggestions(app.index)
+	if len(suggestions) == 0 {
+		return userErr{"No link suggestions"}
+	}
+	defer app.rebuild()
+	backedUp := make(map[string]bool)
+	for i, s := range suggestions {
+		app.selectPath(s.source)
+		app.render()
+		prompt := fmt.Sprintf("[%d/%d] Link %q in %s:%d to %s? (y/n/q): %s", i+1, len(suggestions),
+			s.phrase, app.relativePath(s.source), s.line, app.relativePath(s.target), s.text)
+		switch getChoice(prompt, "ynq", app.screen) {
+		case 'y':
+			app.recordOperation("link", s.source+" "+s.target)
+			if !backedUp[s.source] {
+				if err := app.backup("link", s.source); err != nil {
+					return err
+				}
+				backedUp[s.source] = true
+			}
+			if err := applyLinkSuggestion(s); err != nil {
+				return err
+			}
+		case 'q', 0:
+			return nil
+		}
+	}
+	return nil
+}
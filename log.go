@@ -0,0 +1,50 @@
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var logger = log.New(io.Discard, "", log.LstdFlags)
+
+func logFilePath() string {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(cacheDir, "notes", "notes.log")
+}
+
+// Direct the logger to the log file, keeping it discarded when the file can't be opened
+func initLog() func() {
+	path := logFilePath()
+	if path == "" {
+		return func() {}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return func() {}
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return func() {}
+	}
+	logger.SetOutput(file)
+	return func() {
+		_ = file.Close()
+	}
+}
+
+func tailLog(n int) []string {
+	content, err := os.ReadFile(logFilePath())
+	if err != nil {
+		return nil
+	}
+	lines := strings.Split(strings.TrimRight(string(content), "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines
+}
@@ -9,22 +9,93 @@ import (
 	markdown "github.com/MichaelMure/go-term-markdown"
 	"github.com/gdamore/tcell/v2"
 	"github.com/mattn/go-runewidth"
+	"io/fs"
 	"os"
 	"os/exec"
 	"os/signal"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"syscall"
+	"time"
+	"unicode"
 )
 
+var version = "dev"
+
 func main() {
+	if len(os.Args) > 1 {
+		if subcommand, ok := subcommands()[os.Args[1]]; ok {
+			if err := subcommand.Run(os.Args[2:]); err != nil {
+				exitWithError(err)
+			}
+			return
+		}
+	}
+
 	d := flag.String("d", "", "Path to directory with notes")
+	v := flag.String("v", "", "Name of a vault from the config to open")
+	readOnly := flag.Bool("read-only", false, "Browse without changing any files")
 	flag.Parse()
-	if *d == "" {
+
+	closeLog := initLog()
+	defer closeLog()
+
+	config, err := loadConfig(configFilePath())
+	if err != nil {
+		exitWithError(err)
+	}
+	dir, vaultName := *d, ""
+	var remote *RemoteVault
+	if dir == "" && *v == "" && len(config.Vaults) > 0 {
+		*v = config.Vaults[0].Name
+	}
+	if dir == "" && *v != "" {
+		vault, ok := findVault(config.Vaults, *v)
+		if !ok {
+			exitWithError(fmt.Errorf("error: no vault named %s in the config", *v))
+		}
+		dir, remote, err = openVault(vault)
+		if err != nil {
+			exitWithError(err)
+		}
+		vaultName = vault.Name
+	}
+	if dir == "" {
 		fmt.Println("Error: no directory provided. Use -d to specify a directory.")
 		os.Exit(1)
 	}
-	dir := *d
+	if *readOnly {
+		config.ReadOnly = true
+	}
+	keymaps := defaultKeymaps()
+	if err := applyKeymapOverrides(keymaps, config.Keymap); err != nil {
+		exitWithError(err)
+	}
+	if err := bindUserCommands(keymaps, config.UserCommands); err != nil {
+		exitWithError(err)
+	}
+	encryption.ageIdentity = config.AgeIdentity
+	encryption.ageRecipients = config.AgeRecipients
+	encryption.gpgRecipients = config.GPGRecipients
+	previewers = config.Previewers
+	theme = mustTheme(resolveThemeName(config.Theme), config.Colors)
+	mouseEnabled = config.Mouse
+	tocMinHeadings = config.TOCMinHeadings
+	folderCounts = config.FolderCounts
+	noteNaming.datePrefix = config.DatePrefixNames
+	noteNaming.slug = config.SlugNames
+	noteNaming.extension = config.DefaultExtension
+	metadataSidecars = config.MetadataSidecars
+	icons, _ = buildIcons(config.Icons, config.IconOverrides)
+	if config.LockedDir != "" {
+		encryption.lockedDir = filepath.Join(dir, config.LockedDir)
+	}
+	vaultConfig, err := loadVaultConfig(dir)
+	if err != nil {
+		exitWithError(err)
+	}
 
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
@@ -33,13 +104,37 @@ func main() {
 	if err != nil {
 		exitWithError(err)
 	}
+	tools := detectTools()
+	app := &App{
+		screen:         screen,
+		dir:            dir,
+		vaultName:      vaultName,
+		remote:         remote,
+		config:         config,
+		state:          loadState(stateFilePath()),
+		layoutMode:     config.Layout,
+		showMetadata:   config.ShowMetadata,
+		focus:          FocusTree,
+		keymaps:        keymaps,
+		commands:       defaultCommands(),
+		tools:          tools,
+		notice:         missingToolsNotice(tools),
+		fieldFilters:   vaultConfig.fieldFilters(),
+		sortField:      vaultConfig.SortField,
+		sortDescending: vaultConfig.SortDescending,
+		index:          loadIndex(dir),
+		indexDone:      make(chan *Index, 1),
+		remoteDone:     make(chan remoteEvent, 1),
+		tmuxEdits:      make(chan tmuxEditEvent, maxTmuxEdits),
+	}
 	defer func() {
-		resetScreen(screen)
+		resetScreen(app.screen)
+		app.fireHook(hookAppExit, "", "")
 	}()
 
 	go func() {
 		<-sigChan
-		resetScreen(screen)
+		resetScreen(app.screen)
 		os.Exit(0)
 	}()
 
@@ -47,69 +142,19 @@ func main() {
 		exitWithError(errors.New("error: not a directory"))
 	}
 
-	rootItem := buildTree(dir)
-	flatTree := flattenTree(rootItem, []bool{})
-	var currentSelection = new(int)
-	*currentSelection = 0
-
-	for {
-		renderTree(flatTree, currentSelection, screen)
-		ev := screen.PollEvent()
-		switch ev := ev.(type) {
-		case *tcell.EventKey:
-			switch ev.Key() {
-			case tcell.KeyUp:
-				if *currentSelection > 0 {
-					*currentSelection--
-				}
-			case tcell.KeyDown:
-				if *currentSelection < len(flatTree)-1 {
-					*currentSelection++
-				}
-			case tcell.KeyEscape, tcell.KeyCtrlC:
-				return
-			case tcell.KeyRune:
-				switch ev.Rune() {
-				case 'Q', 'q':
-					return
-				case 'E', 'e':
-					if isFile(flatTree[*currentSelection].Path) {
-						screen, err = openVim(flatTree[*currentSelection].Path, screen)
-						if err != nil {
-							exitWithError(err)
-						}
-						flatTree = rebuildTree(dir, currentSelection)
-					}
-				case 'R', 'r':
-					err = handleRename(flatTree[*currentSelection], screen)
-					if err != nil {
-						handleError(err, screen)
-					}
-					flatTree = rebuildTree(dir, currentSelection)
-				case 'N', 'n':
-					if isDir(flatTree[*currentSelection].Path) {
-						err = handleNew(flatTree[*currentSelection], rootItem.Path, screen)
-						if err != nil {
-							handleError(err, screen)
-						}
-						flatTree = rebuildTree(dir, currentSelection)
-					}
-				case 'D', 'd':
-					err = handleDelete(flatTree[*currentSelection], rootItem.Path, screen)
-					if err != nil {
-						handleError(err, screen)
-					}
-					flatTree = rebuildTree(dir, currentSelection)
-				case 'M', 'm':
-					err = handleMove(flatTree[*currentSelection], rootItem.Path, screen)
-					if err != nil {
-						handleError(err, screen)
-					}
-					flatTree = rebuildTree(dir, currentSelection)
-				}
-			}
+	if encryption.lockedDir != "" {
+		app.vaultLocked = true
+		if !app.unlockVault() {
+			return
 		}
 	}
+	app.rebuild()
+	app.updateTitle()
+	if config.CheckUpdates {
+		checkForUpdate(app.screen)
+	}
+	checkVaultSize(dir, config, app.screen)
+	app.run()
 }
 
 type TreeItem struct {
@@ -117,7 +162,17 @@ type TreeItem struct {
 	Path     string
 	Children []TreeItem
 	IsLast   bool
+	IsDir    bool
 	Prefixes []bool
+	Label    string
+	Fields   map[string]any
+	Pinned   bool
+	// Tasks in the note and how many are done
+	Tasks     int
+	TasksDone int
+	// Notes and directories below a directory, outside hidden directories
+	Notes   int
+	Subdirs int
 }
 
 type ColData struct {
@@ -126,33 +181,66 @@ type ColData struct {
 }
 
 type TextStyle struct {
-	Bold       bool
-	Underline  bool
-	Foreground tcell.Color
-	Background tcell.Color
+	Bold          bool
+	Dim           bool
+	Italic        bool
+	Underline     bool
+	Reverse       bool
+	Strikethrough bool
+	Foreground    tcell.Color
+	Background    tcell.Color
+	URL           string
 }
 
-func rebuildTree(dir string, currentSelection *int) []TreeItem {
-	rootItem := buildTree(dir)
-	flatTree := flattenTree(rootItem, []bool{})
-	if *currentSelection >= len(flatTree) {
-		*currentSelection = len(flatTree) - 1
-	}
-	return flatTree
+// Directories notes keeps in the vault for itself. They're left out of the
+// tree, which would otherwise read every deleted, backed up and revised note
+// on each rebuild.
+var appDirNames = []string{backupsDirName, revisionsDirName, templatesDirName}
+
+func isAppDir(name string) bool {
+	return slices.Contains(appDirNames, name)
+}
+
+// Walk the notes under root like filepath.WalkDir. Hidden files and
+// directories, which include the app directories, and encrypted notes are
+// left out, so every feature reading the whole vault sees the same notes.
+func walkNotes(root string, fn fs.WalkDirFunc) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && path != root {
+			if strings.HasPrefix(d.Name(), ".") {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !d.IsDir() && isEncryptedFile(path) {
+				return nil
+			}
+		}
+		return fn(path, d, err)
+	})
 }
 
-func buildTree(path string) TreeItem {
+func buildTree(path string, order TreeSort) TreeItem {
 	rootItem := TreeItem{
 		Display: filepath.Base(path),
 		Path:    path,
 		IsLast:  true,
+		IsDir:   true,
 	}
 
 	entries, err := os.ReadDir(path)
 	if err != nil {
 		return rootItem
 	}
+	sortEntries(entries, order)
 
+	entries = slices.DeleteFunc(entries, func(entry os.DirEntry) bool {
+		if entry.IsDir() {
+			return isAppDir(entry.Name())
+		}
+		return metadataSidecars && isSidecar(entry.Name())
+	})
 	numEntries := len(entries)
 	for i, entry := range entries {
 		itemPath := filepath.Join(path, entry.Name())
@@ -164,10 +252,19 @@ func buildTree(path string) TreeItem {
 		}
 
 		if entry.IsDir() {
-			childItem = buildTree(itemPath)
+			childItem = buildTree(itemPath, order)
 			childItem.Display = entry.Name()
 			childItem.Path = itemPath
 			childItem.IsLast = isLastEntry
+			if !strings.HasPrefix(entry.Name(), ".") {
+				rootItem.Notes += childItem.Notes
+				rootItem.Subdirs += childItem.Subdirs + 1
+			}
+		} else {
+			rootItem.Notes++
+			childItem.Fields = noteFields(itemPath)
+			childItem.Label = frontmatterString(childItem.Fields, labelField)
+			childItem.Tasks, childItem.TasksDone = countTasks(itemPath)
 		}
 
 		rootItem.Children = append(rootItem.Children, childItem)
@@ -234,42 +331,92 @@ func resolveAndValidatePath(inputPath string, rootItemPath string) (string, erro
 	return resolvedPath, nil
 }
 
-func renderMarkdown(x, y int, content []byte, screen tcell.Screen) {
+// Render ANSI styled content clipped to area, skipping the first offset lines
+// and highlighting occurrences of highlight, and return the total number of lines
+func renderMarkdown(area Rect, offset int, highlight string, content []byte, screen tcell.Screen) int {
 	scanner := bufio.NewScanner(bytes.NewReader(content))
-	row := y
+	row := area.Y
+	lineCount := 0
 	for scanner.Scan() {
 		line := scanner.Text()
+		lineCount++
+		if lineCount <= offset || row >= area.Y+area.Height {
+			continue
+		}
 		cols := processANSIStrings(line)
-		col := x
+		col := area.X
 		for _, colData := range cols {
 			style := tcell.StyleDefault
 			if colData.Style.Bold {
 				style = style.Bold(true)
 			}
+			if colData.Style.Dim {
+				style = style.Dim(true)
+			}
+			if colData.Style.Italic {
+				style = style.Italic(true)
+			}
 			if colData.Style.Underline {
 				style = style.Underline(true)
 			}
+			if colData.Style.Reverse {
+				style = style.Reverse(true)
+			}
+			if colData.Style.Strikethrough {
+				style = style.StrikeThrough(true)
+			}
+			if colData.Style.URL != "" {
+				style = style.Url(colData.Style.URL)
+			}
 			style = style.Foreground(colData.Style.Foreground)
 			style = style.Background(colData.Style.Background)
-			for _, r := range colData.Text {
-				screen.SetContent(col, row, r, nil, style)
+			matches := highlightMask(colData.Text, highlight)
+			for i, r := range []rune(colData.Text) {
+				if col+runewidth.RuneWidth(r) > area.X+area.Width {
+					break
+				}
+				runeStyle := style
+				if matches[i] {
+					runeStyle = style.Reverse(true)
+				}
+				screen.SetContent(col, row, r, nil, runeStyle)
 				col += runewidth.RuneWidth(r)
 			}
 		}
 		row++
 	}
+	return lineCount
 }
 
 func parseANSICode(code string, style TextStyle) TextStyle {
 	parts := strings.Split(code, ";")
-	for _, part := range parts {
-		switch part {
+	for i := 0; i < len(parts); i++ {
+		switch part := parts[i]; part {
 		case "0":
-			style = TextStyle{}
+			style = TextStyle{URL: style.URL}
 		case "1":
 			style.Bold = true
+		case "2":
+			style.Dim = true
+		case "3":
+			style.Italic = true
 		case "4":
 			style.Underline = true
+		case "7":
+			style.Reverse = true
+		case "9":
+			style.Strikethrough = true
+		case "22":
+			style.Bold = false
+			style.Dim = false
+		case "23":
+			style.Italic = false
+		case "24":
+			style.Underline = false
+		case "27":
+			style.Reverse = false
+		case "29":
+			style.Strikethrough = false
 		case "30":
 			style.Foreground = tcell.ColorBlack
 		case "31":
@@ -286,12 +433,90 @@ func parseANSICode(code string, style TextStyle) TextStyle {
 			style.Foreground = tcell.ColorTeal
 		case "37":
 			style.Foreground = tcell.ColorSilver
+		case "40":
+			style.Background = tcell.ColorBlack
+		case "41":
+			style.Background = tcell.ColorMaroon
+		case "42":
+			style.Background = tcell.ColorGreen
+		case "43":
+			style.Background = tcell.ColorOlive
+		case "44":
+			style.Background = tcell.ColorNavy
+		case "45":
+			style.Background = tcell.ColorPurple
+		case "46":
+			style.Background = tcell.ColorTeal
+		case "47":
+			style.Background = tcell.ColorSilver
+		case "49":
+			style.Background = tcell.ColorDefault
+		case "100":
+			style.Background = tcell.ColorGray
+		case "101":
+			style.Background = tcell.ColorRed
+		case "102":
+			style.Background = tcell.ColorLime
+		case "103":
+			style.Background = tcell.ColorYellow
+		case "104":
+			style.Background = tcell.ColorBlue
+		case "105":
+			style.Background = tcell.ColorFuchsia
+		case "106":
+			style.Background = tcell.ColorAqua
+		case "107":
+			style.Background = tcell.ColorWhite
+		case "38", "48":
+			color, consumed, ok := parseExtendedColor(parts[i+1:])
+			i += consumed
+			if !ok {
+				continue
+			}
+			if part == "38" {
+				style.Foreground = color
+			} else {
+				style.Background = color
+			}
 		default:
 		}
 	}
 	return style
 }
 
+// Parse the arguments following 38/48, either 5;N for a 256-color palette index
+// or 2;R;G;B for truecolor, returning how many parts were consumed
+func parseExtendedColor(parts []string) (tcell.Color, int, bool) {
+	if len(parts) == 0 {
+		return tcell.ColorDefault, 0, false
+	}
+	switch parts[0] {
+	case "5":
+		if len(parts) < 2 {
+			return tcell.ColorDefault, len(parts), false
+		}
+		n, err := strconv.Atoi(parts[1])
+		if err != nil || n < 0 || n > 255 {
+			return tcell.ColorDefault, 2, false
+		}
+		return tcell.PaletteColor(n), 2, true
+	case "2":
+		if len(parts) < 4 {
+			return tcell.ColorDefault, len(parts), false
+		}
+		var rgb [3]int32
+		for j := range rgb {
+			n, err := strconv.Atoi(parts[j+1])
+			if err != nil || n < 0 || n > 255 {
+				return tcell.ColorDefault, 4, false
+			}
+			rgb[j] = int32(n)
+		}
+		return tcell.NewRGBColor(rgb[0], rgb[1], rgb[2]), 4, true
+	}
+	return tcell.ColorDefault, 1, false
+}
+
 func processANSIStrings(s string) []ColData {
 	var cols []ColData
 	var currentStyle TextStyle
@@ -313,6 +538,22 @@ func processANSIStrings(s string) []ColData {
 			seq := s[i+2 : i+seqEnd]
 			currentStyle = parseANSICode(seq, currentStyle)
 			i += seqEnd + 1
+		} else if s[i] == '\x1b' && i+1 < len(s) && s[i+1] == ']' {
+			if textBuilder.Len() > 0 {
+				cols = append(cols, ColData{
+					Text:  textBuilder.String(),
+					Style: currentStyle,
+				})
+				textBuilder.Reset()
+			}
+			body, length := parseOSC(s[i:])
+			if length == 0 {
+				break
+			}
+			if url, ok := parseOSC8(body); ok {
+				currentStyle.URL = url
+			}
+			i += length
 		} else {
 			textBuilder.WriteByte(s[i])
 			i++
@@ -327,6 +568,60 @@ func processANSIStrings(s string) []ColData {
 	return cols
 }
 
+// Mark the runes of text that are part of a case-insensitive match of term
+func highlightMask(text string, term string) []bool {
+	runes := []rune(text)
+	mask := make([]bool, len(runes))
+	if term == "" {
+		return mask
+	}
+	lower := []rune(strings.ToLower(text))
+	needle := []rune(strings.ToLower(term))
+	if len(lower) != len(runes) {
+		return mask
+	}
+	for i := 0; i+len(needle) <= len(lower); i++ {
+		if string(lower[i:i+len(needle)]) == string(needle) {
+			for j := i; j < i+len(needle); j++ {
+				mask[j] = true
+			}
+		}
+	}
+	return mask
+}
+
+// Strip ANSI sequences, leaving the plain text of a rendered line
+func plainText(line string) string {
+	var b strings.Builder
+	for _, col := range processANSIStrings(line) {
+		b.WriteString(col.Text)
+	}
+	return b.String()
+}
+
+// Extract the body of an OSC sequence at the start of s, terminated either by
+// BEL or ST, returning the total length of the sequence or 0 when unterminated
+func parseOSC(s string) (string, int) {
+	for j := 2; j < len(s); j++ {
+		if s[j] == '\a' {
+			return s[2:j], j + 1
+		}
+		if s[j] == '\x1b' && j+1 < len(s) && s[j+1] == '\\' {
+			return s[2:j], j + 2
+		}
+	}
+	return "", 0
+}
+
+// Parse an OSC 8 hyperlink body (8;params;URL). An empty URL ends the link.
+func parseOSC8(body string) (string, bool) {
+	parts := strings.SplitN(body, ";", 3)
+	if len(parts) != 3 || parts[0] != "8" {
+		return "", false
+	}
+	return parts[2], true
+}
+
 func formatTreeItem(item TreeItem) string {
 	var builder strings.Builder
 
@@ -346,17 +641,29 @@ func formatTreeItem(item TreeItem) string {
 		}
 	}
 
+	if item.Pinned {
+		builder.WriteString(pinMarker)
+	}
+	builder.WriteString(iconFor(item))
 	builder.WriteString(item.Display)
+	if item.IsDir {
+		builder.WriteString(folderCount(item))
+	} else if icons["encrypted"] == "" && isEncryptedFile(item.Path) {
+		builder.WriteString(lockMarker)
+	}
+	if item.Tasks > 0 {
+		fmt.Fprintf(&builder, " [%d/%d]", item.TasksDone, item.Tasks)
+	}
 	return builder.String()
 }
 
 func getUserInput(prompt string, defaultValue string, screen tcell.Screen) (string, bool) {
 	input := []rune(defaultValue)
 	cursorPos := len(input)
-	width, height := screen.Size()
-	promptY := height - 1
 
 	for {
+		width, height := screen.Size()
+		promptY := height - 1
 		renderClearArea(0, promptY, width, height, screen)
 		renderText(0, promptY, prompt+string(input), tcell.StyleDefault, screen)
 		screen.ShowCursor(len(prompt)+cursorPos, promptY)
@@ -364,6 +671,8 @@ func getUserInput(prompt string, defaultValue string, screen tcell.Screen) (stri
 
 		ev := screen.PollEvent()
 		switch ev := ev.(type) {
+		case *tcell.EventResize:
+			screen.Sync()
 		case *tcell.EventKey:
 			switch ev.Key() {
 			case tcell.KeyEsc, tcell.KeyCtrlC:
@@ -404,15 +713,17 @@ func getUserInput(prompt string, defaultValue string, screen tcell.Screen) (stri
 }
 
 func getConfirmation(prompt string, screen tcell.Screen) bool {
-	width, height := screen.Size()
-	promptY := height - 1
 	for {
+		width, height := screen.Size()
+		promptY := height - 1
 		renderClearArea(0, promptY, width, height, screen)
 		renderText(0, promptY, prompt, tcell.StyleDefault, screen)
 		screen.Show()
 
 		ev := screen.PollEvent()
 		switch ev := ev.(type) {
+		case *tcell.EventResize:
+			screen.Sync()
 		case *tcell.EventKey:
 			switch ev.Rune() {
 			case 'y', 'Y':
@@ -427,63 +738,340 @@ func getConfirmation(prompt string, screen tcell.Screen) bool {
 	}
 }
 
-func openVim(path string, screen tcell.Screen) (tcell.Screen, error) {
+// Prompt for a secret, echoing an asterisk per character
+func getPassword(prompt string, screen tcell.Screen) (string, bool) {
+	var input []rune
+	for {
+		width, height := screen.Size()
+		promptY := height - 1
+		renderClearArea(0, promptY, width, height, screen)
+		renderText(0, promptY, prompt+strings.Repeat("*", len(input)), tcell.StyleDefault, screen)
+		screen.ShowCursor(len(prompt)+len(input), promptY)
+		screen.Show()
+
+		ev := screen.PollEvent()
+		switch ev := ev.(type) {
+		case *tcell.EventResize:
+			screen.Sync()
+		case *tcell.EventKey:
+			switch ev.Key() {
+			case tcell.KeyEsc, tcell.KeyCtrlC:
+				screen.HideCursor()
+				return "", false
+			case tcell.KeyEnter:
+				screen.HideCursor()
+				return string(input), true
+			case tcell.KeyBackspace, tcell.KeyBackspace2:
+				if len(input) > 0 {
+					input = input[:len(input)-1]
+				}
+			default:
+				if ev.Rune() != 0 {
+					input = append(input, ev.Rune())
+				}
+			}
+		}
+	}
+}
+
+// Prompt for one of the keys in choices, returning it in lower case, or 0 when
+// the prompt is dismissed with Esc
+func getChoice(prompt string, choices string, screen tcell.Screen) rune {
+	for {
+		width, height := screen.Size()
+		promptY := height - 1
+		renderClearArea(0, promptY, width, height, screen)
+		renderText(0, promptY, prompt, tcell.StyleDefault, screen)
+		screen.Show()
+
+		ev := screen.PollEvent()
+		switch ev := ev.(type) {
+		case *tcell.EventResize:
+			screen.Sync()
+		case *tcell.EventKey:
+			if ev.Key() == tcell.KeyEscape || ev.Key() == tcell.KeyCtrlC {
+				return 0
+			}
+			if r := unicode.ToLower(ev.Rune()); strings.ContainsRune(choices, r) {
+				return r
+			}
+		}
+	}
+}
+
+func openVim(path string, screen tcell.Screen, args ...string) (tcell.Screen, error) {
+	screen, err := runSuspended(exec.Command("vim", append(args, path)...), screen)
+	if err != nil {
+		return screen, fmt.Errorf("error opening vim at %s: %w", path, err)
+	}
+	return screen, nil
+}
+
+// Run an interactive command on the terminal with the screen suspended and
+// initialize a new screen once it exits. The new screen is returned even when
+// the command itself failed.
+func runSuspended(cmd *exec.Cmd, screen tcell.Screen) (tcell.Screen, error) {
 	resetScreen(screen)
 
-	cmd := exec.Command("vim", path)
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
-	err := cmd.Run()
+	runErr := cmd.Run()
+
+	screen, err := initScreen()
 	if err != nil {
-		return nil, fmt.Errorf("error opening vim at %s: %w", path, err)
+		return nil, fmt.Errorf("error initializing screen after %s close: %v", filepath.Base(cmd.Path), err)
 	}
+	return screen, runErr
+}
 
-	screen, err = initScreen()
+// Render a note to ANSI styled lines wrapped at width, from the preview cache
+// unless the note changed since
+func renderNote(path string, width int) ([]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	key := previewKey{path, info.ModTime(), width}
+	if cached, ok := previewCache[key]; ok {
+		return cached, nil
+	}
+	content, err := renderNoteContent(path, width)
 	if err != nil {
-		return nil, fmt.Errorf("error initializing screen after vim close: %v", err)
+		return nil, err
 	}
+	cachePreview(key, content)
+	return content, nil
+}
 
-	return screen, nil
+// Whether renderNote has the note at this width already
+func isNoteRendered(path string, width int) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	_, ok := previewCache[previewKey{path, info.ModTime(), width}]
+	return ok
 }
 
-func renderMarkdownPreview(path string, startX int, screen tcell.Screen) {
-	width, height := screen.Size()
-	if isFile(path) {
-		source, err := os.ReadFile(path)
-		if err != nil {
-			return
-		}
-		lines := markdown.Render(string(source), (width-width/5)-2, 0)
-		renderClearArea(startX, 0, width, height-2, screen)
-		renderMarkdown(startX, 1, lines, screen)
-	} else {
-		renderClearArea(startX, 0, width, height-2, screen)
+func renderNoteContent(path string, width int) ([]byte, error) {
+	if command, ok := previewerFor(path); ok {
+		return runPreviewer(command, path, width), nil
+	}
+	source, err := readNote(path)
+	if err != nil {
+		return nil, err
+	}
+	if isStructuredFile(plainPath(path)) {
+		return renderStructured(plainPath(path), source), nil
+	}
+	body := markdown.Render(convertMath(convertHTML(convertMermaid(string(source)))), width, 0)
+	return append(renderTOC(parseHeadings(source), width), body...), nil
+}
+
+func renderMarkdownPreview(path string, area Rect, scroll *int, highlight string, screen tcell.Screen) {
+	renderClearArea(area.X, area.Y, area.X+area.Width, area.Y+area.Height, screen)
+	if !isFile(path) {
+		return
+	}
+	lines, err := renderNote(path, area.Width)
+	if err != nil {
+		return
+	}
+	lineCount := renderMarkdown(area, *scroll, highlight, lines, screen)
+	if *scroll >= lineCount {
+		*scroll = max(lineCount-1, 0)
+	}
+}
+
+// What renderTree drew last, so the next frame only repaints the tree rows and
+// the preview that changed. It's only valid while nothing else was shown on
+// the screen since.
+type treeFrame struct {
+	screen  tcell.Screen
+	shown   int
+	layout  Layout
+	focus   Focus
+	rows    []treeRow
+	preview previewFrame
+}
+
+// A tree entry as drawn, with its metadata column and label
+type treeRow struct {
+	line     string
+	style    tcell.Style
+	metadata string
+	labeled  bool
+	label    tcell.Color
+}
+
+// What the preview shows: a note as of its modification time, scrolled and
+// highlighted, or its QR code, or nothing while deferred
+type previewFrame struct {
+	path    string
+	modTime time.Time
+	area    Rect
+	scroll  int
+	query   string
+	qrCode  *QRCode
+	blank   bool
+}
+
+func (app *App) isLastFrameShown(layout Layout) bool {
+	last := app.lastFrame
+	return last.screen == app.screen && last.shown >= 0 && last.shown == shownFrames(app.screen) &&
+		last.layout == layout && last.focus == app.focus && app.focus != FocusOutline
+}
+
+// Draw the selected entry in the preview unless it's shown already, returning
+// what the preview shows
+func (app *App) updatePreview(path string, area Rect, shown bool) previewFrame {
+	preview := previewFrame{path: path, area: area, scroll: app.previewScroll, query: app.previewQuery, qrCode: app.qrCode}
+	if info, err := os.Stat(path); err == nil {
+		preview.modTime = info.ModTime()
+	}
+	preview.blank = app.previewDeferred && !isNoteRendered(path, area.Width)
+	if shown && preview == app.lastFrame.preview {
+		return preview
+	}
+	app.renderPreview(path, area)
+	// Scrolling past the end was undone while drawing
+	preview.scroll = app.previewScroll
+	return preview
+}
+
+func drawTreeRow(row treeRow, area Rect, y int, screen tcell.Screen) {
+	renderClearArea(area.X, y, area.X+area.Width, y+1, screen)
+	renderText(area.X, y, row.line, row.style, screen)
+	if row.metadata != "" {
+		renderText(area.X+area.Width-treeMetadataWidth-1, y, row.metadata, theme.Footer.Dim(true), screen)
+	}
+	if row.labeled {
+		renderText(area.X+runewidth.StringWidth(row.line)+1, y, "●", tcell.StyleDefault.Foreground(row.label), screen)
+	}
+}
+
+// Draw the selected entry in the preview, as a QR code when one is shown. A
+// deferred preview stays empty unless the note was rendered before.
+func (app *App) renderPreview(path string, area Rect) {
+	switch {
+	case app.qrCode != nil && app.qrCode.path == path:
+		renderQRCode(app.qrCode, area, app.screen)
+	case app.previewDeferred && !isNoteRendered(path, area.Width):
+		renderClearArea(area.X, area.Y, area.X+area.Width, area.Y+area.Height, app.screen)
+	default:
+		renderMarkdownPreview(path, area, &app.previewScroll, app.previewQuery, app.screen)
 	}
 }
 
-func renderTree(tree []TreeItem, currentSelection *int, screen tcell.Screen) {
-	screen.Clear()
-	width, height := screen.Size()
-	separatorX := width / 5
-	previewStartX := separatorX + 3
+func renderTree(app *App) {
+	screen := app.screen
+	if isScreenTooSmall(screen) {
+		renderTooSmall(screen)
+		return
+	}
+	width, _ := screen.Size()
+	layout := app.layout()
+	// Unless something else was shown since the last frame, only the tree
+	// rows and the preview that changed are repainted, and Show only sends
+	// the terminal the cells that changed
+	shown := app.isLastFrameShown(layout)
+	frame := treeFrame{screen: screen, layout: layout, focus: app.focus}
+	if shown {
+		if layout.HeaderY >= 0 {
+			renderClearArea(0, layout.HeaderY, width, layout.HeaderY+1, screen)
+		}
+	} else {
+		// The preview clears its own area
+		renderClearAround(layout.Preview, screen)
+	}
 
-	for y := 0; y < height-2; y++ {
-		screen.SetContent(separatorX, y, '│', nil, tcell.StyleDefault)
+	separatorStyle := theme.Separator
+	if app.focus == FocusPreview {
+		separatorStyle = theme.SeparatorFocus
+	}
+	if layout.SeparatorX >= 0 {
+		for y := layout.Tree.Y; y < layout.Tree.Y+layout.Tree.Height; y++ {
+			screen.SetContent(layout.SeparatorX, y, separatorRune(app.config.VerticalSeparator), nil, separatorStyle)
+		}
+	}
+	if layout.SeparatorY >= 0 {
+		for x := 0; x < width; x++ {
+			screen.SetContent(x, layout.SeparatorY, separatorRune(app.config.HorizontalSeparator), nil, separatorStyle)
+		}
 	}
 
-	for i, item := range tree {
+	if app.focus == FocusOutline && app.outline != nil {
+		renderOutline(app.outline, layout.Tree, screen)
+	}
+	app.scrollTreeToSelection(layout.Tree.Height)
+	if layout.Preview.Width > 0 && app.currentSelection < len(app.flatTree) {
+		frame.preview = app.updatePreview(app.selectedItem().Path, layout.Preview, shown)
+	}
+	if app.focus != FocusOutline {
+		frame.rows = make([]treeRow, layout.Tree.Height)
+	}
+	now := time.Now()
+	for i, item := range app.flatTree {
+		row := i - app.treeOffset
+		if row < 0 || row >= len(frame.rows) {
+			continue
+		}
 		line := formatTreeItem(item)
-		style := tcell.StyleDefault
-		if i == *currentSelection {
-			style = style.Background(tcell.ColorBlue).Foreground(tcell.ColorWhite)
-			renderMarkdownPreview(item.Path, previewStartX, screen)
+		style := treeEntryStyle(item, app.config.TreeColors, now)
+		if app.marked[item.Path] {
+			line += " *"
+			style = theme.Marked
+		}
+		if i == app.currentSelection {
+			if app.focus == FocusTree {
+				style = theme.Selection
+			} else {
+				style = theme.SelectionInactive
+			}
+		}
+		color, labeled := labelColors[item.Label]
+		available := layout.Tree.Width
+		var metadata string
+		if app.showMetadata {
+			available -= treeMetadataWidth + 1
+			metadata = treeMetadata(item.Path, now)
+		}
+		if labeled {
+			available -= 2
+		}
+		// Keep entries out of the preview, shifted by the horizontal scroll
+		if app.treeScroll > 0 {
+			line = runewidth.TruncateLeft(line, app.treeScroll, "…")
+		}
+		line = runewidth.Truncate(line, max(available, 0), "…")
+		frame.rows[row] = treeRow{line, style, metadata, labeled, color}
+	}
+	for row, entry := range frame.rows {
+		if !shown || entry != app.lastFrame.rows[row] {
+			drawTreeRow(entry, layout.Tree, layout.Tree.Y+row, screen)
 		}
-		renderText(0, i, line, style, screen)
 	}
 
-	renderHorizontalSeparator(0, height-2, width, screen)
+	if layout.HeaderY >= 0 {
+		renderBreadcrumbs(app, layout.HeaderY)
+	}
+	if layout.FooterSeparatorY >= 0 {
+		renderHorizontalSeparator(0, layout.FooterSeparatorY, width, separatorRune(app.config.HorizontalSeparator), screen)
+	}
 
-	renderFooter(tree[*currentSelection], screen)
+	renderFooter(app, layout)
+	notice := app.notice
+	if status := app.indexStatus(); status != "" {
+		notice = status
+	}
+	if app.toast != "" {
+		notice = app.toast
+	}
+	if notice != "" {
+		renderText(width-runewidth.StringWidth(notice), layout.FooterY, notice, theme.Notice, screen)
+	}
 	screen.Show()
+	frame.shown = shownFrames(screen)
+	app.lastFrame = frame
 }
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"github.com/gdamore/tcell/v2"
+	"sort"
+	"strings"
+)
+
+func (app *App) toggleMark() {
+	item := app.selectedItem()
+	if !isFile(item.Path) {
+		return
+	}
+	if app.marked == nil {
+		app.marked = make(map[string]bool)
+	}
+	if app.marked[item.Path] {
+		delete(app.marked, item.Path)
+	} else {
+		app.marked[item.Path] = true
+	}
+	app.moveSelection(1)
+}
+
+// The marked notes, or the selected note when nothing is marked
+func (app *App) markedOrSelected() []string {
+	if len(app.marked) == 0 {
+		if item := app.selectedItem(); isFile(item.Path) {
+			return []string{item.Path}
+		}
+		return nil
+	}
+	paths := make([]string, 0, len(app.marked))
+	for path := range app.marked {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Forget marks of notes that were moved or deleted
+func (app *App) pruneMarks() {
+	for path := range app.marked {
+		if !isFile(path) {
+			delete(app.marked, path)
+		}
+	}
+}
+
+// Add or remove tags on several notes at once. Tags are given as +tag or tag
+// to add and -tag to remove, and stored in the frontmatter tags list.
+func handleBulkTag(paths []string, screen tcell.Screen) error {
+	if len(paths) == 0 {
+		return userErr{"Mark notes with Space to tag them"}
+	}
+	prompt := fmt.Sprintf("Tags for %d notes (+tag to add, -tag to remove): ", len(paths))
+	input, ok := getUserInput(prompt, "", screen)
+	if !ok || strings.TrimSpace(input) == "" {
+		return nil
+	}
+	var add, remove []string
+	for _, field := range strings.Fields(input) {
+		if tag, found := strings.CutPrefix(field, "-"); found {
+			remove = append(remove, strings.TrimPrefix(tag, "#"))
+		} else {
+			add = append(add, strings.TrimPrefix(strings.TrimPrefix(field, "+"), "#"))
+		}
+	}
+
+	for _, path := range paths {
+		// JSON and YAML files can't hold frontmatter
+		if isStructuredFile(path) {
+			continue
+		}
+		tags := editTags(frontmatterList(readFrontmatter(path), tagsField), add, remove)
+		var value any
+		if len(tags) > 0 {
+			value = tags
+		}
+		if err := updateFrontmatterField(path, tagsField, value); err != nil {
+			return fmt.Errorf("error writing tags to %s: %v", path, err)
+		}
+	}
+	return nil
+}
+
+// Apply additions and removals to a tag list, keeping its order and comparing
+// tags case-insensitively
+func editTags(tags []string, add []string, remove []string) []string {
+	var result []string
+	for _, tag := range tags {
+		if !containsFold(remove, tag) && !containsFold(result, tag) {
+			result = append(result, tag)
+		}
+	}
+	for _, tag := range add {
+		if tag != "" && !containsFold(result, tag) {
+			result = append(result, tag)
+		}
+	}
+	return result
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
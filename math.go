@@ -0,0 +1,399 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var texSymbols = map[string]string{
+	"alpha": "α", "beta": "β", "gamma": "γ", "delta": "δ", "epsilon": "ε", "varepsilon": "ε",
+	"zeta": "ζ", "eta": "η", "theta": "θ", "vartheta": "ϑ", "iota": "ι", "kappa": "κ",
+	"lambda": "λ", "mu": "μ", "nu": "ν", "xi": "ξ", "pi": "π", "varpi": "ϖ", "rho": "ρ",
+	"sigma": "σ", "varsigma": "ς", "tau": "τ", "upsilon": "υ", "phi": "φ", "varphi": "φ",
+	"chi": "χ", "psi": "ψ", "omega": "ω",
+	"Gamma": "Γ", "Delta": "Δ", "Theta": "Θ", "Lambda": "Λ", "Xi": "Ξ", "Pi": "Π",
+	"Sigma": "Σ", "Upsilon": "Υ", "Phi": "Φ", "Psi": "Ψ", "Omega": "Ω",
+	"sum": "∑", "prod": "∏", "coprod": "∐", "int": "∫", "iint": "∬", "iiint": "∭", "oint": "∮",
+	"infty": "∞", "partial": "∂", "nabla": "∇", "pm": "±", "mp": "∓", "times": "×",
+	"div": "÷", "cdot": "·", "ast": "∗", "star": "⋆", "circ": "∘", "bullet": "•",
+	"cdots": "⋯", "ldots": "…", "dots": "…", "vdots": "⋮", "ddots": "⋱",
+	"le": "≤", "leq": "≤", "ge": "≥", "geq": "≥", "ne": "≠", "neq": "≠", "ll": "≪", "gg": "≫",
+	"approx": "≈", "equiv": "≡", "sim": "∼", "simeq": "≃", "cong": "≅", "propto": "∝",
+	"in": "∈", "notin": "∉", "ni": "∋", "subset": "⊂", "subseteq": "⊆", "supset": "⊃",
+	"supseteq": "⊇", "cup": "∪", "cap": "∩", "setminus": "∖", "emptyset": "∅", "varnothing": "∅",
+	"forall": "∀", "exists": "∃", "nexists": "∄", "neg": "¬", "lnot": "¬", "land": "∧",
+	"wedge": "∧", "lor": "∨", "vee": "∨", "oplus": "⊕", "otimes": "⊗", "perp": "⊥",
+	"parallel": "∥", "mid": "∣", "angle": "∠", "triangle": "△", "therefore": "∴", "because": "∵",
+	"to": "→", "rightarrow": "→", "leftarrow": "←", "gets": "←", "leftrightarrow": "↔",
+	"Rightarrow": "⇒", "Leftarrow": "⇐", "Leftrightarrow": "⇔", "implies": "⟹", "iff": "⟺",
+	"mapsto": "↦", "uparrow": "↑", "downarrow": "↓", "langle": "⟨", "rangle": "⟩",
+	"lfloor": "⌊", "rfloor": "⌋", "lceil": "⌈", "rceil": "⌉", "prime": "′", "hbar": "ℏ",
+	"ell": "ℓ", "Re": "ℜ", "Im": "ℑ", "aleph": "ℵ", "deg": "°", "degree": "°",
+	"quad": "  ", "qquad": "    ",
+	"sin": "sin", "cos": "cos", "tan": "tan", "cot": "cot", "sec": "sec", "csc": "csc",
+	"arcsin": "arcsin", "arccos": "arccos", "arctan": "arctan", "sinh": "sinh", "cosh": "cosh",
+	"tanh": "tanh", "log": "log", "ln": "ln", "lg": "lg", "exp": "exp", "lim": "lim",
+	"max": "max", "min": "min", "sup": "sup", "inf": "inf", "det": "det", "dim": "dim",
+	"gcd": "gcd", "mod": "mod", "bmod": "mod", "arg": "arg", "ker": "ker",
+}
+
+// Commands that only size or space things out, dropped with their
+// delimiter kept
+var texIgnored = []string{"left", "right", "big", "Big", "bigg", "Bigg", "bigl", "bigr", "Bigl", "Bigr",
+	"displaystyle", "textstyle", "limits", "nolimits"}
+
+var (
+	superscripts = map[rune]rune{
+		'0': '⁰', '1': '¹', '2': '²', '3': '³', '4': '⁴', '5': '⁵', '6': '⁶', '7': '⁷', '8': '⁸', '9': '⁹',
+		'+': '⁺', '-': '⁻', '−': '⁻', '=': '⁼', '(': '⁽', ')': '⁾', 'a': 'ᵃ', 'b': 'ᵇ', 'c': 'ᶜ',
+		'd': 'ᵈ', 'e': 'ᵉ', 'f': 'ᶠ', 'g': 'ᵍ', 'h': 'ʰ', 'i': 'ⁱ', 'j': 'ʲ', 'k': 'ᵏ', 'l': 'ˡ',
+		'm': 'ᵐ', 'n': 'ⁿ', 'o': 'ᵒ', 'p': 'ᵖ', 'r': 'ʳ', 's': 'ˢ', 't': 'ᵗ', 'u': 'ᵘ', 'v': 'ᵛ',
+		'w': 'ʷ', 'x': 'ˣ', 'y': 'ʸ', 'z': 'ᶻ', 'T': 'ᵀ', '′': '′', '*': '*',
+	}
+	subscripts = map[rune]rune{
+		'0': '₀', '1': '₁', '2': '₂', '3': '₃', '4': '₄', '5': '₅', '6': '₆', '7': '₇', '8': '₈', '9': '₉',
+		'+': '₊', '-': '₋', '−': '₋', '=': '₌', '(': '₍', ')': '₎', 'a': 'ₐ', 'e': 'ₑ', 'h': 'ₕ',
+		'i': 'ᵢ', 'j': 'ⱼ', 'k': 'ₖ', 'l': 'ₗ', 'm': 'ₘ', 'n': 'ₙ', 'o': 'ₒ', 'p': 'ₚ', 'r': 'ᵣ',
+		's': 'ₛ', 't': 'ₜ', 'u': 'ᵤ', 'v': 'ᵥ', 'x': 'ₓ',
+	}
+	doubleStruck = map[rune]rune{'N': 'ℕ', 'Z': 'ℤ', 'Q': 'ℚ', 'R': 'ℝ', 'C': 'ℂ', 'P': 'ℙ', 'H': 'ℍ', '1': '𝟙'}
+)
+
+// Replace $...$ and $$...$$ math in markdown with unicode approximations, inline
+// math as code spans and display math as code blocks so markdown leaves them
+// alone. Code blocks and spans are left untouched, and a $ followed by a space
+// or a closing one followed by a digit isn't math, so prices stay as they are.
+func convertMath(source string) string {
+	if !strings.Contains(source, "$") {
+		return source
+	}
+	return mapOutsideFences(source, convertMathSegment)
+}
+
+// Apply convert to the parts of source outside fenced code blocks
+func mapOutsideFences(source string, convert func(string) string) string {
+	var out strings.Builder
+	var segment strings.Builder
+	inFence := false
+	for _, line := range strings.SplitAfter(source, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			if inFence {
+				out.WriteString(line)
+			} else {
+				out.WriteString(convert(segment.String()))
+				segment.Reset()
+				out.WriteString(line)
+			}
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			out.WriteString(line)
+		} else {
+			segment.WriteString(line)
+		}
+	}
+	out.WriteString(convert(segment.String()))
+	return out.String()
+}
+
+func convertMathSegment(s string) string {
+	if !strings.Contains(s, "$") {
+		return s
+	}
+	var out strings.Builder
+	for i := 0; i < len(s); {
+		switch {
+		case s[i] == '\\' && i+1 < len(s):
+			// An escaped $ is shown without its backslash
+			if s[i+1] == '$' {
+				out.WriteByte('$')
+			} else {
+				out.WriteString(s[i : i+2])
+			}
+			i += 2
+			continue
+		case s[i] == '`':
+			// Copy code spans as they are
+			run := len(s[i:]) - len(strings.TrimLeft(s[i:], "`"))
+			fence := s[i : i+run]
+			if end := strings.Index(s[i+run:], fence); end != -1 {
+				out.WriteString(s[i : i+run+end+run])
+				i += run + end + run
+			} else {
+				out.WriteString(fence)
+				i += run
+			}
+			continue
+		case strings.HasPrefix(s[i:], "$$"):
+			if end := strings.Index(s[i+2:], "$$"); end != -1 {
+				out.WriteString(displayMath(s[i+2 : i+2+end]))
+				i += 2 + end + 2
+				continue
+			}
+		case s[i] == '$':
+			if end := inlineMathEnd(s[i+1:]); end != -1 {
+				math := strings.NewReplacer("\n", " ", "`", "'").Replace(texToUnicode(s[i+1 : i+1+end]))
+				out.WriteString("`" + math + "`")
+				i += 1 + end + 1
+				continue
+			}
+		}
+		out.WriteByte(s[i])
+		i++
+	}
+	return out.String()
+}
+
+// Index of the $ closing inline math, -1 when s doesn't start math
+func inlineMathEnd(s string) int {
+	if s == "" || strings.IndexByte(" \t\n$", s[0]) != -1 {
+		return -1
+	}
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '\n':
+			if strings.HasPrefix(strings.TrimLeft(s[i+1:], " \t"), "\n") {
+				return -1
+			}
+		case '$':
+			if strings.ContainsRune(" \t\n", rune(s[i-1])) || i+1 < len(s) && isDigit(rune(s[i+1])) {
+				return -1
+			}
+			return i
+		}
+	}
+	return -1
+}
+
+// A display math block as an indented code block on its own lines
+func displayMath(tex string) string {
+	var b strings.Builder
+	b.WriteString("\n```\n")
+	for _, line := range strings.Split(texToUnicode(tex), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			b.WriteString("    " + line + "\n")
+		}
+	}
+	b.WriteString("```\n")
+	return b.String()
+}
+
+// Approximate TeX math with unicode symbols, super- and subscripts where
+// unicode has them, e.g. \sum_{i=1}^n x_i^2 becomes ∑ᵢ₌₁ⁿ xᵢ²
+func texToUnicode(tex string) string {
+	r := &texReader{s: tex}
+	lines := strings.Split(r.convert(false), "\n")
+	for i, line := range lines {
+		lines[i] = strings.Join(strings.Fields(line), " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+type texReader struct {
+	s string
+	i int
+}
+
+// Convert up to the end of the source, or of the group when inGroup
+func (r *texReader) convert(inGroup bool) string {
+	var b strings.Builder
+	for r.i < len(r.s) {
+		c := r.s[r.i]
+		switch c {
+		case '{':
+			r.i++
+			b.WriteString(r.convert(true))
+		case '}':
+			r.i++
+			if inGroup {
+				return b.String()
+			}
+		case '^', '_':
+			r.i++
+			b.WriteString(script(r.argument(), c == '^'))
+		case '\\':
+			b.WriteString(r.command())
+		case '&', '~':
+			r.i++
+			b.WriteString(" ")
+		case '\n', '\t':
+			r.i++
+			b.WriteString(" ")
+		default:
+			ch, size := utf8.DecodeRuneInString(r.s[r.i:])
+			r.i += size
+			if ch == '-' {
+				ch = '−'
+			}
+			b.WriteRune(ch)
+		}
+	}
+	return b.String()
+}
+
+// The next group or token converted, as taken by commands, ^ and _
+func (r *texReader) argument() string {
+	for r.i < len(r.s) && r.s[r.i] == ' ' {
+		r.i++
+	}
+	if r.i >= len(r.s) {
+		return ""
+	}
+	switch r.s[r.i] {
+	case '{':
+		r.i++
+		return r.convert(true)
+	case '\\':
+		return r.command()
+	}
+	ch, size := utf8.DecodeRuneInString(r.s[r.i:])
+	r.i += size
+	return string(ch)
+}
+
+// Raw text of the next group, for \text and environment names
+func (r *texReader) rawArgument() string {
+	for r.i < len(r.s) && r.s[r.i] == ' ' {
+		r.i++
+	}
+	if r.i >= len(r.s) || r.s[r.i] != '{' {
+		return ""
+	}
+	end := strings.IndexByte(r.s[r.i:], '}')
+	if end == -1 {
+		end = len(r.s) - r.i
+	}
+	text := r.s[r.i+1 : r.i+end]
+	r.i = min(r.i+end+1, len(r.s))
+	return text
+}
+
+func (r *texReader) command() string {
+	r.i++
+	start := r.i
+	for r.i < len(r.s) && unicode.IsLetter(rune(r.s[r.i])) {
+		r.i++
+	}
+	name := r.s[start:r.i]
+	if name == "" {
+		if r.i >= len(r.s) {
+			return ""
+		}
+		c := r.s[r.i]
+		r.i++
+		switch c {
+		case '\\':
+			return "\n"
+		case ',', ':', ';', ' ':
+			return " "
+		case '!':
+			return ""
+		case '|':
+			return "‖"
+		}
+		return string(c)
+	}
+
+	switch name {
+	case "frac", "dfrac", "tfrac", "cfrac":
+		numerator := r.argument()
+		return fraction(numerator, r.argument())
+	case "sqrt":
+		index := ""
+		if r.i < len(r.s) && r.s[r.i] == '[' {
+			if end := strings.IndexByte(r.s[r.i:], ']'); end != -1 {
+				index = script(texToUnicode(r.s[r.i+1:r.i+end]), true)
+				r.i += end + 1
+			}
+		}
+		return index + "√" + group(r.argument())
+	case "mathbb":
+		return strings.Map(func(ch rune) rune {
+			if mapped, ok := doubleStruck[ch]; ok {
+				return mapped
+			}
+			return ch
+		}, r.argument())
+	case "text", "textrm", "textit", "textbf", "mbox":
+		return r.rawArgument()
+	case "mathrm", "mathbf", "mathit", "mathcal", "mathsf", "mathtt", "boldsymbol", "operatorname":
+		return r.argument()
+	case "overline", "bar":
+		return r.argument() + "̅"
+	case "hat":
+		return r.argument() + "̂"
+	case "vec":
+		return r.argument() + "⃗"
+	case "dot":
+		return r.argument() + "̇"
+	case "tilde":
+		return r.argument() + "̃"
+	case "begin", "end":
+		r.rawArgument()
+		return ""
+	}
+	for _, ignored := range texIgnored {
+		if name == ignored {
+			// \left. and \right. stand for no delimiter
+			if r.i < len(r.s) && r.s[r.i] == '.' {
+				r.i++
+			}
+			return ""
+		}
+	}
+	if symbol, ok := texSymbols[name]; ok {
+		return symbol
+	}
+	return "\\" + name
+}
+
+// Super- or subscript text, in unicode when it has all the characters and
+// as ^(text) or _(text) otherwise
+func script(text string, super bool) string {
+	table, marker := subscripts, "_"
+	if super {
+		table, marker = superscripts, "^"
+	}
+	var b strings.Builder
+	for _, ch := range text {
+		mapped, ok := table[ch]
+		if !ok {
+			if utf8.RuneCountInString(text) == 1 {
+				return marker + text
+			}
+			return marker + "(" + text + ")"
+		}
+		b.WriteRune(mapped)
+	}
+	return b.String()
+}
+
+func fraction(numerator string, denominator string) string {
+	switch numerator + "/" + denominator {
+	case "1/2":
+		return "½"
+	case "1/3":
+		return "⅓"
+	case "2/3":
+		return "⅔"
+	case "1/4":
+		return "¼"
+	case "3/4":
+		return "¾"
+	}
+	return group(numerator) + "/" + group(denominator)
+}
+
+// Parenthesize text made of more than one term
+func group(text string) string {
+	for _, ch := range text {
+		if !unicode.IsLetter(ch) && !unicode.IsDigit(ch) && ch != '.' {
+			return "(" + text + ")"
+		}
+	}
+	return text
+}
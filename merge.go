@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Vault relative markdown notes other than exclude, skipping hidden
+// directories and encrypted notes
+func listNotes(rootItemPath string, exclude string) []string {
+	var notes []string
+	walkNotes(rootItemPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || path == exclude || !isMarkdownFile(path) {
+			return nil
+		}
+		if rel, err := filepath.Rel(rootItemPath, path); err == nil {
+			notes = append(notes, rel)
+		}
+		return nil
+	})
+	return notes
+}
+
+func isMarkdownFile(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".md" || ext == ".markdown"
+}
+
+// Point the wiki and markdown links of a note at from to to instead, keeping
+// headings and link texts. Fenced code blocks are left alone.
+func retargetLinks(content string, root string, path string, from string, to string) (string, int) {
+	fromRel, _ := filepath.Rel(root, from)
+	toRel, _ := filepath.Rel(root, to)
+	fromNames := []string{wikiTarget(filepath.Base(fromRel)), wikiTarget(filepath.ToSlash(fromRel))}
+	count := 0
+	var out strings.Builder
+	inFence := false
+	for _, line := range strings.SplitAfter(content, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inFence = !inFence
+		}
+		if inFence {
+			out.WriteString(line)
+			continue
+		}
+		line = publishWikiLinkRegex.ReplaceAllStringFunc(line, func(link string) string {
+			m := publishWikiLinkRegex.FindStringSubmatch(link)
+			name := strings.TrimSpace(m[1])
+			if !slices.Contains(fromNames, wikiTarget(name)) {
+				return link
+			}
+			target := strings.TrimSuffix(filepath.Base(toRel), filepath.Ext(toRel))
+			if strings.Contains(name, "/") {
+				target = strings.TrimSuffix(filepath.ToSlash(toRel), filepath.Ext(toRel))
+			}
+			count++
+			link = "[[" + target + m[2]
+			if m[3] != "" {
+				link += "|" + m[3]
+			}
+			return link + "]]"
+		})
+		line = mdLinkRegex.ReplaceAllStringFunc(line, func(link string) string {
+			destination := strings.TrimSuffix(strings.TrimPrefix(link, "]("), ")")
+			if target, ok := markdownTarget(root, path, destination); !ok || target != fromRel {
+				return link
+			}
+			href, err := filepath.Rel(filepath.Dir(path), to)
+			if err != nil {
+				return link
+			}
+			href = filepath.ToSlash(href)
+			if _, fragment, ok := strings.Cut(destination, "#"); ok {
+				href += "#" + fragment
+			}
+			count++
+			return "](" + href + ")"
+		})
+		out.WriteString(line)
+	}
+	return out.String(), count
+}
+
+// The note at target followed by a separator, a comment naming the source
+// and the source note without its frontmatter
+func mergedContent(target []byte, source []byte, sourceRel string, now time.Time) []byte {
+	if _, body, ok := splitFrontmatter(source); ok {
+		source = body
+	}
+	merged := strings.TrimRight(string(target), "\n")
+	if merged != "" {
+		merged += "\n\n---\n\n"
+	}
+	merged += fmt.Sprintf("<!-- merged from %s on %s -->\n\n", filepath.ToSlash(sourceRel), now.Format("2006-01-02"))
+	return []byte(merged + strings.TrimLeft(strings.TrimRight(string(source), "\n"), "\n") + "\n")
+}
+
+// Append the selected note to another one picked from the vault, move it to
+// the backups and point the links to it at the note it was merged into. The
+// changed notes are backed up first.
+func (app *App) mergeNote() error {
+	source := app.selectedItem().Path
+	if !isFile(source) || !isMarkdownFile(source) {
+		return userErr{"Select a markdown note to merge"}
+	}
+	if isEncryptedFile(source) {
+		return userErr{"Can't merge an encrypted note"}
+	}
+	if !app.confirmUnchanged(source) {
+		return nil
+	}
+	root := app.rootItem.Path
+	sourceRel := app.relativePath(source)
+	targetRel, ok := pickFromList("Merge "+sourceRel+" into", "notes", listNotes(root, source), false, app.screen)
+	if !ok {
+		return nil
+	}
+	target := filepath.Join(root, targetRel)
+	if isEncryptedFile(target) {
+		return userErr{"Can't merge into an encrypted note"}
+	}
+	if !getConfirmation(fmt.Sprintf("Append %s to %s and move it to the backups? (y/N): ", sourceRel, targetRel), app.screen) {
+		return nil
+	}
+	app.recordOperation("merge", source+" "+target)
+
+	sourceContent, err := os.ReadFile(source)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %v", source, err)
+	}
+	targetContent, err := os.ReadFile(target)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %v", target, err)
+	}
+	linking := make(map[string]string)
+	updated := 0
+	for _, rel := range listNotes(root, source) {
+		path := filepath.Join(root, rel)
+		if isEncryptedFile(path) {
+			continue
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		if retargeted, count := retargetLinks(string(content), root, path, source, target); count > 0 {
+			linking[path] = retargeted
+			updated += count
+		}
+	}
+	paths := []string{source, target}
+	for path := range linking {
+		if path != target {
+			paths = append(paths, path)
+		}
+	}
+	if err := backupFiles(root, "merge", paths...); err != nil {
+		return err
+	}
+
+	if retargeted, ok := linking[target]; ok {
+		targetContent = []byte(retargeted)
+		delete(linking, target)
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %v", target, err)
+	}
+	if err := os.WriteFile(target, mergedContent(targetContent, sourceContent, sourceRel, time.Now()), info.Mode()); err != nil {
+		return fmt.Errorf("error writing %s: %v", target, err)
+	}
+	for path, content := range linking {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			return fmt.Errorf("error writing %s: %v", path, err)
+		}
+		app.fireHook(hookNoteEdited, path, "")
+	}
+	move, err := moveToBackups(root, "merge", source)
+	if err != nil {
+		return err
+	}
+	app.updateBackupSets(moveSidecars([]FileMove{move}), "merge")
+	app.fireHook(hookNoteEdited, target, "")
+	app.fireHook(hookNoteDeleted, source, "")
+	app.rebuild()
+	app.selectPath(target)
+	app.toast = fmt.Sprintf("Merged %s into %s", sourceRel, targetRel)
+	if updated > 0 {
+		app.toast += fmt.Sprintf(", updated %d links", updated)
+	}
+	return nil
+}
@@ -0,0 +1,661 @@
+package main
+
+import (
+	"github.com/mattn/go-runewidth"
+	"regexp"
+	"strings"
+)
+
+// Line directions of a canvas cell, combined into box drawing characters
+const (
+	lineUp = 1 << iota
+	lineDown
+	lineLeft
+	lineRight
+)
+
+var boxChars = map[uint8]rune{
+	lineUp: '│', lineDown: '│', lineUp | lineDown: '│',
+	lineLeft: '─', lineRight: '─', lineLeft | lineRight: '─',
+	lineDown | lineRight: '┌', lineDown | lineLeft: '┐', lineUp | lineRight: '└', lineUp | lineLeft: '┘',
+	lineUp | lineDown | lineRight: '├', lineUp | lineDown | lineLeft: '┤',
+	lineLeft | lineRight | lineDown: '┬', lineLeft | lineRight | lineUp: '┴',
+	lineUp | lineDown | lineLeft | lineRight: '┼',
+}
+
+// Marks the cell covered by the right half of a wide character
+const wideFiller = rune(-1)
+
+// canvas is a grid that lines are drawn on, joining where they meet, with
+// text written over them
+type canvas struct {
+	lines [][]uint8
+	text  [][]rune
+}
+
+func (c *canvas) grow(x int, y int) {
+	for len(c.lines) <= y {
+		c.lines = append(c.lines, nil)
+		c.text = append(c.text, nil)
+	}
+	for len(c.lines[y]) <= x {
+		c.lines[y] = append(c.lines[y], 0)
+		c.text[y] = append(c.text[y], 0)
+	}
+}
+
+func (c *canvas) mark(x int, y int, direction uint8) {
+	if x < 0 || y < 0 {
+		return
+	}
+	c.grow(x, y)
+	c.lines[y][x] |= direction
+}
+
+func (c *canvas) hline(y int, x1 int, x2 int) {
+	x1, x2 = min(x1, x2), max(x1, x2)
+	for x := x1; x <= x2; x++ {
+		if x > x1 {
+			c.mark(x, y, lineLeft)
+		}
+		if x < x2 {
+			c.mark(x, y, lineRight)
+		}
+	}
+}
+
+func (c *canvas) vline(x int, y1 int, y2 int) {
+	y1, y2 = min(y1, y2), max(y1, y2)
+	for y := y1; y <= y2; y++ {
+		if y > y1 {
+			c.mark(x, y, lineUp)
+		}
+		if y < y2 {
+			c.mark(x, y, lineDown)
+		}
+	}
+}
+
+func (c *canvas) rect(x int, y int, width int, height int) {
+	c.hline(y, x, x+width-1)
+	c.hline(y+height-1, x, x+width-1)
+	c.vline(x, y, y+height-1)
+	c.vline(x+width-1, y, y+height-1)
+}
+
+func (c *canvas) write(x int, y int, s string) {
+	for _, r := range s {
+		if x < 0 || y < 0 {
+			return
+		}
+		c.grow(x, y)
+		c.text[y][x] = r
+		if runewidth.RuneWidth(r) == 2 {
+			c.grow(x+1, y)
+			c.text[y][x+1] = wideFiller
+			x++
+		}
+		x++
+	}
+}
+
+// Whether s fits at x, y without covering anything drawn
+func (c *canvas) free(x int, y int, s string) bool {
+	for i := 0; i < runewidth.StringWidth(s); i++ {
+		if y < len(c.lines) && x+i < len(c.lines[y]) && (c.lines[y][x+i] != 0 || c.text[y][x+i] != 0) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *canvas) String() string {
+	var b strings.Builder
+	for y := range c.lines {
+		var line strings.Builder
+		for x, r := range c.text[y] {
+			switch {
+			case r == wideFiller:
+			case r != 0:
+				line.WriteRune(r)
+			case c.lines[y][x] != 0:
+				line.WriteRune(boxChars[c.lines[y][x]])
+			default:
+				line.WriteByte(' ')
+			}
+		}
+		b.WriteString(strings.TrimRight(line.String(), " ") + "\n")
+	}
+	return b.String()
+}
+
+// Replace ```mermaid code blocks holding flowcharts or sequence diagrams with
+// code blocks drawing them, leaving other diagrams as their source
+func convertMermaid(source string) string {
+	if !strings.Contains(source, "```mermaid") {
+		return source
+	}
+	var out, diagram strings.Builder
+	inDiagram := false
+	for _, line := range strings.SplitAfter(source, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case !inDiagram && trimmed == "```mermaid":
+			inDiagram = true
+			diagram.Reset()
+		case inDiagram && strings.HasPrefix(trimmed, "```"):
+			inDiagram = false
+			if art, ok := renderMermaid(diagram.String()); ok {
+				out.WriteString("```\n" + art + "```\n")
+			} else {
+				out.WriteString("```mermaid\n" + diagram.String() + line)
+			}
+		case inDiagram:
+			diagram.WriteString(line)
+		default:
+			out.WriteString(line)
+		}
+	}
+	if inDiagram {
+		out.WriteString("```mermaid\n" + diagram.String())
+	}
+	return out.String()
+}
+
+// Statements of a diagram without comments, blank lines and the header,
+// which is returned first
+func mermaidStatements(diagram string) (string, []string) {
+	var header string
+	var statements []string
+	for _, line := range strings.Split(diagram, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "%%") {
+			continue
+		}
+		if header == "" {
+			header = line
+			continue
+		}
+		statements = append(statements, line)
+	}
+	return header, statements
+}
+
+func renderMermaid(diagram string) (string, bool) {
+	header, statements := mermaidStatements(diagram)
+	fields := strings.Fields(header)
+	if len(fields) == 0 {
+		return "", false
+	}
+	switch fields[0] {
+	case "graph", "flowchart":
+		direction := "TD"
+		if len(fields) > 1 {
+			direction = strings.TrimSuffix(fields[1], ";")
+		}
+		return renderFlowchart(direction, statements)
+	case "sequenceDiagram":
+		return renderSequence(statements)
+	}
+	return "", false
+}
+
+type flowNode struct {
+	id    string
+	label string
+	shape string
+}
+
+type flowEdge struct {
+	from  int
+	to    int
+	label string
+	arrow bool
+}
+
+// Opening and closing of node shapes, longest first
+var flowShapes = [][3]string{
+	{"((", "))", "round"}, {"([", "])", "round"}, {"[[", "]]", "rect"}, {"[(", ")]", "rect"},
+	{"{{", "}}", "decision"}, {"[", "]", "rect"}, {"(", ")", "round"}, {"{", "}", "decision"}, {">", "]", "rect"},
+}
+
+var (
+	flowIDRegex   = regexp.MustCompile(`^\s*([\p{L}\p{N}_]+)`)
+	flowEdgeRegex = regexp.MustCompile(`^\s*(?:(?:--|==|-\.)\s+(.+?)\s+)?(-{2,}>|={2,}>|-\.+->|\.->|-{3,}|={3,}|-\.+-|\.-)\s*(?:\|([^|]*)\|)?`)
+)
+
+type flowchart struct {
+	nodes []flowNode
+	index map[string]int
+	edges []flowEdge
+}
+
+// Parse a node reference at the start of s, declaring the node or updating
+// its label, and return the rest of s
+func (f *flowchart) node(s string) (int, string, bool) {
+	m := flowIDRegex.FindStringSubmatch(s)
+	if m == nil {
+		return 0, s, false
+	}
+	id := m[1]
+	s = s[len(m[0]):]
+	label, shape := "", ""
+	for _, candidate := range flowShapes {
+		if !strings.HasPrefix(s, candidate[0]) {
+			continue
+		}
+		if end := strings.Index(s[len(candidate[0]):], candidate[1]); end != -1 {
+			label = strings.Trim(strings.TrimSpace(s[len(candidate[0]):len(candidate[0])+end]), `"`)
+			shape = candidate[2]
+			s = s[len(candidate[0])+end+len(candidate[1]):]
+		}
+		break
+	}
+	i, ok := f.index[id]
+	if !ok {
+		i = len(f.nodes)
+		f.index[id] = i
+		f.nodes = append(f.nodes, flowNode{id, id, "rect"})
+	}
+	if shape != "" {
+		f.nodes[i].label, f.nodes[i].shape = strings.ReplaceAll(label, "<br>", " "), shape
+	}
+	return i, s, true
+}
+
+func parseFlowchart(statements []string) *flowchart {
+	f := &flowchart{index: make(map[string]int)}
+	for _, line := range statements {
+		for _, statement := range strings.Split(line, ";") {
+			word := strings.Fields(statement)
+			if len(word) == 0 {
+				continue
+			}
+			switch word[0] {
+			case "subgraph", "end", "classDef", "class", "style", "linkStyle", "click", "direction":
+				continue
+			}
+			from, rest, ok := f.node(statement)
+			if !ok {
+				continue
+			}
+			for {
+				m := flowEdgeRegex.FindStringSubmatch(rest)
+				if m == nil {
+					break
+				}
+				to, next, ok := f.node(rest[len(m[0]):])
+				if !ok {
+					break
+				}
+				label := strings.Trim(strings.TrimSpace(m[1]+m[3]), `"`)
+				f.edges = append(f.edges, flowEdge{from, to, label, strings.HasSuffix(m[2], ">")})
+				from, rest = to, next
+			}
+		}
+	}
+	return f
+}
+
+// Level of each node, the longest path to it from a node without incoming
+// edges, ignoring edges that close a cycle
+func (f *flowchart) levels() []int {
+	back := make(map[int]bool)
+	state := make([]int, len(f.nodes))
+	var visit func(int)
+	visit = func(n int) {
+		state[n] = 1
+		for i, e := range f.edges {
+			if e.from != n {
+				continue
+			}
+			switch state[e.to] {
+			case 0:
+				visit(e.to)
+			case 1:
+				back[i] = true
+			}
+		}
+		state[n] = 2
+	}
+	for n := range f.nodes {
+		if state[n] == 0 {
+			visit(n)
+		}
+	}
+	level := make([]int, len(f.nodes))
+	for changed := true; changed; {
+		changed = false
+		for i, e := range f.edges {
+			if !back[i] && e.from != e.to && level[e.to] < level[e.from]+1 {
+				level[e.to] = level[e.from] + 1
+				changed = true
+			}
+		}
+	}
+	return level
+}
+
+func (n flowNode) draw(c *canvas, x int, y int, width int, height int) {
+	c.rect(x, y, width, height)
+	corners := map[string]string{"round": "╭╮╰╯", "decision": "╱╲╲╱"}[n.shape]
+	if corners != "" {
+		r := []rune(corners)
+		c.write(x, y, string(r[0]))
+		c.write(x+width-1, y, string(r[1]))
+		c.write(x, y+height-1, string(r[2]))
+		c.write(x+width-1, y+height-1, string(r[3]))
+	}
+	c.write(x+2, y+height/2, n.label)
+}
+
+func renderFlowchart(direction string, statements []string) (string, bool) {
+	f := parseFlowchart(statements)
+	if len(f.nodes) == 0 {
+		return "", false
+	}
+	level := f.levels()
+	var rows [][]int
+	for n := range f.nodes {
+		for len(rows) <= level[n] {
+			rows = append(rows, nil)
+		}
+		rows[level[n]] = append(rows[level[n]], n)
+	}
+	width := make([]int, len(f.nodes))
+	for n, node := range f.nodes {
+		width[n] = runewidth.StringWidth(node.label) + 4
+	}
+
+	c := &canvas{}
+	x, y := make([]int, len(f.nodes)), make([]int, len(f.nodes))
+	var drawn []bool
+	if direction == "LR" || direction == "RL" {
+		drawn = f.drawLeftRight(c, rows, width, level, x, y)
+	} else {
+		drawn = f.drawTopDown(c, rows, width, level, x, y)
+	}
+
+	// Edges that skip levels or go back are listed below the drawing
+	var other []string
+	for i, e := range f.edges {
+		if !drawn[i] {
+			link := f.nodes[e.from].label + " → " + f.nodes[e.to].label
+			if e.label != "" {
+				link += " (" + e.label + ")"
+			}
+			other = append(other, link)
+		}
+	}
+	art := c.String()
+	if len(other) > 0 {
+		art += "\n" + strings.Join(other, "\n") + "\n"
+	}
+	return art, true
+}
+
+// Draw the levels as rows of boxes, linking boxes of adjacent rows. Returns
+// which edges were drawn.
+func (f *flowchart) drawTopDown(c *canvas, rows [][]int, width []int, level []int, x []int, y []int) []bool {
+	const boxHeight, gap = 3, 3
+	rowWidth := make([]int, len(rows))
+	widest := 0
+	for r, row := range rows {
+		for _, n := range row {
+			rowWidth[r] += width[n] + 3
+		}
+		rowWidth[r] -= 3
+		widest = max(widest, rowWidth[r])
+	}
+	for r, row := range rows {
+		left := (widest - rowWidth[r]) / 2
+		for _, n := range row {
+			x[n], y[n] = left, r*(boxHeight+gap)
+			left += width[n] + 3
+		}
+	}
+	drawn := make([]bool, len(f.edges))
+	var labels [][3]int
+	for i, e := range f.edges {
+		if level[e.to] != level[e.from]+1 {
+			continue
+		}
+		drawn[i] = true
+		sx, tx := x[e.from]+width[e.from]/2, x[e.to]+width[e.to]/2
+		bottom := y[e.from] + boxHeight - 1
+		c.vline(sx, bottom, bottom+2)
+		c.hline(bottom+2, sx, tx)
+		if e.arrow {
+			c.vline(tx, bottom+2, bottom+3)
+		} else {
+			c.vline(tx, bottom+2, y[e.to])
+		}
+		if e.label != "" {
+			labels = append(labels, [3]int{i, tx + 2, bottom + 3})
+		}
+	}
+	for n, node := range f.nodes {
+		node.draw(c, x[n], y[n], width[n], boxHeight)
+	}
+	for i, e := range f.edges {
+		if drawn[i] && e.arrow {
+			c.write(x[e.to]+width[e.to]/2, y[e.to]-1, "▼")
+		}
+	}
+	for _, l := range labels {
+		if c.free(l[1], l[2], f.edges[l[0]].label) {
+			c.write(l[1], l[2], f.edges[l[0]].label)
+		}
+	}
+	return drawn
+}
+
+// Draw the levels as columns of boxes, linking boxes of adjacent columns.
+// Returns which edges were drawn.
+func (f *flowchart) drawLeftRight(c *canvas, columns [][]int, width []int, level []int, x []int, y []int) []bool {
+	const boxHeight = 3
+	labelWidth := make([]int, len(columns))
+	for _, e := range f.edges {
+		if level[e.to] == level[e.from]+1 {
+			labelWidth[level[e.from]] = max(labelWidth[level[e.from]], runewidth.StringWidth(e.label))
+		}
+	}
+	left := 0
+	for col, column := range columns {
+		columnWidth := 0
+		for i, n := range column {
+			x[n], y[n] = left, i*(boxHeight+1)
+			columnWidth = max(columnWidth, width[n])
+		}
+		left += columnWidth + labelWidth[col] + 6
+	}
+	drawn := make([]bool, len(f.edges))
+	for i, e := range f.edges {
+		if level[e.to] != level[e.from]+1 {
+			continue
+		}
+		drawn[i] = true
+		right := x[e.from] + width[e.from] - 1
+		sy, ty := y[e.from]+1, y[e.to]+1
+		turn := x[e.to] - 3
+		c.hline(sy, right, turn)
+		c.vline(turn, sy, ty)
+		if e.arrow {
+			c.hline(ty, turn, x[e.to]-1)
+		} else {
+			c.hline(ty, turn, x[e.to])
+		}
+	}
+	for n, node := range f.nodes {
+		node.draw(c, x[n], y[n], width[n], boxHeight)
+	}
+	for i, e := range f.edges {
+		if !drawn[i] {
+			continue
+		}
+		if e.arrow {
+			c.write(x[e.to]-1, y[e.to]+1, "▶")
+		}
+		if e.label != "" && c.free(x[e.from]+width[e.from]+1, y[e.from], e.label) {
+			c.write(x[e.from]+width[e.from]+1, y[e.from], e.label)
+		}
+	}
+	return drawn
+}
+
+var (
+	sequenceParticipantRegex = regexp.MustCompile(`^(participant|actor)\s+(\S+)(?:\s+as\s+(.+))?$`)
+	sequenceMessageRegex     = regexp.MustCompile(`^([^-+>:]+?)\s*(-->>|->>|-->|->|--x|-x|--\)|-\))\s*[+-]?([^:]+?)\s*(?::\s*(.*))?$`)
+	sequenceNoteRegex        = regexp.MustCompile(`(?i)^note\s+(over|left of|right of)\s+([^:]+?)\s*:\s*(.*)$`)
+	sequenceBlocks           = []string{"loop", "alt", "else", "opt", "par", "and", "critical", "break", "rect", "end"}
+)
+
+type sequenceEvent struct {
+	kind   string
+	from   int
+	to     int
+	text   string
+	dashed bool
+}
+
+func renderSequence(statements []string) (string, bool) {
+	var labels []string
+	index := make(map[string]int)
+	participant := func(id string, label string) int {
+		id = strings.TrimSpace(id)
+		if i, ok := index[id]; ok {
+			if label != "" {
+				labels[i] = label
+			}
+			return i
+		}
+		if label == "" {
+			label = id
+		}
+		index[id] = len(labels)
+		labels = append(labels, label)
+		return len(labels) - 1
+	}
+
+	var events []sequenceEvent
+	for _, s := range statements {
+		if m := sequenceParticipantRegex.FindStringSubmatch(s); m != nil {
+			participant(m[2], m[3])
+			continue
+		}
+		if m := sequenceNoteRegex.FindStringSubmatch(s); m != nil {
+			ids := strings.Split(m[2], ",")
+			from := participant(ids[0], "")
+			to := participant(ids[len(ids)-1], "")
+			events = append(events, sequenceEvent{kind: strings.ToLower(m[1]), from: from, to: to, text: m[3]})
+			continue
+		}
+		if m := sequenceMessageRegex.FindStringSubmatch(s); m != nil {
+			from, to := participant(m[1], ""), participant(m[3], "")
+			events = append(events, sequenceEvent{kind: "message", from: from, to: to, text: m[4], dashed: strings.HasPrefix(m[2], "--")})
+			continue
+		}
+		word := strings.Fields(s)[0]
+		for _, block := range sequenceBlocks {
+			if word == block {
+				events = append(events, sequenceEvent{kind: "block", text: s})
+			}
+		}
+	}
+	if len(labels) == 0 {
+		return "", false
+	}
+
+	// Space the lifelines so the boxes and message labels between them fit
+	width := make([]int, len(labels))
+	for i, label := range labels {
+		width[i] = runewidth.StringWidth(label) + 4
+	}
+	gaps := make([]int, len(labels))
+	for i := 1; i < len(labels); i++ {
+		gaps[i] = (width[i-1]+width[i])/2 + 2
+	}
+	for _, e := range events {
+		from, to := min(e.from, e.to), max(e.from, e.to)
+		if e.kind != "message" || from == to {
+			continue
+		}
+		span := 0
+		for i := from + 1; i <= to; i++ {
+			span += gaps[i]
+		}
+		if need := runewidth.StringWidth(e.text) + 4; span < need {
+			gaps[to] += need - span
+		}
+	}
+	center := make([]int, len(labels))
+	center[0] = width[0] / 2
+	for i := 1; i < len(labels); i++ {
+		center[i] = center[i-1] + gaps[i]
+	}
+
+	c := &canvas{}
+	type text struct {
+		x, y int
+		s    string
+	}
+	var texts []text
+	row := 4
+	for _, e := range events {
+		switch e.kind {
+		case "message":
+			from, to := center[e.from], center[e.to]
+			if from == to {
+				texts = append(texts, text{from + 2, row, e.text})
+				c.hline(row+1, from, from+3)
+				c.vline(from+3, row+1, row+2)
+				c.hline(row+2, from, from+3)
+				texts = append(texts, text{from + 1, row + 2, "◀"})
+				row += 4
+				continue
+			}
+			texts = append(texts, text{min(from, to) + 2, row, e.text})
+			c.hline(row+1, from, to)
+			step := 1
+			if to < from {
+				step = -1
+			}
+			if e.dashed {
+				for x := from + step; x != to; x += step {
+					texts = append(texts, text{x, row + 1, "╌"})
+				}
+			}
+			head := "▶"
+			if to < from {
+				head = "◀"
+			}
+			texts = append(texts, text{to - step, row + 1, head})
+			row += 3
+		case "over", "left of", "right of":
+			note := "[" + e.text + "]"
+			noteWidth := runewidth.StringWidth(note)
+			x := (center[e.from]+center[e.to])/2 - noteWidth/2
+			switch e.kind {
+			case "left of":
+				x = center[e.from] - noteWidth - 1
+			case "right of":
+				x = center[e.from] + 2
+			}
+			texts = append(texts, text{max(x, 0), row, note})
+			row += 2
+		case "block":
+			texts = append(texts, text{0, row, "[" + e.text + "]"})
+			row += 2
+		}
+	}
+	for i, label := range labels {
+		left := center[i] - width[i]/2
+		c.rect(left, 0, width[i], 3)
+		c.vline(center[i], 2, row)
+		texts = append(texts, text{left + 2, 1, label})
+	}
+	for _, t := range texts {
+		c.write(t.x, t.y, t.s)
+	}
+	return c.String(), true
+}
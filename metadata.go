@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Sidecar files sit next to their note, e.g. plan.md.meta.yaml for plan.md
+const sidecarExt = ".meta.yaml"
+
+// Fields of a sidecar kept up to date by the app. aliases are written by
+// hand and count like frontmatter aliases.
+const (
+	sidecarPinned       = "pinned"
+	sidecarReadPosition = "read_position"
+)
+
+// Set from the config at startup. With sidecars on, the pinned state, color
+// label and reading position of notes are kept in sidecar files instead of
+// the state and the frontmatter.
+var metadataSidecars bool
+
+func isSidecar(path string) bool {
+	return strings.HasSuffix(strings.ToLower(path), sidecarExt)
+}
+
+func sidecarPath(path string) string {
+	return path + sidecarExt
+}
+
+// The fields of a note's sidecar, nil without one
+func readSidecar(path string) map[string]any {
+	content, err := os.ReadFile(sidecarPath(path))
+	if err != nil {
+		return nil
+	}
+	return parseFrontmatter(strings.Split(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n"))
+}
+
+// Set a field of a note's sidecar, creating it when needed and removing it
+// once its last field is removed. A nil value removes the field.
+func updateSidecarField(path string, key string, value any) error {
+	sidecar := sidecarPath(path)
+	content, err := os.ReadFile(sidecar)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error reading %s: %v", sidecar, err)
+	}
+	// Sidecars are frontmatter without the delimiters
+	wrapped := append([]byte(frontmatterDelimiter+"\n"), content...)
+	if len(content) > 0 && !bytes.HasSuffix(content, []byte("\n")) {
+		wrapped = append(wrapped, '\n')
+	}
+	wrapped = append(wrapped, frontmatterDelimiter+"\n"...)
+	lines, _, _ := splitFrontmatter(setFrontmatterField(wrapped, key, value))
+	if len(parseFrontmatter(lines)) == 0 {
+		if err := os.Remove(sidecar); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("error removing %s: %v", sidecar, err)
+		}
+		return nil
+	}
+	if err := os.WriteFile(sidecar, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		return fmt.Errorf("error writing %s: %v", sidecar, err)
+	}
+	return nil
+}
+
+// The frontmatter fields of a note with those of its sidecar over them
+func noteFields(path string) map[string]any {
+	fields := readFrontmatter(path)
+	if !metadataSidecars {
+		return fields
+	}
+	sidecar := readSidecar(path)
+	if len(sidecar) == 0 {
+		return fields
+	}
+	if fields == nil {
+		fields = make(map[string]any)
+	}
+	for key, value := range sidecar {
+		fields[key] = value
+	}
+	return fields
+}
+
+// Move the sidecars of renamed, moved or deleted notes along with them,
+// returning their moves so undoing the operation brings them back too.
+// Sidecars inside moved directories move with them already.
+func moveSidecars(moves []FileMove) []FileMove {
+	if !metadataSidecars {
+		return nil
+	}
+	var sidecarMoves []FileMove
+	for _, move := range moves {
+		from, to := sidecarPath(move.From), sidecarPath(move.To)
+		if !isFile(move.To) || !isFile(from) || isFile(to) {
+			continue
+		}
+		if err := os.Rename(from, to); err != nil {
+			logger.Printf("error moving %s: %v", from, err)
+			continue
+		}
+		sidecarMoves = append(sidecarMoves, FileMove{from, to})
+	}
+	return sidecarMoves
+}
+
+// Absolute paths of the notes pinned in their sidecars, in tree order
+func sidecarPins(item TreeItem) []string {
+	var pins []string
+	for _, child := range item.Children {
+		if child.IsDir {
+			pins = append(pins, sidecarPins(child)...)
+		} else if frontmatterString(child.Fields, sidecarPinned) == "true" {
+			if abs, err := filepath.Abs(child.Path); err == nil {
+				pins = append(pins, abs)
+			}
+		}
+	}
+	return pins
+}
+
+// The reader line a note was last read at, 0 without sidecars
+func readPosition(path string) int {
+	if !metadataSidecars {
+		return 0
+	}
+	line, _ := strconv.Atoi(frontmatterString(readSidecar(path), sidecarReadPosition))
+	return max(line, 0)
+}
+
+func saveReadPosition(path string, line int) {
+	if !metadataSidecars || line == readPosition(path) {
+		return
+	}
+	var value any
+	if line > 0 {
+		value = strconv.Itoa(line)
+	}
+	if err := updateSidecarField(path, sidecarReadPosition, value); err != nil {
+		logger.Printf("error saving reading position: %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"github.com/gdamore/tcell/v2"
+	"strings"
+)
+
+type Heading struct {
+	Level int
+	Text  string
+	Line  int
+}
+
+// Outline lists the headings of a note in place of the tree
+type Outline struct {
+	path      string
+	headings  []Heading
+	selection int
+}
+
+// Parse ATX headings outside of fenced code blocks, with 1-based source lines
+func parseHeadings(source []byte) []Heading {
+	var headings []Heading
+	inFence := false
+	for i, line := range strings.Split(string(source), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+			continue
+		}
+		if inFence || !strings.HasPrefix(line, "#") {
+			continue
+		}
+		level := len(line) - len(strings.TrimLeft(line, "#"))
+		text := strings.TrimSpace(strings.Trim(strings.TrimSpace(line[level:]), "#"))
+		if level > 6 || text == "" || (len(line) > level && line[level] != ' ' && line[level] != '\t') {
+			continue
+		}
+		headings = append(headings, Heading{Level: level, Text: text, Line: i + 1})
+	}
+	return headings
+}
+
+func (app *App) openOutline() error {
+	item := app.selectedItem()
+	if !isFile(item.Path) {
+		return nil
+	}
+	source, err := readNote(item.Path)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %v", item.Path, err)
+	}
+	headings := parseHeadings(source)
+	if len(headings) == 0 {
+		return userErr{"No headings in this note"}
+	}
+	app.outline = &Outline{path: item.Path, headings: headings}
+	app.previewHidden = false
+	app.setFocus(FocusOutline)
+	app.scrollPreviewToHeading()
+	return nil
+}
+
+func (app *App) closeOutline() {
+	app.outline = nil
+	app.setFocus(FocusTree)
+}
+
+func (app *App) moveOutlineSelection(delta int) {
+	o := app.outline
+	o.selection = max(min(o.selection+delta, len(o.headings)-1), 0)
+	app.scrollPreviewToHeading()
+}
+
+// Scroll the preview to the rendered line of the selected heading, past the
+// table of contents
+func (app *App) scrollPreviewToHeading() {
+	o := app.outline
+	lines, err := renderNote(o.path, app.layout().Preview.Width)
+	if err != nil {
+		return
+	}
+	toc := strings.Count(string(renderTOC(o.headings, app.layout().Preview.Width)), "\n")
+	app.previewScroll = headingLines(strings.Split(string(lines), "\n"), o.headings, toc)[o.selection]
+}
+
+func (app *App) editAtHeading() error {
+	heading := app.outline.headings[app.outline.selection]
+	app.recordOperation("edit", app.outline.path)
+	return app.openEditor(app.outline.path, fmt.Sprintf("+%d", heading.Line))
+}
+
+func renderOutline(outline *Outline, area Rect, screen tcell.Screen) {
+	offset := max(outline.selection-area.Height+1, 0)
+	for i, heading := range outline.headings[offset:] {
+		if i >= area.Height {
+			break
+		}
+		style := tcell.StyleDefault
+		if offset+i == outline.selection {
+			style = theme.Selection
+		}
+		line := strings.Repeat("  ", heading.Level-1) + heading.Text
+		renderText(area.X, area.Y+i, line, style, screen)
+	}
+}
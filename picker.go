@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const rootDirLabel = "/ (vault root)"
+
+// Vault relative directories, "." for the root, skipping hidden ones and
+// exclude with its subdirectories
+func listDirectories(rootItemPath string, exclude string) []string {
+	dirs := []string{"."}
+	walkNotes(rootItemPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() || path == rootItemPath {
+			return nil
+		}
+		if path == exclude {
+			return filepath.SkipDir
+		}
+		if rel, err := filepath.Rel(rootItemPath, path); err == nil {
+			dirs = append(dirs, rel)
+		}
+		return nil
+	})
+	return dirs
+}
+
+// Score how well query matches candidate as a case-insensitive subsequence,
+// favoring consecutive characters and ones starting a path segment or word
+func fuzzyScore(candidate string, query string) (int, bool) {
+	c, q := []rune(strings.ToLower(candidate)), []rune(strings.ToLower(query))
+	score, qi, previous := 0, 0, -2
+	for i := 0; i < len(c) && qi < len(q); i++ {
+		if c[i] != q[qi] {
+			continue
+		}
+		score++
+		if previous == i-1 {
+			score += 3
+		}
+		if i == 0 || !unicode.IsLetter(c[i-1]) && !unicode.IsDigit(c[i-1]) {
+			score += 2
+		}
+		previous = i
+		qi++
+	}
+	return score, qi == len(q)
+}
+
+// Items matching the filter, best first
+func filterItems(items []string, filter string) []string {
+	type scored struct {
+		item  string
+		score int
+	}
+	var matches []scored
+	for _, item := range items {
+		if score, ok := fuzzyScore(item, filter); ok {
+			matches = append(matches, scored{item, score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		return len(matches[i].item) < len(matches[j].item)
+	})
+	result := make([]string, len(matches))
+	for i, m := range matches {
+		result[i] = m.item
+	}
+	return result
+}
+
+// Pick a directory of the vault by typing part of its path, with the typed
+// path offered as a new directory when no directory has exactly that path.
+// Returns the vault relative directory.
+func pickDirectory(title string, rootItemPath string, exclude string, screen tcell.Screen) (string, bool) {
+	return pickFromList(title, "folders", listDirectories(rootItemPath, exclude), true, screen)
+}
+
+// Pick one of items by typing part of it, best matches first. With allowNew
+// the typed text is offered too when no item is exactly that, as a path.
+func pickFromList(title string, noun string, items []string, allowNew bool, screen tcell.Screen) (string, bool) {
+	var filter []rune
+	selection, offset := 0, 0
+	for {
+		matches := filterItems(items, string(filter))
+		found := len(matches)
+		typed := filepath.Clean(strings.TrimSpace(string(filter)))
+		if allowNew && len(filter) > 0 && typed != "." && !slices.Contains(items, typed) {
+			matches = append(matches, "+ "+typed)
+		}
+		selection = max(min(selection, len(matches)-1), 0)
+
+		width, height := screen.Size()
+		boxWidth, boxHeight := max(width-4, 10), max(height-4, 6)
+		x, y := (width-boxWidth)/2, (height-boxHeight)/2
+		rows := boxHeight - 3
+		if selection < offset {
+			offset = selection
+		}
+		if selection >= offset+rows {
+			offset = selection - rows + 1
+		}
+
+		renderClearArea(x, y, x+boxWidth, y+boxHeight, screen)
+		border := tcell.StyleDefault.Dim(true)
+		for i := x; i < x+boxWidth; i++ {
+			screen.SetContent(i, y, '─', nil, border)
+			screen.SetContent(i, y+boxHeight-1, '─', nil, border)
+		}
+		renderText(x+1, y, " "+runewidth.Truncate(title, boxWidth-4, "…")+" ", tcell.StyleDefault.Bold(true), screen)
+		prompt := "> " + string(filter)
+		renderText(x+1, y+1, prompt, tcell.StyleDefault, screen)
+		screen.ShowCursor(x+1+runewidth.StringWidth(prompt), y+1)
+		for i := offset; i < len(matches) && i < offset+rows; i++ {
+			line := matches[i]
+			if line == "." {
+				line = rootDirLabel
+			}
+			style := tcell.StyleDefault
+			if strings.HasPrefix(line, "+ ") {
+				style = theme.Accent
+			}
+			if i == selection {
+				style = theme.Selection
+			}
+			renderText(x+1, y+2+i-offset, runewidth.Truncate(line, boxWidth-2, "…"), style, screen)
+		}
+		keys := "↑/↓: Select | Enter: Pick | Esc: Cancel"
+		if allowNew {
+			keys = "↑/↓: Select | Tab: Complete | Enter: Pick | Esc: Cancel"
+		}
+		hint := fmt.Sprintf(" %d %s  %s ", found, noun, keys)
+		renderText(x+boxWidth-1-runewidth.StringWidth(hint), y+boxHeight-1, hint, border, screen)
+		screen.Show()
+
+		switch ev := screen.PollEvent().(type) {
+		case *tcell.EventResize:
+			screen.Sync()
+		case *tcell.EventKey:
+			switch ev.Key() {
+			case tcell.KeyEscape, tcell.KeyCtrlC:
+				screen.HideCursor()
+				return "", false
+			case tcell.KeyEnter:
+				if len(matches) == 0 {
+					continue
+				}
+				screen.HideCursor()
+				return strings.TrimPrefix(matches[selection], "+ "), true
+			case tcell.KeyUp, tcell.KeyCtrlP:
+				selection--
+			case tcell.KeyDown, tcell.KeyCtrlN:
+				selection++
+			case tcell.KeyPgUp:
+				selection -= rows
+			case tcell.KeyPgDn:
+				selection += rows
+			case tcell.KeyTab:
+				// Complete the filter to the selected directory to type below it
+				if allowNew && len(matches) > 0 && matches[selection] != "." {
+					filter = []rune(strings.TrimPrefix(matches[selection], "+ ") + string(os.PathSeparator))
+					selection = 0
+				}
+			case tcell.KeyBackspace, tcell.KeyBackspace2:
+				if len(filter) > 0 {
+					filter = filter[:len(filter)-1]
+					selection = 0
+				}
+			case tcell.KeyRune:
+				filter = append(filter, ev.Rune())
+				selection = 0
+			}
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Drawn before pinned entries, which are listed above the tree
+const pinMarker = "▪ "
+
+// Pinned entries of this vault that exist, in the order they were pinned.
+// Pins are kept in the state as absolute paths since it's shared by vaults.
+func (app *App) pinnedItems() []TreeItem {
+	root, err := filepath.Abs(app.rootItem.Path)
+	if err != nil {
+		return nil
+	}
+	var items []TreeItem
+	pins := slices.Clone(app.state.Pinned)
+	for _, pin := range app.sidecarPins {
+		if !slices.Contains(pins, pin) {
+			pins = append(pins, pin)
+		}
+	}
+	for _, pin := range pins {
+		rel, err := filepath.Rel(root, pin)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") || (!isFile(pin) && !isDir(pin)) {
+			continue
+		}
+		items = append(items, TreeItem{
+			Display: filepath.ToSlash(rel),
+			Path:    filepath.Join(app.rootItem.Path, rel),
+			IsDir:   isDir(pin),
+			Pinned:  true,
+		})
+	}
+	return items
+}
+
+// Pin the selected entry above the tree or unpin it
+func (app *App) togglePin() error {
+	if app.config.ReadOnly {
+		return errReadOnly
+	}
+	path := app.selectedItem().Path
+	if path == app.rootItem.Path {
+		return userErr{"The vault itself can't be pinned"}
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("error resolving %s: %v", path, err)
+	}
+	if i := slices.Index(app.state.Pinned, abs); i != -1 {
+		app.state.Pinned = slices.Delete(app.state.Pinned, i, i+1)
+	} else if metadataSidecars && isFile(path) {
+		var value any
+		if !slices.Contains(app.sidecarPins, abs) {
+			value = "true"
+		}
+		if err := updateSidecarField(path, sidecarPinned, value); err != nil {
+			return err
+		}
+		app.rebuildTree()
+		app.selectPath(path)
+		return nil
+	} else {
+		app.state.Pinned = append(app.state.Pinned, abs)
+	}
+	app.rebuildTree()
+	app.selectPath(path)
+	return saveState(stateFilePath(), app.state)
+}
+
+// Keep pins on entries that were renamed or moved, including entries inside
+// moved directories, but not on ones moved to the backups
+func (app *App) movePins(moves []FileMove) {
+	changed := false
+	for _, move := range moves {
+		if isInBackups(move.To, app.rootItem.Path) {
+			continue
+		}
+		from, err := filepath.Abs(move.From)
+		if err != nil {
+			continue
+		}
+		to, err := filepath.Abs(move.To)
+		if err != nil {
+			continue
+		}
+		for i, pin := range app.state.Pinned {
+			if pin == from || strings.HasPrefix(pin, from+string(filepath.Separator)) {
+				app.state.Pinned[i] = to + strings.TrimPrefix(pin, from)
+				changed = true
+			}
+		}
+	}
+	if changed {
+		if err := saveState(stateFilePath(), app.state); err != nil {
+			logger.Printf("error saving pins: %v", err)
+		}
+	}
+}
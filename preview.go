@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	ansiReset   = "\x1b[0m"
+	ansiKey     = "\x1b[36m"
+	ansiString  = "\x1b[32m"
+	ansiNumber  = "\x1b[33m"
+	ansiLiteral = "\x1b[35m"
+	ansiComment = "\x1b[34m"
+)
+
+func isStructuredFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json", ".yaml", ".yml":
+		return true
+	}
+	return false
+}
+
+// Pretty-print JSON or YAML source as ANSI colored text understood by renderMarkdown
+func renderStructured(path string, source []byte) []byte {
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		return colorizeJSON(source)
+	}
+	return colorizeYAML(source)
+}
+
+func colorizeJSON(source []byte) []byte {
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, source, "", "  "); err != nil {
+		return source
+	}
+
+	var out bytes.Buffer
+	s := indented.Bytes()
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"':
+			end := i + 1
+			for end < len(s) && s[end] != '"' {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			token := s[i : end+1]
+			color := ansiString
+			if end+1 < len(s) && s[end+1] == ':' {
+				color = ansiKey
+			}
+			out.WriteString(color)
+			out.Write(token)
+			out.WriteString(ansiReset)
+			i = end
+		case c == '-' || (c >= '0' && c <= '9'):
+			end := i
+			for end < len(s) && strings.IndexByte("+-.eE0123456789", s[end]) >= 0 {
+				end++
+			}
+			out.WriteString(ansiNumber)
+			out.Write(s[i:end])
+			out.WriteString(ansiReset)
+			i = end - 1
+		case c == 't' || c == 'f' || c == 'n':
+			end := i
+			for end < len(s) && s[end] >= 'a' && s[end] <= 'z' {
+				end++
+			}
+			out.WriteString(ansiLiteral)
+			out.Write(s[i:end])
+			out.WriteString(ansiReset)
+			i = end - 1
+		default:
+			out.WriteByte(c)
+		}
+	}
+	return out.Bytes()
+}
+
+var yamlKeyRegex = regexp.MustCompile(`^(\s*(?:-\s+)?)([^\s#:][^:#]*?):(\s+|$)(.*)$`)
+
+func colorizeYAML(source []byte) []byte {
+	var out bytes.Buffer
+	for _, line := range strings.Split(strings.TrimRight(string(source), "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "#"):
+			out.WriteString(ansiComment + line + ansiReset)
+		case yamlKeyRegex.MatchString(line):
+			m := yamlKeyRegex.FindStringSubmatch(line)
+			out.WriteString(m[1] + ansiKey + m[2] + ansiReset + ":" + m[3] + colorizeYAMLValue(m[4]))
+		case strings.HasPrefix(trimmed, "- "):
+			indent := line[:len(line)-len(strings.TrimLeft(line, " "))]
+			out.WriteString(indent + "- " + colorizeYAMLValue(strings.TrimPrefix(trimmed, "- ")))
+		default:
+			out.WriteString(line)
+		}
+		out.WriteByte('\n')
+	}
+	return out.Bytes()
+}
+
+var yamlNumberRegex = regexp.MustCompile(`^-?[0-9][0-9_.eE+-]*$`)
+
+func colorizeYAMLValue(value string) string {
+	switch {
+	case value == "":
+		return ""
+	case strings.HasPrefix(value, "#"):
+		return ansiComment + value + ansiReset
+	case value == "true" || value == "false" || value == "null" || value == "~":
+		return ansiLiteral + value + ansiReset
+	case yamlNumberRegex.MatchString(value):
+		return ansiNumber + value + ansiReset
+	default:
+		return ansiString + value + ansiReset
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	previewTimeout     = 5 * time.Second
+	maxPreviewOutput   = 1 << 20
+	maxCachedPreviews  = 64
+	previewFilePattern = "{file}"
+)
+
+// Previewer is a shell command from the config whose output, ANSI colors
+// included, is shown in the preview for files matching the pattern, e.g.
+// {"pattern": "*.pdf", "command": "pdftotext {file} -"}
+type Previewer struct {
+	Pattern string `json:"pattern"`
+	Command string `json:"command"`
+}
+
+// Escape sequences other than colors and styles, such as cursor movement or
+// clearing the line, which the preview can't apply
+var ansiControlRegex = regexp.MustCompile(`\x1b\[[0-9;?]*[A-Za-ln-z]`)
+
+type previewKey struct {
+	path    string
+	modTime time.Time
+	width   int
+}
+
+var (
+	// Set from the config at startup, like the encryption settings
+	previewers []Previewer
+	// Previews are rendered on every redraw, so rendered notes and command
+	// output are kept until the file changes
+	previewCache = make(map[previewKey][]byte)
+)
+
+// Keep a rendered preview, starting over once the cache is full. Encrypted
+// notes aren't kept.
+func cachePreview(key previewKey, content []byte) {
+	if isEncryptedFile(key.path) {
+		return
+	}
+	if len(previewCache) >= maxCachedPreviews {
+		previewCache = make(map[previewKey][]byte)
+	}
+	previewCache[key] = content
+}
+
+// The command of the first previewer whose pattern matches the file name.
+// Encrypted notes are never handed to previewers.
+func previewerFor(path string) (string, bool) {
+	if isEncryptedFile(path) {
+		return "", false
+	}
+	name := strings.ToLower(filepath.Base(path))
+	for _, p := range previewers {
+		if ok, _ := filepath.Match(strings.ToLower(p.Pattern), name); ok {
+			return p.Command, true
+		}
+	}
+	return "", false
+}
+
+// Run a previewer with {file} and {width} replaced, or the quoted path appended
+// when the command has no {file}. Failures are shown as the preview.
+func runPreviewer(command string, path string, width int) []byte {
+	if !strings.Contains(command, previewFilePattern) {
+		command += " " + previewFilePattern
+	}
+	expanded := strings.NewReplacer(previewFilePattern, shellQuote(path), "{width}", strconv.Itoa(width)).Replace(command)
+	cmd := shellCommand(expanded)
+	cmd.Dir = filepath.Dir(path)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Start()
+	if err == nil {
+		timer := time.AfterFunc(previewTimeout, func() { cmd.Process.Kill() })
+		err = cmd.Wait()
+		timer.Stop()
+	}
+	output := stdout.Bytes()
+	if len(output) > maxPreviewOutput {
+		output = output[:maxPreviewOutput]
+	}
+	output = ansiControlRegex.ReplaceAll(output, nil)
+	output = bytes.ReplaceAll(bytes.ReplaceAll(output, []byte("\r"), nil), []byte("\t"), []byte("    "))
+	if err != nil {
+		output = append(output, fmt.Sprintf("\n\nPreviewer failed: %v %s", err, strings.TrimSpace(stderr.String()))...)
+	}
+	return output
+}
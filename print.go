@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Send lp a file to print, returning the request id it reports
+func spoolFile(path string, title string) (string, error) {
+	output, err := exec.Command("lp", "-t", title, "--", path).CombinedOutput()
+	message := strings.TrimSpace(string(output))
+	if err != nil {
+		if message == "" {
+			message = err.Error()
+		}
+		return "", userErr{"lp: " + strings.Split(message, "\n")[0]}
+	}
+	return message, nil
+}
+
+// Print the selected note with lp after confirming, as plain text or, with
+// pandoc, rendered to PDF first. Encrypted notes aren't printed, the spooler
+// would keep their plaintext.
+func (app *App) printNote() error {
+	path := app.selectedItem().Path
+	if !isFile(path) {
+		return nil
+	}
+	if isEncryptedFile(path) {
+		return userErr{"Can't print an encrypted note"}
+	}
+	if !app.hasTool("lp") {
+		return userErr{"lp not found"}
+	}
+	name := app.relativePath(path)
+	pdf := false
+	if app.hasTool("pandoc") && isMarkdownFile(path) {
+		switch getChoice(fmt.Sprintf("Print %s: (r)endered as PDF, (t)ext, (c)ancel: ", name), "rtc", app.screen) {
+		case 'r':
+			pdf = true
+		case 't':
+		default:
+			return nil
+		}
+	} else if !getConfirmation(fmt.Sprintf("Print %s? (y/N): ", name), app.screen) {
+		return nil
+	}
+	app.recordOperation("print", path)
+
+	spooled := path
+	if pdf {
+		dir, err := os.MkdirTemp("", "notes-")
+		if err != nil {
+			return fmt.Errorf("error creating temporary directory: %v", err)
+		}
+		defer os.RemoveAll(dir)
+		spooled = filepath.Join(dir, strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))+".pdf")
+		// Relative image links resolve from the note's directory
+		cmd := exec.Command("pandoc", "--from", "markdown", "--output", spooled, filepath.Base(path))
+		cmd.Dir = filepath.Dir(path)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			message := strings.TrimSpace(string(output))
+			if message == "" {
+				message = err.Error()
+			}
+			return userErr{"pandoc: " + strings.Split(message, "\n")[0]}
+		}
+	}
+	request, err := spoolFile(spooled, filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	app.toast = "Printing " + name
+	if request != "" {
+		app.toast += ": " + request
+	}
+	return nil
+}
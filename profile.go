@@ -0,0 +1,284 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Directories of a profile archive and where their files live on disk. The
+// config directory holds config.json and anything added next to it later;
+// runtime state isn't part of a profile.
+const (
+	profileConfigDir    = "config"
+	profileTemplatesDir = "templates"
+	profileVaultConfig  = "vault.json"
+)
+
+type profileFile struct {
+	name string
+	path string
+}
+
+// The files making up a profile: the user config directory and, given a
+// vault, its templates and vault settings
+func profileFiles(vault string) ([]profileFile, error) {
+	var files []profileFile
+	addDir := func(prefix string, dir string, skip func(rel string) bool) error {
+		if !isDir(dir) {
+			return nil
+		}
+		return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			rel, err := filepath.Rel(dir, path)
+			if err != nil || skip(rel) {
+				return err
+			}
+			files = append(files, profileFile{prefix + "/" + filepath.ToSlash(rel), path})
+			return nil
+		})
+	}
+
+	configDir := filepath.Dir(configFilePath())
+	err := addDir(profileConfigDir, configDir, func(rel string) bool {
+		return filepath.Join(configDir, rel) == stateFilePath()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading config directory %s: %v", configDir, err)
+	}
+	if vault == "" {
+		return files, nil
+	}
+	templatesDir := filepath.Join(vault, templatesDirName)
+	if err := addDir(profileTemplatesDir, templatesDir, func(string) bool { return false }); err != nil {
+		return nil, fmt.Errorf("error reading templates %s: %v", templatesDir, err)
+	}
+	if vaultConfig := filepath.Join(vault, vaultConfigFileName); isFile(vaultConfig) {
+		files = append(files, profileFile{profileVaultConfig, vaultConfig})
+	}
+	return files, nil
+}
+
+// Map a name in a profile archive to its destination, rejecting names that
+// would escape the destination directories
+func profileDestination(name string, vault string) (string, error) {
+	clean := filepath.FromSlash(name)
+	if filepath.IsAbs(clean) || strings.HasPrefix(filepath.Clean(clean), "..") {
+		return "", fmt.Errorf("error: unsafe path in profile: %s", name)
+	}
+	dir, rel, _ := strings.Cut(filepath.ToSlash(filepath.Clean(clean)), "/")
+	switch {
+	case dir == profileConfigDir && rel != "":
+		return filepath.Join(filepath.Dir(configFilePath()), filepath.FromSlash(rel)), nil
+	case dir == profileTemplatesDir && rel != "" && vault != "":
+		return filepath.Join(vault, templatesDirName, filepath.FromSlash(rel)), nil
+	case dir == profileVaultConfig && rel == "" && vault != "":
+		return filepath.Join(vault, vaultConfigFileName), nil
+	}
+	return "", nil
+}
+
+// Leave the secret keys, the ones redacted from bug reports, out of a JSON
+// file of the profile, returning the content and the dotted names of the
+// keys left out. Files that aren't JSON are kept as they are.
+func stripSecrets(content []byte) ([]byte, []string, error) {
+	var value any
+	if json.Unmarshal(content, &value) != nil {
+		return content, nil, nil
+	}
+	var removed []string
+	var strip func(value any, prefix string)
+	strip = func(value any, prefix string) {
+		switch v := value.(type) {
+		case map[string]any:
+			for key, child := range v {
+				if secretKeyRegex.MatchString(key) {
+					delete(v, key)
+					removed = append(removed, prefix+key)
+				} else {
+					strip(child, prefix+key+".")
+				}
+			}
+		case []any:
+			for i, child := range v {
+				strip(child, fmt.Sprintf("%s%d.", prefix, i))
+			}
+		}
+	}
+	strip(value, "")
+	if len(removed) == 0 {
+		return content, nil, nil
+	}
+	out, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return nil, nil, fmt.Errorf("error encoding config: %v", err)
+	}
+	sort.Strings(removed)
+	return append(out, '\n'), removed, nil
+}
+
+// Archive the profile, returning the number of files and the secret config
+// keys left out of it
+func exportProfile(archivePath string, vault string) (int, []string, error) {
+	files, err := profileFiles(vault)
+	if err != nil {
+		return 0, nil, err
+	}
+	out, err := os.Create(archivePath)
+	if err != nil {
+		return 0, nil, fmt.Errorf("error creating %s: %v", archivePath, err)
+	}
+	defer out.Close()
+	gz := gzip.NewWriter(out)
+	archive := tar.NewWriter(gz)
+	var secrets []string
+
+	for _, file := range files {
+		info, err := os.Stat(file.path)
+		if err != nil {
+			return 0, nil, fmt.Errorf("error reading %s: %v", file.path, err)
+		}
+		content, err := os.ReadFile(file.path)
+		if err != nil {
+			return 0, nil, fmt.Errorf("error reading %s: %v", file.path, err)
+		}
+		if strings.HasSuffix(file.name, ".json") {
+			var removed []string
+			if content, removed, err = stripSecrets(content); err != nil {
+				return 0, nil, err
+			}
+			for _, key := range removed {
+				secrets = append(secrets, file.name+": "+key)
+			}
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return 0, nil, fmt.Errorf("error archiving %s: %v", file.path, err)
+		}
+		header.Name = file.name
+		header.Size = int64(len(content))
+		if err := archive.WriteHeader(header); err != nil {
+			return 0, nil, fmt.Errorf("error archiving %s: %v", file.path, err)
+		}
+		if _, err := archive.Write(content); err != nil {
+			return 0, nil, fmt.Errorf("error archiving %s: %v", file.path, err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return 0, nil, fmt.Errorf("error writing %s: %v", archivePath, err)
+	}
+	if err := gz.Close(); err != nil {
+		return 0, nil, fmt.Errorf("error writing %s: %v", archivePath, err)
+	}
+	return len(files), secrets, nil
+}
+
+// Unpack a profile archive. Existing files are only replaced with force; the
+// names of the files written and skipped are returned.
+func importProfile(archivePath string, vault string, force bool) (written []string, skipped []string, err error) {
+	in, err := os.Open(archivePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error opening %s: %v", archivePath, err)
+	}
+	defer in.Close()
+	gz, err := gzip.NewReader(in)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading archive: %v", err)
+	}
+	archive := tar.NewReader(gz)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return written, skipped, nil
+		}
+		if err != nil {
+			return written, skipped, fmt.Errorf("error reading archive: %v", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		dest, err := profileDestination(header.Name, vault)
+		if err != nil {
+			return written, skipped, err
+		}
+		if dest == "" || (isFile(dest) && !force) {
+			skipped = append(skipped, header.Name)
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(dest), os.ModePerm); err != nil {
+			return written, skipped, fmt.Errorf("error creating directory %s: %v", filepath.Dir(dest), err)
+		}
+		content, err := io.ReadAll(archive)
+		if err != nil {
+			return written, skipped, fmt.Errorf("error reading archive: %v", err)
+		}
+		// Files keep their mode, so a config private to the user stays so
+		mode := header.FileInfo().Mode().Perm()
+		if mode == 0 {
+			mode = 0o600
+		}
+		if err := os.WriteFile(dest, content, mode); err != nil {
+			return written, skipped, fmt.Errorf("error writing %s: %v", dest, err)
+		}
+		written = append(written, header.Name)
+	}
+}
+
+func runProfileSubcommand(args []string) error {
+	usage := fmt.Errorf("usage: notes profile export|import [-d dir] [--force] <file.tar.gz>")
+	if len(args) == 0 {
+		return usage
+	}
+	flags := flag.NewFlagSet("profile", flag.ContinueOnError)
+	d := flags.String("d", "", "Path to directory with notes whose templates and settings to include")
+	force := flags.Bool("force", false, "Replace existing files on import")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return usage
+	}
+	archivePath := flags.Arg(0)
+
+	switch args[0] {
+	case "export":
+		count, secrets, err := exportProfile(archivePath, *d)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Exported %d files to %s\n", count, archivePath)
+		if len(secrets) > 0 {
+			fmt.Printf("Left out secrets, set them again after importing: %s\n", strings.Join(secrets, ", "))
+		}
+	case "import":
+		written, skipped, err := importProfile(archivePath, *d, *force)
+		for _, name := range written {
+			fmt.Printf("imported %s\n", name)
+		}
+		for _, name := range skipped {
+			fmt.Printf("skipped %s\n", name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(skipped) > 0 {
+			fmt.Println("Existing files and vault files without -d were skipped; use --force to replace existing files")
+		}
+	default:
+		return usage
+	}
+	return nil
+}
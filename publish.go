@@ -0,0 +1,400 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"github.com/gomarkdown/markdown"
+	"github.com/gomarkdown/markdown/ast"
+	mdhtml "github.com/gomarkdown/markdown/html"
+	"github.com/gomarkdown/markdown/parser"
+	"html/template"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+var publishWikiLinkRegex = regexp.MustCompile(`\[\[([^\]|#]+)(#[^\]|]*)?(?:\|([^\]]+))?\]\]`)
+
+var publishTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { max-width: 46rem; margin: 2rem auto; padding: 0 1rem; font-family: sans-serif; line-height: 1.5; color: #222; }
+nav { margin-bottom: 2rem; color: #666; }
+a { color: #2563eb; }
+pre { background: #f4f4f5; padding: 0.75rem; overflow-x: auto; }
+code { font-size: 0.9em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ddd; padding: 0.25rem 0.5rem; }
+ul.listing { list-style: none; padding: 0; }
+</style>
+</head>
+<body>
+<nav>{{range $i, $crumb := .Crumbs}}{{if $i}} / {{end}}<a href="{{$crumb.Href}}">{{$crumb.Name}}</a>{{end}}</nav>
+{{.Body}}
+{{if .LiveReload}}<script>new EventSource("/.live").onmessage = () => location.reload()</script>
+{{end}}</body>
+</html>
+`))
+
+type publishPage struct {
+	Title      string
+	Crumbs     []publishLink
+	Body       template.HTML
+	LiveReload bool
+}
+
+type publishLink struct {
+	Name string
+	Href string
+}
+
+// A vault being published: its notes by path relative to the vault root and
+// the lookup used to resolve wiki-links
+type publishSite struct {
+	root    string
+	out     string
+	notes   []string
+	files   []string
+	dirs    map[string]bool
+	targets map[string]string
+}
+
+func (site *publishSite) collect() error {
+	site.dirs = map[string]bool{".": true}
+	site.targets = make(map[string]string)
+	err := walkNotes(site.root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p == site.out {
+				return filepath.SkipDir
+			}
+			if rel, _ := filepath.Rel(site.root, p); rel != "." {
+				site.dirs[filepath.ToSlash(rel)] = true
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(site.root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if strings.ToLower(path.Ext(rel)) != ".md" {
+			site.files = append(site.files, rel)
+			return nil
+		}
+		site.notes = append(site.notes, rel)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error reading %s: %v", site.root, err)
+	}
+	sort.Strings(site.notes)
+	for _, rel := range site.notes {
+		withoutExt := strings.ToLower(strings.TrimSuffix(rel, path.Ext(rel)))
+		site.targets[withoutExt] = rel
+		if name := path.Base(withoutExt); site.targets[name] == "" {
+			site.targets[name] = rel
+		}
+	}
+	return nil
+}
+
+func htmlPath(rel string) string {
+	return strings.TrimSuffix(rel, path.Ext(rel)) + ".html"
+}
+
+// The href of target relative to the page of note from
+func relativeHref(from string, target string) string {
+	href, err := filepath.Rel(path.Dir(from), target)
+	if err != nil {
+		return target
+	}
+	return filepath.ToSlash(href)
+}
+
+// The heading id gomarkdown generates for text, so [[note#Heading]] links to it
+func anchorName(text string) string {
+	var anchor []rune
+	dash := false
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			if dash && len(anchor) > 0 {
+				anchor = append(anchor, '-')
+			}
+			dash = false
+			anchor = append(anchor, unicode.ToLower(r))
+		} else {
+			dash = true
+		}
+	}
+	return string(anchor)
+}
+
+// Turn [[note]], [[note#heading]] and [[note|text]] into markdown links to the
+// published pages. Links to missing notes are left as their text and fenced
+// code blocks are kept as they are.
+func (site *publishSite) rewriteWikiLinks(rel string, source string) string {
+	var out strings.Builder
+	inFence := false
+	for _, line := range strings.SplitAfter(source, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inFence = !inFence
+		}
+		if inFence {
+			out.WriteString(line)
+			continue
+		}
+		out.WriteString(publishWikiLinkRegex.ReplaceAllStringFunc(line, func(link string) string {
+			m := publishWikiLinkRegex.FindStringSubmatch(link)
+			name := strings.TrimSpace(m[1])
+			text := strings.TrimSpace(m[3])
+			if text == "" {
+				text = name
+			}
+			key := strings.ToLower(strings.TrimSuffix(name, path.Ext(name)))
+			target, ok := site.targets[key]
+			if !ok {
+				return text
+			}
+			href := relativeHref(rel, htmlPath(target))
+			if heading := strings.TrimPrefix(m[2], "#"); heading != "" {
+				href += "#" + anchorName(heading)
+			}
+			return "[" + text + "](" + strings.ReplaceAll(href, " ", "%20") + ")"
+		}))
+	}
+	return out.String()
+}
+
+// Point relative links to other notes at their published pages
+func rewriteNoteLinks(doc ast.Node) {
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		link, ok := node.(*ast.Link)
+		if !ok || !entering {
+			return ast.GoToNext
+		}
+		destination := string(link.Destination)
+		if strings.Contains(destination, "://") || strings.HasPrefix(destination, "mailto:") {
+			return ast.GoToNext
+		}
+		target, fragment, _ := strings.Cut(destination, "#")
+		if strings.ToLower(path.Ext(target)) == ".md" {
+			destination = htmlPath(target)
+			if fragment != "" {
+				destination += "#" + fragment
+			}
+			link.Destination = []byte(destination)
+		}
+		return ast.GoToNext
+	})
+}
+
+func renderMarkdownHTML(source string) string {
+	p := parser.NewWithExtensions(parser.CommonExtensions | parser.AutoHeadingIDs)
+	doc := markdown.Parse([]byte(source), p)
+	rewriteNoteLinks(doc)
+	renderer := mdhtml.NewRenderer(mdhtml.RendererOptions{Flags: mdhtml.CommonFlags})
+	return string(markdown.Render(doc, renderer))
+}
+
+// The frontmatter title, the first top level heading or the file name
+func publishTitle(rel string, fields map[string]any, body string) string {
+	if title := frontmatterString(fields, "title"); title != "" {
+		return title
+	}
+	for _, line := range strings.Split(body, "\n") {
+		if heading, ok := strings.CutPrefix(line, "# "); ok {
+			return strings.TrimSpace(heading)
+		}
+	}
+	return strings.TrimSuffix(path.Base(rel), path.Ext(rel))
+}
+
+// Links from the index to every directory above the page at rel
+func publishCrumbs(rel string) []publishLink {
+	crumbs := []publishLink{{"Index", relativeHref(rel, "index.html")}}
+	dir := path.Dir(rel)
+	if dir == "." {
+		return crumbs
+	}
+	parts := strings.Split(dir, "/")
+	for i := range parts {
+		crumbs = append(crumbs, publishLink{parts[i], relativeHref(rel, strings.Join(parts[:i+1], "/")+"/index.html")})
+	}
+	return crumbs
+}
+
+func (site *publishSite) writePage(rel string, page publishPage) error {
+	dest := filepath.Join(site.out, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(dest), os.ModePerm); err != nil {
+		return fmt.Errorf("error creating directory %s: %v", filepath.Dir(dest), err)
+	}
+	var b bytes.Buffer
+	if err := publishTemplate.Execute(&b, page); err != nil {
+		return fmt.Errorf("error rendering %s: %v", rel, err)
+	}
+	if err := os.WriteFile(dest, b.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("error writing %s: %v", dest, err)
+	}
+	return nil
+}
+
+// The frontmatter title, first heading or name of the note at rel
+func (site *publishSite) noteTitle(rel string) string {
+	content, err := os.ReadFile(filepath.Join(site.root, filepath.FromSlash(rel)))
+	if err != nil {
+		return strings.TrimSuffix(path.Base(rel), path.Ext(rel))
+	}
+	lines, body, _ := splitFrontmatter(content)
+	return publishTitle(rel, parseFrontmatter(lines), string(body))
+}
+
+func (site *publishSite) notePage(rel string) (publishPage, error) {
+	source := filepath.Join(site.root, filepath.FromSlash(rel))
+	content, err := os.ReadFile(source)
+	if err != nil {
+		return publishPage{}, fmt.Errorf("error reading %s: %v", source, err)
+	}
+	lines, body, _ := splitFrontmatter(content)
+	title := publishTitle(rel, parseFrontmatter(lines), string(body))
+	html := renderMarkdownHTML(site.rewriteWikiLinks(rel, convertHTML(string(body))))
+	return publishPage{Title: title, Crumbs: publishCrumbs(rel), Body: template.HTML(html)}, nil
+}
+
+func (site *publishSite) publishNote(rel string) (string, error) {
+	page, err := site.notePage(rel)
+	if err != nil {
+		return "", err
+	}
+	return page.Title, site.writePage(htmlPath(rel), page)
+}
+
+// The page listing the subdirectories and notes of dir, false when the
+// directory has its own index note
+func (site *publishSite) listingPage(dir string, titles map[string]string) (publishPage, bool) {
+	rel := path.Join(dir, "index.html")
+	for _, note := range site.notes {
+		if htmlPath(note) == rel {
+			return publishPage{}, false
+		}
+	}
+	var b strings.Builder
+	title := path.Base(dir)
+	if dir == "." {
+		title = filepath.Base(site.root)
+	}
+	b.WriteString("<h1>" + template.HTMLEscapeString(title) + "</h1>\n<ul class=\"listing\">\n")
+	var subdirs []string
+	for d := range site.dirs {
+		if d != "." && path.Dir(d) == dir {
+			subdirs = append(subdirs, d)
+		}
+	}
+	sort.Strings(subdirs)
+	for _, d := range subdirs {
+		fmt.Fprintf(&b, "<li>📁 <a href=\"%s\">%s/</a></li>\n", template.HTMLEscapeString(relativeHref(rel, d+"/index.html")), template.HTMLEscapeString(path.Base(d)))
+	}
+	for _, note := range site.notes {
+		if path.Dir(note) == dir {
+			fmt.Fprintf(&b, "<li><a href=\"%s\">%s</a></li>\n", template.HTMLEscapeString(relativeHref(rel, htmlPath(note))), template.HTMLEscapeString(titles[note]))
+		}
+	}
+	b.WriteString("</ul>\n")
+	return publishPage{Title: title, Crumbs: publishCrumbs(rel), Body: template.HTML(b.String())}, true
+}
+
+// Write index.html listing the subdirectories and notes of dir, unless the
+// directory has its own index note
+func (site *publishSite) publishListing(dir string, titles map[string]string) error {
+	page, ok := site.listingPage(dir, titles)
+	if !ok {
+		return nil
+	}
+	return site.writePage(path.Join(dir, "index.html"), page)
+}
+
+func copyFile(source string, dest string) error {
+	content, err := os.ReadFile(source)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %v", source, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), os.ModePerm); err != nil {
+		return fmt.Errorf("error creating directory %s: %v", filepath.Dir(dest), err)
+	}
+	if err := os.WriteFile(dest, content, 0o644); err != nil {
+		return fmt.Errorf("error writing %s: %v", dest, err)
+	}
+	return nil
+}
+
+// Render the vault to a static website in out: a page per markdown note, an
+// index page per directory and every other file copied as is
+func publishVault(root string, out string) (int, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return 0, fmt.Errorf("error resolving %s: %v", root, err)
+	}
+	out, err = filepath.Abs(out)
+	if err != nil {
+		return 0, fmt.Errorf("error resolving %s: %v", out, err)
+	}
+	if out == root {
+		return 0, fmt.Errorf("error: the output directory can't be the notes directory")
+	}
+	site := &publishSite{root: root, out: out}
+	if err := site.collect(); err != nil {
+		return 0, err
+	}
+
+	titles := make(map[string]string)
+	for _, rel := range site.notes {
+		title, err := site.publishNote(rel)
+		if err != nil {
+			return 0, err
+		}
+		titles[rel] = title
+	}
+	for dir := range site.dirs {
+		if err := site.publishListing(dir, titles); err != nil {
+			return 0, err
+		}
+	}
+	for _, rel := range site.files {
+		if err := copyFile(filepath.Join(root, filepath.FromSlash(rel)), filepath.Join(out, filepath.FromSlash(rel))); err != nil {
+			return 0, err
+		}
+	}
+	return len(site.notes), nil
+}
+
+func runPublishSubcommand(args []string) error {
+	flags := flag.NewFlagSet("publish", flag.ContinueOnError)
+	d := flags.String("d", "", "Path to directory with notes")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *d == "" || flags.NArg() != 1 {
+		return fmt.Errorf("usage: notes publish -d dir <outdir>")
+	}
+	count, err := publishVault(*d, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Published %d notes to %s\n", count, flags.Arg(0))
+	return nil
+}
@@ -0,0 +1,509 @@
+package main
+
+import (
+	"fmt"
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+	"strings"
+)
+
+// QR codes are encoded in byte mode with the low error correction level,
+// which fits the most data into the fewest modules on screen. Block sizes per
+// version follow ISO/IEC 18004.
+var (
+	qrECCPerBlock = [41]int{0, 7, 10, 15, 20, 26, 18, 20, 24, 30, 18, 20, 24, 26, 30, 22, 24, 28, 30, 28, 28, 28, 28, 30, 30, 26, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30}
+	qrBlocks      = [41]int{0, 1, 1, 1, 1, 1, 2, 2, 2, 2, 4, 4, 4, 4, 4, 6, 6, 6, 6, 7, 8, 8, 9, 9, 10, 12, 12, 12, 13, 14, 15, 16, 17, 18, 19, 19, 20, 21, 22, 24, 25}
+)
+
+// Light modules kept around a code so scanners find its edges
+const qrQuietZone = 2
+
+// A QR code shown in the preview in place of the selected note
+type QRCode struct {
+	path    string
+	label   string
+	modules [][]bool
+}
+
+// Modules of a version without the function patterns, in bits
+func qrRawModules(version int) int {
+	n := (16*version+128)*version + 64
+	if version >= 2 {
+		align := version/7 + 2
+		n -= (25*align-10)*align - 55
+		if version >= 7 {
+			n -= 36
+		}
+	}
+	return n
+}
+
+func qrDataCodewords(version int) int {
+	return qrRawModules(version)/8 - qrECCPerBlock[version]*qrBlocks[version]
+}
+
+func qrAlignmentPositions(version int) []int {
+	if version == 1 {
+		return nil
+	}
+	align := version/7 + 2
+	step := (version*8 + align*3 + 5) / (align*4 - 4) * 2
+	positions := make([]int, align)
+	positions[0] = 6
+	for i, pos := align-1, version*4+10; i > 0; i, pos = i-1, pos-step {
+		positions[i] = pos
+	}
+	return positions
+}
+
+// Multiply in GF(2^8) modulo the QR polynomial
+func gfMultiply(x, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11d)
+		z ^= int((y>>i)&1) * int(x)
+	}
+	return byte(z)
+}
+
+func reedSolomonDivisor(degree int) []byte {
+	divisor := make([]byte, degree)
+	divisor[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range divisor {
+			divisor[j] = gfMultiply(divisor[j], root)
+			if j+1 < degree {
+				divisor[j] ^= divisor[j+1]
+			}
+		}
+		root = gfMultiply(root, 2)
+	}
+	return divisor
+}
+
+func reedSolomonRemainder(data []byte, divisor []byte) []byte {
+	remainder := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ remainder[0]
+		copy(remainder, remainder[1:])
+		remainder[len(remainder)-1] = 0
+		for i := range remainder {
+			remainder[i] ^= gfMultiply(divisor[i], factor)
+		}
+	}
+	return remainder
+}
+
+// The data codewords of the smallest version holding data in byte mode
+func qrEncodeData(data []byte) ([]byte, int, error) {
+	version := 1
+	for ; version <= 40; version++ {
+		countBits := 8
+		if version >= 10 {
+			countBits = 16
+		}
+		if 4+countBits+len(data)*8 <= qrDataCodewords(version)*8 {
+			break
+		}
+	}
+	if version > 40 {
+		return nil, 0, userErr{fmt.Sprintf("Too long for a QR code, %d bytes of %d", len(data), qrDataCodewords(40)-3)}
+	}
+	var bits []bool
+	appendBits := func(value int, n int) {
+		for i := n - 1; i >= 0; i-- {
+			bits = append(bits, value>>i&1 == 1)
+		}
+	}
+	appendBits(0b0100, 4)
+	if version >= 10 {
+		appendBits(len(data), 16)
+	} else {
+		appendBits(len(data), 8)
+	}
+	for _, b := range data {
+		appendBits(int(b), 8)
+	}
+	capacity := qrDataCodewords(version) * 8
+	appendBits(0, min(4, capacity-len(bits)))
+	appendBits(0, (8-len(bits)%8)%8)
+	codewords := make([]byte, 0, capacity/8)
+	for i := 0; i < len(bits); i += 8 {
+		var b byte
+		for _, bit := range bits[i : i+8] {
+			b <<= 1
+			if bit {
+				b |= 1
+			}
+		}
+		codewords = append(codewords, b)
+	}
+	for pad := byte(0xec); len(codewords) < capacity/8; pad ^= 0xec ^ 0x11 {
+		codewords = append(codewords, pad)
+	}
+	return codewords, version, nil
+}
+
+// Split the data into blocks, add their error correction codewords and
+// interleave them
+func qrInterleave(data []byte, version int) []byte {
+	blocks := qrBlocks[version]
+	eccLength := qrECCPerBlock[version]
+	total := qrRawModules(version) / 8
+	shortBlocks := blocks - total%blocks
+	shortLength := total/blocks - eccLength
+	divisor := reedSolomonDivisor(eccLength)
+	var dataBlocks, eccBlocks [][]byte
+	for i, offset := 0, 0; i < blocks; i++ {
+		length := shortLength
+		if i >= shortBlocks {
+			length++
+		}
+		block := data[offset : offset+length]
+		offset += length
+		dataBlocks = append(dataBlocks, block)
+		eccBlocks = append(eccBlocks, reedSolomonRemainder(block, divisor))
+	}
+	result := make([]byte, 0, total)
+	for i := 0; i <= shortLength; i++ {
+		for _, block := range dataBlocks {
+			if i < len(block) {
+				result = append(result, block[i])
+			}
+		}
+	}
+	for i := 0; i < eccLength; i++ {
+		for _, block := range eccBlocks {
+			result = append(result, block[i])
+		}
+	}
+	return result
+}
+
+type qrMatrix struct {
+	size     int
+	modules  [][]bool
+	function [][]bool
+}
+
+func (m *qrMatrix) set(x, y int, dark bool) {
+	m.modules[y][x] = dark
+	m.function[y][x] = true
+}
+
+func (m *qrMatrix) drawFinder(cx, cy int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			x, y := cx+dx, cy+dy
+			if x < 0 || y < 0 || x >= m.size || y >= m.size {
+				continue
+			}
+			d := max(abs(dx), abs(dy))
+			m.set(x, y, d != 2 && d != 4)
+		}
+	}
+}
+
+func (m *qrMatrix) drawFormat(mask int) {
+	// Low error correction is 01 in the format bits
+	data := 1<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return bits>>i&1 == 1 }
+	for i := 0; i <= 5; i++ {
+		m.set(8, i, bit(i))
+	}
+	m.set(8, 7, bit(6))
+	m.set(8, 8, bit(7))
+	m.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		m.set(14-i, 8, bit(i))
+	}
+	for i := 0; i < 8; i++ {
+		m.set(m.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		m.set(8, m.size-15+i, bit(i))
+	}
+	m.set(8, m.size-8, true)
+}
+
+func (m *qrMatrix) drawFunctionPatterns(version int) {
+	for i := 0; i < m.size; i++ {
+		m.set(6, i, i%2 == 0)
+		m.set(i, 6, i%2 == 0)
+	}
+	m.drawFinder(3, 3)
+	m.drawFinder(m.size-4, 3)
+	m.drawFinder(3, m.size-4)
+	positions := qrAlignmentPositions(version)
+	last := len(positions) - 1
+	for i, x := range positions {
+		for j, y := range positions {
+			// The corners with finder patterns
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					m.set(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+	// Reserve the format areas until the mask is picked
+	m.drawFormat(0)
+	if version >= 7 {
+		rem := version
+		for i := 0; i < 12; i++ {
+			rem = (rem << 1) ^ ((rem >> 11) * 0x1f25)
+		}
+		bits := version<<12 | rem
+		for i := 0; i < 18; i++ {
+			dark := bits>>i&1 == 1
+			a, b := m.size-11+i%3, i/3
+			m.set(a, b, dark)
+			m.set(b, a, dark)
+		}
+	}
+}
+
+// Place the codewords in the zigzag order going up and down two columns at
+// a time from the bottom right
+func (m *qrMatrix) drawCodewords(codewords []byte) {
+	i := 0
+	for right := m.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < m.size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = m.size - 1 - vert
+				}
+				if !m.function[y][x] && i < len(codewords)*8 {
+					m.modules[y][x] = codewords[i>>3]>>(7-i&7)&1 == 1
+					i++
+				}
+			}
+		}
+	}
+}
+
+func qrMasked(mask int, x, y int) bool {
+	switch mask {
+	case 0:
+		return (x+y)%2 == 0
+	case 1:
+		return y%2 == 0
+	case 2:
+		return x%3 == 0
+	case 3:
+		return (x+y)%3 == 0
+	case 4:
+		return (x/3+y/2)%2 == 0
+	case 5:
+		return x*y%2+x*y%3 == 0
+	case 6:
+		return (x*y%2+x*y%3)%2 == 0
+	default:
+		return ((x+y)%2+x*y%3)%2 == 0
+	}
+}
+
+func (m *qrMatrix) applyMask(mask int) {
+	for y := 0; y < m.size; y++ {
+		for x := 0; x < m.size; x++ {
+			if !m.function[y][x] && qrMasked(mask, x, y) {
+				m.modules[y][x] = !m.modules[y][x]
+			}
+		}
+	}
+}
+
+// Penalty of the masked code by the rules of the standard: long runs, 2x2
+// blocks, finder lookalikes and an unbalanced share of dark modules
+func (m *qrMatrix) penalty() int {
+	penalty := 0
+	at := func(x, y int, transposed bool) bool {
+		if transposed {
+			return m.modules[x][y]
+		}
+		return m.modules[y][x]
+	}
+	finderLike := []bool{true, false, true, true, true, false, true}
+	for _, transposed := range []bool{false, true} {
+		for y := 0; y < m.size; y++ {
+			run := 1
+			for x := 1; x <= m.size; x++ {
+				if x < m.size && at(x, y, transposed) == at(x-1, y, transposed) {
+					run++
+					continue
+				}
+				if run >= 5 {
+					penalty += run - 2
+				}
+				run = 1
+			}
+			for x := 0; x+7 <= m.size; x++ {
+				matches := true
+				for k, dark := range finderLike {
+					if at(x+k, y, transposed) != dark {
+						matches = false
+						break
+					}
+				}
+				if !matches {
+					continue
+				}
+				light := func(from, to int) bool {
+					for k := from; k < to; k++ {
+						if k >= 0 && k < m.size && at(k, y, transposed) {
+							return false
+						}
+					}
+					return true
+				}
+				if light(x-4, x) || light(x+7, x+11) {
+					penalty += 40
+				}
+			}
+		}
+	}
+	dark := 0
+	for y := 0; y < m.size; y++ {
+		for x := 0; x < m.size; x++ {
+			if m.modules[y][x] {
+				dark++
+			}
+			if x+1 < m.size && y+1 < m.size {
+				c := m.modules[y][x]
+				if c == m.modules[y][x+1] && c == m.modules[y+1][x] && c == m.modules[y+1][x+1] {
+					penalty += 3
+				}
+			}
+		}
+	}
+	total := m.size * m.size
+	penalty += (abs(dark*20-total*10)+total-1)/total*10 - 10
+	return penalty
+}
+
+// Encode data as a QR code, returning its modules with true for dark ones
+func encodeQR(data []byte) ([][]bool, error) {
+	codewords, version, err := qrEncodeData(data)
+	if err != nil {
+		return nil, err
+	}
+	codewords = qrInterleave(codewords, version)
+	size := version*4 + 17
+	m := &qrMatrix{size: size, modules: make([][]bool, size), function: make([][]bool, size)}
+	for i := range m.modules {
+		m.modules[i] = make([]bool, size)
+		m.function[i] = make([]bool, size)
+	}
+	m.drawFunctionPatterns(version)
+	m.drawCodewords(codewords)
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		m.applyMask(mask)
+		m.drawFormat(mask)
+		if penalty := m.penalty(); bestPenalty < 0 || penalty < bestPenalty {
+			best, bestPenalty = mask, penalty
+		}
+		// Masking twice undoes it
+		m.applyMask(mask)
+	}
+	m.applyMask(best)
+	m.drawFormat(best)
+	return m.modules, nil
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// Draw a QR code in the area with two modules per cell, dark on light
+// whatever the terminal colors, centered under its label
+func renderQRCode(code *QRCode, area Rect, screen tcell.Screen) {
+	renderClearArea(area.X, area.Y, area.X+area.Width, area.Y+area.Height, screen)
+	size := len(code.modules) + 2*qrQuietZone
+	width, height := size, (size+1)/2
+	if width > area.Width || height+1 > area.Height {
+		renderText(area.X, area.Y, fmt.Sprintf("The QR code needs %dx%d cells, enlarge the preview", width, height+1), tcell.StyleDefault.Dim(true), screen)
+		return
+	}
+	dark := func(x, y int) bool {
+		x, y = x-qrQuietZone, y-qrQuietZone
+		return x >= 0 && y >= 0 && x < len(code.modules) && y < len(code.modules) && code.modules[y][x]
+	}
+	color := func(dark bool) tcell.Color {
+		if dark {
+			return tcell.ColorBlack
+		}
+		return tcell.ColorWhite
+	}
+	left := area.X + (area.Width-width)/2
+	label := runewidth.Truncate(code.label, area.Width, "…")
+	renderText(area.X+(area.Width-runewidth.StringWidth(label))/2, area.Y, label, tcell.StyleDefault.Dim(true), screen)
+	for row := 0; row < height; row++ {
+		for x := 0; x < width; x++ {
+			style := tcell.StyleDefault.Foreground(color(dark(x, 2*row))).Background(color(dark(x, 2*row+1)))
+			screen.SetContent(left+x, area.Y+1+row, '▀', nil, style)
+		}
+	}
+}
+
+// Show the selected note, or the URL it was shared at, as a QR code in the
+// preview until the selection moves or the key is pressed again
+func (app *App) toggleQRCode() error {
+	path := app.selectedItem().Path
+	if app.qrCode != nil && app.qrCode.path == path {
+		app.qrCode = nil
+		return nil
+	}
+	if !isFile(path) {
+		return nil
+	}
+	name := app.relativePath(path)
+	choice := 'c'
+	if app.config.Share.Backend != "" {
+		choice = getChoice(fmt.Sprintf("QR code of %s: (c)ontent, (s)hared URL: ", name), "cs", app.screen)
+	}
+	var data, label string
+	switch choice {
+	case 'c':
+		content, err := readNote(path)
+		if err != nil {
+			return err
+		}
+		data, label = strings.TrimSpace(string(content)), name
+	case 's':
+		url, ok, err := app.uploadSelected()
+		if !ok {
+			return err
+		}
+		data, label = url, url
+	default:
+		return nil
+	}
+	if data == "" {
+		return userErr{name + " is empty"}
+	}
+	modules, err := encodeQR([]byte(data))
+	if err != nil {
+		return err
+	}
+	app.qrCode = &QRCode{path: path, label: label, modules: modules}
+	app.previewHidden = false
+	return nil
+}
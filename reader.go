@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Reader is the full-screen reading mode for a single note
+type Reader struct {
+	path   string
+	lines  []string
+	scroll int
+	query  string
+}
+
+func (app *App) openReader() error {
+	item := app.selectedItem()
+	if !isFile(item.Path) {
+		return nil
+	}
+	app.reader = &Reader{path: item.Path, scroll: readPosition(item.Path)}
+	app.setFocus(FocusReader)
+	return nil
+}
+
+func (app *App) closeReader() {
+	if !app.config.ReadOnly {
+		saveReadPosition(app.reader.path, app.reader.scroll)
+	}
+	app.reader = nil
+	app.setFocus(FocusTree)
+}
+
+func (app *App) readerArea() Rect {
+	width, height := app.screen.Size()
+	return Rect{2, 0, width - 4, height - 2}
+}
+
+func (app *App) scrollReader(delta int) {
+	r := app.reader
+	r.scroll = max(min(r.scroll+delta, len(r.lines)-app.readerArea().Height), 0)
+}
+
+func renderReader(app *App) {
+	screen := app.screen
+	r := app.reader
+	screen.Clear()
+	width, height := screen.Size()
+	area := app.readerArea()
+
+	content, err := renderNote(r.path, area.Width)
+	if err != nil {
+		app.closeReader()
+		renderTree(app)
+		return
+	}
+	r.lines = strings.Split(strings.TrimRight(string(content), "\n"), "\n")
+	r.scroll = max(min(r.scroll, len(r.lines)-area.Height), 0)
+	renderMarkdown(area, r.scroll, r.query, content, screen)
+
+	renderHorizontalSeparator(0, height-2, width, separatorRune(app.config.HorizontalSeparator), screen)
+	label := " READER "
+	status := fmt.Sprintf("%s  %d/%d  Space/b: Page | g/G: Top/Bottom | /: Search | n/N: Next/Prev | Q: Back",
+		filepath.Base(r.path), min(r.scroll+area.Height, len(r.lines)), len(r.lines))
+	renderClearArea(0, height-1, width, height, screen)
+	renderText(0, height-1, label, theme.FooterLabel, screen)
+	renderText(len(label)+1, height-1, status, theme.Footer, screen)
+	screen.Show()
+}
+
+func (app *App) searchReader() error {
+	query, ok := getUserInput("/", app.reader.query, app.screen)
+	if !ok || query == "" {
+		return nil
+	}
+	app.reader.query = query
+	return app.jumpToMatch(app.reader.scroll, 1)
+}
+
+// Scroll to the first line from index from in the given direction that
+// contains the current query
+func (app *App) jumpToMatch(from int, direction int) error {
+	r := app.reader
+	if r.query == "" {
+		return nil
+	}
+	line := findMatch(r.lines, r.query, from, direction)
+	if line == -1 {
+		return userErr{fmt.Sprintf("Pattern not found: %s", r.query)}
+	}
+	r.scroll = line
+	app.scrollReader(0)
+	return nil
+}
+
+// Find the first rendered line from index from in the given direction that
+// contains query case-insensitively, or -1
+func findMatch(lines []string, query string, from int, direction int) int {
+	needle := strings.ToLower(query)
+	for i := from; i >= 0 && i < len(lines); i += direction {
+		if strings.Contains(strings.ToLower(plainText(lines[i])), needle) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,47 @@
+package main
+
+// Actions and commands that change files in the vault, refused in read-only
+// mode. Editing is included since the editor can save.
+var (
+	writingActions = map[string]bool{
+		"edit":              true,
+		"edit-at-card":      true,
+		"edit-at-heading":   true,
+		"edit-at-line":      true,
+		"new":               true,
+		"rename":            true,
+		"move":              true,
+		"delete":            true,
+		"undo":              true,
+		"redo":              true,
+		"restore-backup":    true,
+		"label":             true,
+		"pin":               true,
+		"tag":               true,
+		"toggle-encryption": true,
+		"paste-image":       true,
+		"append-inbox":      true,
+		"insert-snippet":    true,
+		"git-stage":         true,
+		"git-unstage":       true,
+		"git-commit":        true,
+		"merge":             true,
+		"move-card-left":    true,
+		"move-card-right":   true,
+	}
+	writingCommands = map[string]bool{
+		"cheatsheet":         true,
+		"daily":              true,
+		"diff":               true,
+		"export-archive":     true,
+		"export-document":    true,
+		"import-archive":     true,
+		"new-from-clipboard": true,
+		"rename-tag":         true,
+		"replace":            true,
+		"suggest-links":      true,
+		"sync":               true,
+	}
+)
+
+var errReadOnly = userErr{"Read-only mode, changes are disabled"}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/mattn/go-runewidth"
+	"io/fs"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ReplaceMatch is one match of a vault-wide replace with what it becomes,
+// Start and End being byte offsets in the note
+type ReplaceMatch struct {
+	Path        string
+	Line        int
+	Start       int
+	End         int
+	Replacement string
+}
+
+// Find the matches of re in the text notes of the vault, skipping hidden
+// directories and encrypted notes, with $1 style references in replacement
+// expanded per match
+func findReplacements(root string, re *regexp.Regexp, replacement string) (map[string]string, []ReplaceMatch, error) {
+	contents := make(map[string]string)
+	var matches []ReplaceMatch
+	err := walkNotes(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil || bytes.IndexByte(content[:min(len(content), 512)], 0) != -1 {
+			return nil
+		}
+		text := string(content)
+		for _, loc := range re.FindAllStringSubmatchIndex(text, -1) {
+			if loc[0] == loc[1] {
+				continue
+			}
+			expanded := string(re.ExpandString(nil, replacement, text, loc))
+			matches = append(matches, ReplaceMatch{path, strings.Count(text[:loc[0]], "\n") + 1, loc[0], loc[1], expanded})
+			contents[path] = text
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("error searching %s: %v", root, err)
+	}
+	return contents, matches, nil
+}
+
+// The lines a match spans before and after replacing it
+func matchLines(content string, m ReplaceMatch) (string, string) {
+	start := strings.LastIndexByte(content[:m.Start], '\n') + 1
+	end := len(content)
+	if i := strings.IndexByte(content[m.End:], '\n'); i != -1 {
+		end = m.End + i
+	}
+	return content[start:end], content[start:m.Start] + m.Replacement + content[m.End:end]
+}
+
+// Describe every match as it would change, for the dry run
+func (app *App) replaceSummary(contents map[string]string, matches []ReplaceMatch) string {
+	var b strings.Builder
+	for _, m := range matches {
+		before, after := matchLines(contents[m.Path], m)
+		fmt.Fprintf(&b, "%s:%d\n  - %s\n  + %s\n", app.relativePath(m.Path), m.Line, before, after)
+	}
+	return b.String()
+}
+
+// Show a match in context and ask whether to replace it
+func (app *App) confirmReplacement(contents map[string]string, m ReplaceMatch, n int, total int) rune {
+	screen := app.screen
+	screen.Clear()
+	width, _ := screen.Size()
+	before, after := matchLines(contents[m.Path], m)
+	renderText(0, 0, fmt.Sprintf("%d/%d  %s:%d", n, total, app.relativePath(m.Path), m.Line), theme.Accent, screen)
+	y := 2
+	for _, line := range strings.Split(before, "\n") {
+		renderText(0, y, runewidth.Truncate("- "+line, width, "…"), theme.Removed, screen)
+		y++
+	}
+	for _, line := range strings.Split(after, "\n") {
+		renderText(0, y, runewidth.Truncate("+ "+line, width, "…"), theme.Added, screen)
+		y++
+	}
+	return getChoice("Replace? (y)es, (n)o, (a)ll remaining, (q)uit: ", "ynaq", screen)
+}
+
+// Apply the accepted matches, backing up the notes they change first
+func (app *App) applyReplacements(contents map[string]string, accepted []ReplaceMatch) error {
+	byPath := make(map[string][]ReplaceMatch)
+	var paths []string
+	for _, m := range accepted {
+		if _, ok := byPath[m.Path]; !ok {
+			paths = append(paths, m.Path)
+		}
+		byPath[m.Path] = append(byPath[m.Path], m)
+	}
+	sort.Strings(paths)
+	if err := app.backup("replace", paths...); err != nil {
+		return err
+	}
+	defer app.rebuild()
+	for _, path := range paths {
+		content := contents[path]
+		var b strings.Builder
+		last := 0
+		for _, m := range byPath[path] {
+			b.WriteString(content[last:m.Start])
+			b.WriteString(m.Replacement)
+			last = m.End
+		}
+		b.WriteString(content[last:])
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("error reading %s: %v", path, err)
+		}
+		if err := os.WriteFile(path, []byte(b.String()), info.Mode()); err != nil {
+			return fmt.Errorf("error writing %s: %v", path, err)
+		}
+		app.fireHook(hookNoteEdited, path, "")
+	}
+	return nil
+}
+
+// Replace a regular expression across the notes of the vault, asked for when
+// not given, after a summary and optionally confirming each match
+func runReplace(app *App, args []string) error {
+	var pattern, replacement string
+	if len(args) > 0 {
+		pattern, replacement = args[0], strings.Join(args[1:], " ")
+	} else {
+		var ok bool
+		if pattern, ok = getUserInput("Replace (regexp): ", "", app.screen); !ok || pattern == "" {
+			return nil
+		}
+		if replacement, ok = getUserInput("With: ", "", app.screen); !ok {
+			return nil
+		}
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return userErr{fmt.Sprintf("Invalid regexp: %v", err)}
+	}
+	contents, matches, err := findReplacements(app.rootItem.Path, re, replacement)
+	if err != nil {
+		return err
+	}
+	if len(matches) == 0 {
+		return userErr{fmt.Sprintf("No matches for: %s", pattern)}
+	}
+
+	var accepted []ReplaceMatch
+	prompt := fmt.Sprintf("%d matches in %d notes: (a)ll, (r)eview each, (s)ummary, (c)ancel: ", len(matches), len(contents))
+choose:
+	for {
+		switch getChoice(prompt, "arsc", app.screen) {
+		case 'a':
+			accepted = matches
+			break choose
+		case 'r':
+			for i, m := range matches {
+				switch app.confirmReplacement(contents, m, i+1, len(matches)) {
+				case 'y':
+					accepted = append(accepted, m)
+				case 'a':
+					accepted = append(accepted, matches[i:]...)
+					break choose
+				case 'q':
+					break choose
+				case 0:
+					return nil
+				}
+			}
+			break choose
+		case 's':
+			showOutput("Dry run: "+pattern+" → "+replacement, app.replaceSummary(contents, matches), app.screen)
+		default:
+			return nil
+		}
+	}
+	if len(accepted) == 0 {
+		return nil
+	}
+
+	app.recordOperation("replace", pattern+" "+replacement)
+	if err := app.applyReplacements(contents, accepted); err != nil {
+		return err
+	}
+	files := make(map[string]bool)
+	for _, m := range accepted {
+		files[m.Path] = true
+	}
+	renderMessage(fmt.Sprintf("Replaced %d matches in %d notes", len(accepted), len(files)), app.screen)
+	return nil
+}
@@ -0,0 +1,388 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/gdamore/tcell/v2"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// Each edited note keeps snapshots of its versions in a directory named
+	// after the note here
+	revisionsDirName = ".revisions"
+	maxRevisions     = 50
+	diffContext      = 3
+	// Longer or more different files are only reported as different, as
+	// the diff needs memory quadratic in the number of changed lines
+	maxDiffLines = 20000
+	maxDiffEdits = 2000
+)
+
+// Revision is a snapshot of a note, or the note itself for the current version
+type Revision struct {
+	Path string
+	Time time.Time
+}
+
+// Revisions lists the snapshots of a note in place of the tree, newest first
+// after the current version, and shows the diff between two of them
+type Revisions struct {
+	path      string
+	revisions []Revision
+	selection int
+	base      int
+	diff      []string
+	scroll    int
+}
+
+// DiffLine is a line of a line diff: ' ' kept, '-' removed or '+' added
+type DiffLine struct {
+	Op   byte
+	Text string
+}
+
+func revisionsDir(path string, rootItemPath string) (string, bool) {
+	rel, err := filepath.Rel(rootItemPath, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return "", false
+	}
+	return filepath.Join(rootItemPath, revisionsDirName, rel), true
+}
+
+// Snapshots of a note, newest first
+func readRevisions(path string, rootItemPath string) []Revision {
+	dir, ok := revisionsDir(path, rootItemPath)
+	if !ok {
+		return nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var revisions []Revision
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		revisions = append(revisions, Revision{filepath.Join(dir, entry.Name()), info.ModTime()})
+	}
+	sort.SliceStable(revisions, func(i, j int) bool {
+		return revisions[i].Time.After(revisions[j].Time)
+	})
+	return revisions
+}
+
+// Save the note's content as a new snapshot unless it matches the latest one,
+// dropping the oldest beyond maxRevisions. Encrypted notes aren't snapshotted
+// since the copies would be plaintext.
+func (app *App) snapshotNote(path string) {
+	root := app.rootItem.Path
+	if isEncryptedFile(path) || isInBackups(path, root) || strings.HasPrefix(path, filepath.Join(root, revisionsDirName)+string(os.PathSeparator)) {
+		return
+	}
+	dir, ok := revisionsDir(path, root)
+	if !ok {
+		return
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	revisions := readRevisions(path, root)
+	if len(revisions) > 0 {
+		if latest, err := os.ReadFile(revisions[0].Path); err == nil && bytes.Equal(latest, content) {
+			return
+		}
+	}
+	snapshot := uniquePath(filepath.Join(dir, time.Now().Format(backupTimeFormat)+filepath.Ext(path)))
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		logger.Printf("error creating directory %s: %v", dir, err)
+		return
+	}
+	if err := os.WriteFile(snapshot, content, 0o644); err != nil {
+		logger.Printf("error writing %s: %v", snapshot, err)
+		return
+	}
+	if len(revisions) >= maxRevisions {
+		for _, revision := range revisions[maxRevisions-1:] {
+			os.Remove(revision.Path)
+		}
+	}
+}
+
+// Unified diff hunks between two contents, or a line saying they differ when
+// they're too long or too different to diff
+func contentDiff(a []byte, b []byte) []string {
+	if bytes.Equal(a, b) {
+		return nil
+	}
+	lines, ok := diffLines(splitLines(a), splitLines(b))
+	if !ok {
+		return []string{"Files differ, too many changes to show a diff"}
+	}
+	return unifiedDiff(lines, diffContext)
+}
+
+// Diff two lists of lines with Myers' algorithm. Only the diagonals the next
+// step reads are kept for the backtrack, step d keeping k from -d-1 to d+1.
+// Returns false past maxDiffLines or maxDiffEdits.
+func diffLines(a []string, b []string) ([]DiffLine, bool) {
+	n, m := len(a), len(b)
+	if n > maxDiffLines || m > maxDiffLines {
+		return nil, false
+	}
+	offset := n + m + 1
+	v := make([]int, 2*offset+1)
+	var trace [][]int
+	for d := 0; d <= min(n+m, maxDiffEdits); d++ {
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrackDiff(a, b, trace), true
+			}
+		}
+	}
+	return nil, false
+}
+
+func backtrackDiff(a []string, b []string, trace [][]int) []DiffLine {
+	var lines []DiffLine
+	x, y := len(a), len(b)
+	for d := len(trace) - 1; d >= 0; d-- {
+		// Diagonal k of step d is at k+d+1
+		v, offset := trace[d], d+1
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			lines = append(lines, DiffLine{' ', a[x]})
+		}
+		if d > 0 {
+			if x == prevX {
+				lines = append(lines, DiffLine{'+', b[prevY]})
+			} else {
+				lines = append(lines, DiffLine{'-', a[prevX]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+	for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+		lines[i], lines[j] = lines[j], lines[i]
+	}
+	return lines
+}
+
+// Format a line diff as unified diff hunks with context lines around changes
+func unifiedDiff(lines []DiffLine, context int) []string {
+	aLine := make([]int, len(lines)+1)
+	bLine := make([]int, len(lines)+1)
+	for i, line := range lines {
+		aLine[i+1], bLine[i+1] = aLine[i], bLine[i]
+		if line.Op != '+' {
+			aLine[i+1]++
+		}
+		if line.Op != '-' {
+			bLine[i+1]++
+		}
+	}
+	var out []string
+	for i := 0; i < len(lines); i++ {
+		if lines[i].Op == ' ' {
+			continue
+		}
+		start := max(i-context, 0)
+		end := i
+		for j := i; j < len(lines) && j-end <= 2*context; j++ {
+			if lines[j].Op != ' ' {
+				end = j
+			}
+		}
+		stop := min(end+context+1, len(lines))
+		out = append(out, fmt.Sprintf("@@ -%d,%d +%d,%d @@", aLine[start]+1, aLine[stop]-aLine[start], bLine[start]+1, bLine[stop]-bLine[start]))
+		for _, line := range lines[start:stop] {
+			out = append(out, string(line.Op)+line.Text)
+		}
+		i = stop - 1
+	}
+	return out
+}
+
+func splitLines(content []byte) []string {
+	text := strings.TrimSuffix(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
+
+func (app *App) openRevisions() error {
+	item := app.selectedItem()
+	if !isFile(item.Path) {
+		return nil
+	}
+	revisions := readRevisions(item.Path, app.rootItem.Path)
+	if len(revisions) == 0 {
+		return userErr{"No revisions of this note yet, they're saved when it's edited"}
+	}
+	info, err := os.Stat(item.Path)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %v", item.Path, err)
+	}
+	current := Revision{item.Path, info.ModTime()}
+	app.revisions = &Revisions{path: item.Path, revisions: append([]Revision{current}, revisions...), base: -1}
+	app.setFocus(FocusRevisions)
+	return nil
+}
+
+func (app *App) closeRevisions() {
+	if app.revisions.diff != nil {
+		app.revisions.diff = nil
+		return
+	}
+	app.revisions = nil
+	app.setFocus(FocusTree)
+}
+
+func (app *App) moveRevisionSelection(delta int) {
+	r := app.revisions
+	if r.diff != nil {
+		_, height := app.screen.Size()
+		r.scroll = max(min(r.scroll+delta, len(r.diff)-(height-2)), 0)
+		return
+	}
+	r.selection = max(min(r.selection+delta, len(r.revisions)-1), 0)
+}
+
+// Mark the selected revision as the one to compare others with, or unmark it
+func (app *App) markRevision() {
+	r := app.revisions
+	if r.diff != nil {
+		return
+	}
+	if r.base == r.selection {
+		r.base = -1
+	} else {
+		r.base = r.selection
+	}
+}
+
+// Diff the selected revision with the marked one, or with the current version
+// when none is marked, older revision first
+func (app *App) diffRevisions() error {
+	r := app.revisions
+	if r.diff != nil {
+		return nil
+	}
+	other := r.base
+	if other == -1 || other == r.selection {
+		other = 0
+		if r.selection == 0 {
+			other = 1
+		}
+	}
+	older, newer := r.revisions[max(r.selection, other)], r.revisions[min(r.selection, other)]
+	a, err := os.ReadFile(older.Path)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %v", older.Path, err)
+	}
+	b, err := os.ReadFile(newer.Path)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %v", newer.Path, err)
+	}
+	diff := contentDiff(a, b)
+	if len(diff) == 0 {
+		return userErr{"The revisions are the same"}
+	}
+	r.diff = append([]string{"--- " + app.revisionName(older), "+++ " + app.revisionName(newer)}, diff...)
+	r.scroll = 0
+	return nil
+}
+
+func (app *App) revisionName(revision Revision) string {
+	if revision.Path == app.revisions.path {
+		return "current"
+	}
+	return revision.Time.Format("2006-01-02 15:04:05")
+}
+
+func renderRevisions(app *App) {
+	screen := app.screen
+	r := app.revisions
+	screen.Clear()
+	width, height := screen.Size()
+	area := Rect{0, 0, width, height - 2}
+
+	var status string
+	if r.diff != nil {
+		for i, line := range r.diff[min(r.scroll, len(r.diff)):] {
+			if i >= area.Height {
+				break
+			}
+			style := tcell.StyleDefault
+			switch {
+			case strings.HasPrefix(line, "@@"):
+				style = theme.Accent
+			case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+				style = theme.Heading
+			case strings.HasPrefix(line, "+"):
+				style = theme.Added
+			case strings.HasPrefix(line, "-"):
+				style = theme.Removed
+			}
+			renderText(area.X, area.Y+i, strings.ReplaceAll(line, "\t", "    "), style, screen)
+		}
+		status = "↑/↓: Scroll | PgUp/PgDn: Page | Esc: Revisions"
+	} else {
+		offset := max(r.selection-area.Height+1, 0)
+		for i, revision := range r.revisions[offset:] {
+			if i >= area.Height {
+				break
+			}
+			marker := "  "
+			if offset+i == r.base {
+				marker = "● "
+			}
+			style := tcell.StyleDefault
+			if offset+i == r.selection {
+				style = theme.Selection
+			}
+			line := marker + revision.Time.Format("2006-01-02 15:04:05")
+			if offset+i == 0 {
+				line += "  current"
+			}
+			renderText(area.X, area.Y+i, line, style, screen)
+		}
+		status = fmt.Sprintf("%s  ↑/↓: Select | Space: Mark | Enter: Diff with marked or current | Esc: Tree", app.relativePath(r.path))
+	}
+
+	renderHorizontalSeparator(0, height-2, width, separatorRune(app.config.HorizontalSeparator), screen)
+	label := " REVISIONS "
+	renderClearArea(0, height-1, width, height, screen)
+	renderText(0, height-1, label, theme.FooterLabel, screen)
+	renderText(len(label)+1, height-1, status, theme.Footer, screen)
+	screen.Show()
+}
@@ -6,8 +6,12 @@ import (
 	"github.com/mattn/go-runewidth"
 	"os"
 	"os/exec"
+	"strings"
 )
 
+// Set from the config at startup, for the screens created after editing too
+var mouseEnabled bool
+
 func initScreen() (tcell.Screen, error) {
 	screen, err := tcell.NewScreen()
 	if err != nil {
@@ -17,11 +21,40 @@ func initScreen() (tcell.Screen, error) {
 	if err != nil {
 		return nil, fmt.Errorf("error initializing screen: %v", err)
 	}
-	return screen, nil
+	if mouseEnabled {
+		screen.EnableMouse(tcell.MouseButtonEvents)
+	}
+	return &countingScreen{Screen: screen}, nil
+}
+
+// Screen counting the frames it showed, so views repainting only what changed
+// can tell whether anything else drew since their last frame
+type countingScreen struct {
+	tcell.Screen
+	shown int
+}
+
+func (s *countingScreen) Show() {
+	s.shown++
+	s.Screen.Show()
+}
+
+func (s *countingScreen) Sync() {
+	s.shown++
+	s.Screen.Sync()
+}
+
+// The number of frames the screen showed, -1 when it doesn't count them
+func shownFrames(screen tcell.Screen) int {
+	if s, ok := screen.(*countingScreen); ok {
+		return s.shown
+	}
+	return -1
 }
 
 func resetScreen(screen tcell.Screen) {
 	screen.Fini()
+	restoreTerminalTitle()
 	cmd := exec.Command("stty", "sane")
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
@@ -33,11 +66,20 @@ func renderError(message string, screen tcell.Screen) {
 	width, height := screen.Size()
 	y := height - 1 // Display the message at the bottom of the screen
 	renderClearArea(0, y, width, height, screen)
-	renderText(0, y, "Error: "+message+" (Press any key to continue)", tcell.StyleDefault.Foreground(tcell.ColorRed), screen)
+	renderText(0, y, "Error: "+message+" (Press any key to continue)", theme.Error, screen)
 	screen.Show()
 	screen.PollEvent() // Wait for a key press to continue
 }
 
+func renderMessage(message string, screen tcell.Screen) {
+	width, height := screen.Size()
+	y := height - 1
+	renderClearArea(0, y, width, height, screen)
+	renderText(0, y, message+" (Press any key to continue)", tcell.StyleDefault, screen)
+	screen.Show()
+	screen.PollEvent()
+}
+
 // Helper function to clear a rectangular area on the screen
 func renderClearArea(x1, y1, x2, y2 int, screen tcell.Screen) {
 	for x := x1; x < x2; x++ {
@@ -47,22 +89,64 @@ func renderClearArea(x1, y1, x2, y2 int, screen tcell.Screen) {
 	}
 }
 
-func renderHorizontalSeparator(x, y, width int, screen tcell.Screen) {
+// Clear the screen around an area that's about to be drawn over, leaving its
+// cells alone
+func renderClearAround(area Rect, screen tcell.Screen) {
+	width, height := screen.Size()
+	if area.Width <= 0 || area.Height <= 0 {
+		renderClearArea(0, 0, width, height, screen)
+		return
+	}
+	renderClearArea(0, 0, width, area.Y, screen)
+	renderClearArea(0, area.Y+area.Height, width, height, screen)
+	renderClearArea(0, area.Y, area.X, area.Y+area.Height, screen)
+	renderClearArea(area.X+area.Width, area.Y, width, area.Y+area.Height, screen)
+}
+
+func renderHorizontalSeparator(x, y, width int, r rune, screen tcell.Screen) {
 	for i := x; i < width; i++ {
-		screen.SetContent(i, y, '─', nil, tcell.StyleDefault)
+		screen.SetContent(i, y, r, nil, theme.Separator)
 	}
 }
 
-func renderFooter(selectedItem TreeItem, screen tcell.Screen) {
-	width, height := screen.Size()
-	hint := "M: Move | R: Rename | D: Delete | Q: Quit"
-	if isDir(selectedItem.Path) {
-		hint = "N: New | " + hint
-	} else {
-		hint = "E: Edit | " + hint
+// Render the pane label and the status line, and the vault status line below
+// them when it's configured
+func renderFooter(app *App, layout Layout) {
+	screen := app.screen
+	selectedItem, focus, filter, readOnly := app.selectedItem(), app.focus, app.treeStatus(), app.config.ReadOnly
+	width, _ := screen.Size()
+	var hint string
+	switch {
+	case focus == FocusPreview:
+		hint = "↑/↓: Scroll | PgUp/PgDn: Page | /: Search | n/N: Next/Prev | Tab: Tree | Q: Quit"
+	case focus == FocusOutline && readOnly:
+		hint = "↑/↓: Select heading | Esc: Tree"
+	case focus == FocusOutline:
+		hint = "↑/↓: Select heading | Enter: Edit at heading | Esc: Tree"
+	case readOnly:
+		hint = "Enter: Read | /: Search | Tab: Preview | Q: Quit"
+	default:
+		hint = "M: Move | R: Rename | D: Delete | u: Undo | Tab: Preview | Q: Quit"
+		if isDir(selectedItem.Path) {
+			hint = "N: New | " + hint
+		} else {
+			hint = "E: Edit | L: Label | " + hint
+		}
+	}
+	label := " " + strings.ToUpper(focus.String()) + " "
+	if readOnly {
+		label += "[read-only] "
+	}
+	if filter != "" {
+		label += "[" + filter + "] "
+	}
+	renderClearArea(0, layout.FooterY, width, layout.FooterY+1, screen)
+	renderText(0, layout.FooterY, label, theme.FooterLabel, screen)
+	renderText(runewidth.StringWidth(label)+1, layout.FooterY, app.expandStatusLine(app.config.StatusLine, hint), theme.Footer, screen)
+	if layout.VaultStatusY >= 0 {
+		renderClearArea(0, layout.VaultStatusY, width, layout.VaultStatusY+1, screen)
+		renderText(0, layout.VaultStatusY, app.expandStatusLine(app.config.VaultStatusLine, hint), theme.Footer, screen)
 	}
-	renderClearArea(0, height-1, width, height, screen)
-	renderText(0, height-1, hint, tcell.StyleDefault, screen)
 }
 
 // Render a line of text on the screen at a given x, y position
@@ -73,3 +157,57 @@ func renderText(x, y int, text string, style tcell.Style, screen tcell.Screen) {
 		col += runewidth.RuneWidth(r)
 	}
 }
+
+const (
+	minScreenWidth  = 60
+	minScreenHeight = 15
+)
+
+func isScreenTooSmall(screen tcell.Screen) bool {
+	width, height := screen.Size()
+	return width < minScreenWidth || height < minScreenHeight
+}
+
+// Render a notice in place of the panes until the terminal is resized to a workable size
+func renderTooSmall(screen tcell.Screen) {
+	screen.Clear()
+	width, height := screen.Size()
+	lines := []string{
+		fmt.Sprintf("terminal too small (need %dx%d)", minScreenWidth, minScreenHeight),
+		fmt.Sprintf("current size %dx%d", width, height),
+	}
+	y := height/2 - len(lines)/2
+	for i, line := range lines {
+		x := (width - runewidth.StringWidth(line)) / 2
+		if x < 0 {
+			x = 0
+		}
+		renderText(x, y+i, line, tcell.StyleDefault, screen)
+	}
+	screen.Show()
+}
+
+// Set the terminal window title with OSC 2, saving the previous title on the
+// terminal's title stack the first time so it can be restored on exit
+func setTerminalTitle(title string) {
+	if !terminalTitleSaved {
+		fmt.Fprint(os.Stdout, "\x1b[22;0t")
+		terminalTitleSaved = true
+	}
+	title = strings.Map(func(r rune) rune {
+		if r < ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, title)
+	fmt.Fprint(os.Stdout, "\x1b]2;"+title+"\x07")
+}
+
+var terminalTitleSaved bool
+
+func restoreTerminalTitle() {
+	if terminalTitleSaved {
+		fmt.Fprint(os.Stdout, "\x1b[23;0t")
+		terminalTitleSaved = false
+	}
+}
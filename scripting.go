@@ -0,0 +1,245 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// jsonTreeItem is a file or directory of the vault in notes tree --json
+type jsonTreeItem struct {
+	Name      string         `json:"name"`
+	Path      string         `json:"path"`
+	Dir       bool           `json:"dir"`
+	Label     string         `json:"label,omitempty"`
+	Fields    map[string]any `json:"fields,omitempty"`
+	Tasks     int            `json:"tasks,omitempty"`
+	TasksDone int            `json:"tasks_done,omitempty"`
+	Notes     int            `json:"notes,omitempty"`
+	Children  []jsonTreeItem `json:"children,omitempty"`
+}
+
+// jsonSearchResult is a matching line in notes search --json
+type jsonSearchResult struct {
+	Path string `json:"path"`
+	Line int    `json:"line"`
+	Text string `json:"text"`
+}
+
+// jsonNoteMeta is what notes meta --json reports about a note
+type jsonNoteMeta struct {
+	Path      string         `json:"path"`
+	Title     string         `json:"title"`
+	Size      int64          `json:"size"`
+	Modified  time.Time      `json:"modified"`
+	Words     int            `json:"words"`
+	Tasks     int            `json:"tasks"`
+	TasksDone int            `json:"tasks_done"`
+	Tags      []string       `json:"tags"`
+	Links     []jsonLink     `json:"links"`
+	Fields    map[string]any `json:"fields"`
+}
+
+// jsonLink is a link of a note: a path relative to the vault, or the
+// lowercase name a wiki-link points at
+type jsonLink struct {
+	Target string `json:"target"`
+	Wiki   bool   `json:"wiki"`
+	Line   int    `json:"line"`
+}
+
+func printJSON(value any) error {
+	out, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding JSON: %v", err)
+	}
+	fmt.Println(string(out))
+	return nil
+}
+
+// Path of a file of the vault relative to its root, with forward slashes
+func vaultRelative(root string, path string) string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
+
+func jsonTree(root string, item TreeItem) jsonTreeItem {
+	node := jsonTreeItem{
+		Name:      item.Display,
+		Path:      vaultRelative(root, item.Path),
+		Dir:       item.IsDir,
+		Label:     item.Label,
+		Fields:    item.Fields,
+		Tasks:     item.Tasks,
+		TasksDone: item.TasksDone,
+		Notes:     item.Notes,
+	}
+	for _, child := range item.Children {
+		node.Children = append(node.Children, jsonTree(root, child))
+	}
+	return node
+}
+
+func printTree(item TreeItem, depth int) {
+	name := item.Display
+	if item.IsDir {
+		name += "/"
+	}
+	fmt.Println(strings.Repeat("  ", depth) + name)
+	for _, child := range item.Children {
+		printTree(child, depth+1)
+	}
+}
+
+func runTreeSubcommand(args []string) error {
+	flags := flag.NewFlagSet("tree", flag.ContinueOnError)
+	d := flags.String("d", "", "Path to directory with notes")
+	asJSON := flags.Bool("json", false, "Print the tree as nested JSON")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *d == "" || flags.NArg() != 0 {
+		return fmt.Errorf("usage: notes tree -d dir [--json]")
+	}
+	config, err := loadConfig(configFilePath())
+	if err != nil {
+		return err
+	}
+	if !isDir(*d) {
+		return fmt.Errorf("error: not a directory: %s", *d)
+	}
+	tree := buildTree(*d, config.TreeSort)
+	if *asJSON {
+		return printJSON(jsonTree(*d, tree))
+	}
+	printTree(tree, 0)
+	return nil
+}
+
+func runSearchSubcommand(args []string) error {
+	flags := flag.NewFlagSet("search", flag.ContinueOnError)
+	d := flags.String("d", "", "Path to directory with notes")
+	asJSON := flags.Bool("json", false, "Print the matches as a JSON array")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *d == "" || flags.NArg() == 0 {
+		return fmt.Errorf("usage: notes search -d dir [--json] <query>")
+	}
+	config, err := loadConfig(configFilePath())
+	if err != nil {
+		return err
+	}
+	results, err := searchWithBackend(config.SearchBackend, nil, *d, strings.Join(flags.Args(), " "))
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		matches := make([]jsonSearchResult, len(results))
+		for i, result := range results {
+			matches[i] = jsonSearchResult{vaultRelative(*d, result.Path), result.Line, result.Text}
+		}
+		return printJSON(matches)
+	}
+	for _, result := range results {
+		fmt.Printf("%s:%d:%s\n", vaultRelative(*d, result.Path), result.Line, result.Text)
+	}
+	return nil
+}
+
+func noteMeta(root string, path string) (jsonNoteMeta, error) {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return jsonNoteMeta{}, fmt.Errorf("error: not a note: %s", path)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return jsonNoteMeta{}, fmt.Errorf("error reading %s: %v", path, err)
+	}
+	entry, err := indexFile(root, path, info)
+	if err != nil {
+		return jsonNoteMeta{}, fmt.Errorf("error reading %s: %v", path, err)
+	}
+	lines, body, _ := splitFrontmatter(content)
+	fields := parseFrontmatter(lines)
+	rel := vaultRelative(root, path)
+	meta := jsonNoteMeta{
+		Path:     rel,
+		Title:    publishTitle(rel, fields, string(body)),
+		Size:     info.Size(),
+		Modified: info.ModTime(),
+		Words:    len(strings.Fields(string(content))),
+		Tags:     append([]string{}, entry.Tags...),
+		Links:    []jsonLink{},
+		Fields:   fields,
+	}
+	meta.Tasks, meta.TasksDone = countTasks(path)
+	for _, link := range entry.Links {
+		name, wiki := strings.CutPrefix(link.Target, "[[")
+		meta.Links = append(meta.Links, jsonLink{filepath.ToSlash(name), wiki, link.Line})
+	}
+	return meta, nil
+}
+
+func runMetaSubcommand(args []string) error {
+	flags := flag.NewFlagSet("meta", flag.ContinueOnError)
+	d := flags.String("d", "", "Path to directory with notes")
+	asJSON := flags.Bool("json", false, "Print the metadata as a JSON object")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *d == "" || flags.NArg() != 1 {
+		return fmt.Errorf("usage: notes meta -d dir [--json] <note>")
+	}
+	path := flags.Arg(0)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(*d, path)
+	}
+	meta, err := noteMeta(*d, path)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return printJSON(meta)
+	}
+	fmt.Printf("path: %s\ntitle: %s\nsize: %d\nmodified: %s\nwords: %d\ntasks: %d/%d\n", meta.Path, meta.Title, meta.Size, meta.Modified.Format(time.RFC3339), meta.Words, meta.TasksDone, meta.Tasks)
+	fmt.Printf("tags: %s\n", strings.Join(meta.Tags, ", "))
+	for _, link := range meta.Links {
+		target := link.Target
+		if link.Wiki {
+			target = "[[" + target + "]]"
+		}
+		fmt.Printf("link: %s (line %d)\n", target, link.Line)
+	}
+	for _, key := range sortedFieldKeys(meta.Fields) {
+		fmt.Printf("field %s: %v\n", key, meta.Fields[key])
+	}
+	return nil
+}
+
+func sortedFieldKeys(fields map[string]any) []string {
+	keys := make(map[string]bool, len(fields))
+	for key := range fields {
+		keys[key] = true
+	}
+	return sortedKeys(keys)
+}
+
+// The version and build of notes in notes version --json
+func versionJSON() map[string]string {
+	return map[string]string{
+		"version": version,
+		"commit":  buildRevision(),
+		"go":      runtime.Version(),
+		"os":      runtime.GOOS,
+		"arch":    runtime.GOARCH,
+	}
+}
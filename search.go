@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const maxSearchResults = 1000
+
+type SearchResult struct {
+	Path string
+	Line int
+	Text string
+}
+
+// Search holds the results of a content search shown in the results pane
+type Search struct {
+	query     string
+	results   []SearchResult
+	selection int
+}
+
+// Find case-insensitive occurrences of query in the text files of the vault,
+// skipping hidden directories
+func searchVault(root string, query string) ([]SearchResult, error) {
+	var results []SearchResult
+	needle := strings.ToLower(query)
+	err := walkNotes(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		results = searchFile(path, needle, results)
+		if len(results) >= maxSearchResults {
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error searching %s: %v", root, err)
+	}
+	return results, nil
+}
+
+// Append the lines of a text file containing the lowercase needle to results,
+// stopping at maxSearchResults
+func searchFile(path string, needle string, results []SearchResult) []SearchResult {
+	content, err := os.ReadFile(path)
+	if err != nil || bytes.IndexByte(content[:min(len(content), 512)], 0) != -1 {
+		return results
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	line := 0
+	for scanner.Scan() {
+		line++
+		if strings.Contains(strings.ToLower(scanner.Text()), needle) {
+			results = append(results, SearchResult{Path: path, Line: line, Text: scanner.Text()})
+			if len(results) >= maxSearchResults {
+				break
+			}
+		}
+	}
+	return results
+}
+
+func (app *App) openSearch(query string) error {
+	results, err := searchWithBackend(app.config.SearchBackend, app.index, app.rootItem.Path, query)
+	if err != nil {
+		return err
+	}
+	if len(results) == 0 {
+		return userErr{fmt.Sprintf("No matches for: %s", query)}
+	}
+	app.search = &Search{query: query, results: results}
+	app.setFocus(FocusSearch)
+	return nil
+}
+
+func (app *App) promptSearch() error {
+	query, ok := getUserInput("Search notes: ", "", app.screen)
+	if !ok || query == "" {
+		return nil
+	}
+	return app.openSearch(query)
+}
+
+func runSearch(app *App, args []string) error {
+	if len(args) == 0 {
+		return app.promptSearch()
+	}
+	return app.openSearch(strings.Join(args, " "))
+}
+
+func (app *App) closeSearch() {
+	app.search = nil
+	app.setFocus(FocusTree)
+}
+
+func (app *App) moveSearchSelection(delta int) {
+	s := app.search
+	s.selection = max(min(s.selection+delta, len(s.results)-1), 0)
+}
+
+func (app *App) openSearchResult() error {
+	result := app.search.results[app.search.selection]
+	app.recordOperation("edit", result.Path)
+	if err := app.openEditor(result.Path, fmt.Sprintf("+%d", result.Line)); err != nil {
+		return err
+	}
+	app.selectPath(result.Path)
+	return nil
+}
+
+func renderSearch(app *App) {
+	screen := app.screen
+	s := app.search
+	screen.Clear()
+	width, height := screen.Size()
+	area := Rect{0, 0, width, height - 2}
+
+	offset := max(s.selection-area.Height+1, 0)
+	for i, result := range s.results[offset:] {
+		if i >= area.Height {
+			break
+		}
+		row := area.Y + i
+		location := fmt.Sprintf("%s:%d: ", app.relativePath(result.Path), result.Line)
+		locationStyle := theme.Accent
+		textStyle := tcell.StyleDefault
+		if offset+i == s.selection {
+			locationStyle = theme.Selection
+			textStyle = theme.Selection
+		}
+		renderText(area.X, row, location, locationStyle, screen)
+
+		col := area.X + runewidth.StringWidth(location)
+		snippet := []rune(strings.TrimSpace(result.Text))
+		mask := highlightMask(string(snippet), s.query)
+		for j, r := range snippet {
+			if col+runewidth.RuneWidth(r) > area.X+area.Width {
+				break
+			}
+			style := textStyle
+			if mask[j] {
+				style = overlayStyle(style, theme.Match)
+			}
+			screen.SetContent(col, row, r, nil, style)
+			col += runewidth.RuneWidth(r)
+		}
+	}
+
+	renderHorizontalSeparator(0, height-2, width, separatorRune(app.config.HorizontalSeparator), screen)
+	label := " SEARCH "
+	status := fmt.Sprintf("%d/%d matches for %q  ↑/↓: Select | Enter: Edit at line | Esc: Tree", s.selection+1, len(s.results), s.query)
+	if app.config.ReadOnly {
+		status = fmt.Sprintf("%d/%d matches for %q  ↑/↓: Select | Esc: Tree", s.selection+1, len(s.results), s.query)
+	}
+	renderClearArea(0, height-1, width, height, screen)
+	renderText(0, height-1, label, theme.FooterLabel, screen)
+	renderText(len(label)+1, height-1, status, theme.Footer, screen)
+	screen.Show()
+}
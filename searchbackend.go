@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	SearchBackendAuto    = "auto"
+	SearchBackendBuiltin = "builtin"
+	SearchBackendIndex   = "index"
+	SearchBackendRipgrep = "rg"
+	SearchBackendAg      = "ag"
+)
+
+// A match event from ripgrep's --json output, other event types are ignored
+type rgEvent struct {
+	Type string `json:"type"`
+	Data struct {
+		Path struct {
+			Text string `json:"text"`
+		} `json:"path"`
+		Lines struct {
+			Text string `json:"text"`
+		} `json:"lines"`
+		LineNumber int `json:"line_number"`
+	} `json:"data"`
+}
+
+// Run the search with the configured backend, falling back to the built-in
+// search when the external tool isn't installed or there's no index
+func searchWithBackend(backend string, index *Index, root string, query string) ([]SearchResult, error) {
+	if backend == SearchBackendAuto {
+		backend = SearchBackendIndex
+		if _, err := exec.LookPath(SearchBackendRipgrep); err == nil {
+			backend = SearchBackendRipgrep
+		}
+	}
+	if backend == SearchBackendIndex && index != nil {
+		return searchIndex(index, query), nil
+	}
+	if backend == SearchBackendBuiltin || backend == SearchBackendIndex {
+		return searchVault(root, query)
+	}
+	if _, err := exec.LookPath(backend); err != nil {
+		logger.Printf("search backend %s not found, using built-in search", backend)
+		return searchVault(root, query)
+	}
+
+	var cmd *exec.Cmd
+	var parse func(line string) (SearchResult, bool)
+	switch backend {
+	case SearchBackendRipgrep:
+		cmd = exec.Command("rg", "--json", "--ignore-case", "--fixed-strings", "--", query, root)
+		parse = parseRipgrepLine
+	case SearchBackendAg:
+		cmd = exec.Command("ag", "--nocolor", "--nogroup", "--nobreak", "--noheading", "--ignore-case", "--literal", "--", query, root)
+		parse = parseAgLine
+	}
+	results, err := runSearchCommand(cmd, parse)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Path != results[j].Path {
+			return results[i].Path < results[j].Path
+		}
+		return results[i].Line < results[j].Line
+	})
+	return results, nil
+}
+
+func runSearchCommand(cmd *exec.Cmd, parse func(line string) (SearchResult, bool)) ([]SearchResult, error) {
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("error starting %s: %v", cmd.Path, err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("error starting %s: %v", cmd.Path, err)
+	}
+
+	var results []SearchResult
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		if result, ok := parse(scanner.Text()); ok {
+			results = append(results, result)
+			if len(results) >= maxSearchResults {
+				_ = cmd.Process.Kill()
+				break
+			}
+		}
+	}
+	_, _ = io.Copy(io.Discard, stdout)
+
+	err = cmd.Wait()
+	var exitErr *exec.ExitError
+	// Both tools exit with 1 when nothing matched
+	if err != nil && len(results) < maxSearchResults && !(errors.As(err, &exitErr) && exitErr.ExitCode() == 1) {
+		return nil, fmt.Errorf("error running %s: %v", cmd.Path, err)
+	}
+	return results, nil
+}
+
+func parseRipgrepLine(line string) (SearchResult, bool) {
+	var event rgEvent
+	if err := json.Unmarshal([]byte(line), &event); err != nil || event.Type != "match" {
+		return SearchResult{}, false
+	}
+	return SearchResult{
+		Path: event.Data.Path.Text,
+		Line: event.Data.LineNumber,
+		Text: strings.TrimRight(event.Data.Lines.Text, "\r\n"),
+	}, true
+}
+
+// Parse a path:line:text line printed by ag
+func parseAgLine(line string) (SearchResult, bool) {
+	parts := strings.SplitN(line, ":", 3)
+	if len(parts) != 3 {
+		return SearchResult{}, false
+	}
+	n, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return SearchResult{}, false
+	}
+	return SearchResult{Path: parts[0], Line: n, Text: parts[2]}, true
+}
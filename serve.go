@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const servePollInterval = time.Second
+
+// webServer serves the vault rendered like notes publish, straight from the
+// notes directory, telling open pages to reload when files change
+type webServer struct {
+	root    string
+	mu      sync.Mutex
+	site    *publishSite
+	stamp   vaultStamp
+	changed chan struct{}
+}
+
+// vaultStamp changes whenever a file of the vault is added, removed or saved
+type vaultStamp struct {
+	files   int
+	size    int64
+	modTime time.Time
+}
+
+func stampVault(root string) vaultStamp {
+	var stamp vaultStamp
+	_ = walkNotes(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		stamp.files++
+		stamp.size += info.Size()
+		if info.ModTime().After(stamp.modTime) {
+			stamp.modTime = info.ModTime()
+		}
+		return nil
+	})
+	return stamp
+}
+
+// Check the vault for changes until the process exits, waking the pages
+// waiting for a reload when it changed
+func (server *webServer) watch() {
+	for range time.Tick(servePollInterval) {
+		stamp := stampVault(server.root)
+		server.mu.Lock()
+		if stamp != server.stamp {
+			server.stamp, server.site = stamp, nil
+			close(server.changed)
+			server.changed = make(chan struct{})
+		}
+		server.mu.Unlock()
+	}
+}
+
+// The notes and files of the vault, collected again after a change
+func (server *webServer) currentSite() (*publishSite, error) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if server.site == nil {
+		site := &publishSite{root: server.root}
+		if err := site.collect(); err != nil {
+			return nil, err
+		}
+		server.site = site
+	}
+	return server.site, nil
+}
+
+// Hold the request open as an event stream and send an event once the vault
+// changes, which the page answers by reloading
+func (server *webServer) serveLive(w http.ResponseWriter, r *http.Request) {
+	server.mu.Lock()
+	changed := server.changed
+	server.mu.Unlock()
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	select {
+	case <-changed:
+		fmt.Fprint(w, "data: reload\n\n")
+	case <-r.Context().Done():
+	}
+}
+
+func (server *webServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "read-only", http.StatusMethodNotAllowed)
+		return
+	}
+	if r.URL.Path == "/.live" {
+		server.serveLive(w, r)
+		return
+	}
+	site, err := server.currentSite()
+	if err != nil {
+		logger.Printf("%v", err)
+		http.Error(w, "error reading the vault", http.StatusInternalServerError)
+		return
+	}
+	rel := strings.TrimPrefix(path.Clean(r.URL.Path), "/")
+	if rel == "" || strings.HasSuffix(r.URL.Path, "/") {
+		rel = path.Join(rel, "index.html")
+	} else if site.dirs[rel] {
+		http.Redirect(w, r, r.URL.Path+"/", http.StatusMovedPermanently)
+		return
+	}
+
+	// Hidden and encrypted files aren't collected, so they are never served
+	page, found := publishPage{}, false
+	for _, note := range site.notes {
+		if htmlPath(note) == rel {
+			if page, err = site.notePage(note); err != nil {
+				logger.Printf("%v", err)
+				http.Error(w, "error reading the note", http.StatusInternalServerError)
+				return
+			}
+			found = true
+			break
+		}
+	}
+	if dir := path.Dir(rel); !found && path.Base(rel) == "index.html" && site.dirs[dir] {
+		titles := make(map[string]string)
+		for _, note := range site.notes {
+			if path.Dir(note) == dir {
+				titles[note] = site.noteTitle(note)
+			}
+		}
+		page, found = site.listingPage(dir, titles)
+	}
+	if !found {
+		if slices.Contains(site.files, rel) {
+			http.ServeFile(w, r, filepath.Join(site.root, filepath.FromSlash(rel)))
+			return
+		}
+		http.NotFound(w, r)
+		return
+	}
+	page.LiveReload = true
+	var b bytes.Buffer
+	if err := publishTemplate.Execute(&b, page); err != nil {
+		logger.Printf("error rendering %s: %v", rel, err)
+		http.Error(w, "error rendering the note", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write(b.Bytes())
+}
+
+func runServeSubcommand(args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	web := flags.Bool("web", false, "Serve the vault as HTML pages")
+	d := flags.String("d", "", "Path to directory with notes")
+	addr := flags.String("addr", "localhost:8080", "Address to listen on, e.g. :8080 for the whole LAN")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if !*web || *d == "" || flags.NArg() != 0 {
+		return fmt.Errorf("usage: notes serve --web -d dir [--addr host:port]")
+	}
+	root, err := filepath.Abs(*d)
+	if err != nil {
+		return fmt.Errorf("error resolving %s: %v", *d, err)
+	}
+	if !isDir(root) {
+		return fmt.Errorf("error: not a directory: %s", root)
+	}
+	server := &webServer{root: root, stamp: stampVault(root), changed: make(chan struct{})}
+	go server.watch()
+	fmt.Printf("Serving %s at http://%s, Ctrl+C to stop\n", root, *addr)
+	if err := http.ListenAndServe(*addr, server); err != nil {
+		return fmt.Errorf("error serving %s: %v", root, err)
+	}
+	return nil
+}
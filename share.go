@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Where notes are shared, set by the backend of the share config
+const (
+	shareGist     = "gist"
+	sharePastebin = "pastebin"
+)
+
+const gistsURL = "https://api.github.com/gists"
+
+// ShareConfig is where the share action uploads notes: a secret GitHub gist
+// created with a token, or a pastebin taking the note as the request body and
+// answering with its URL
+type ShareConfig struct {
+	Backend      string `json:"backend"`
+	Token        string `json:"token"`
+	TokenCommand string `json:"token_command"`
+	Public       bool   `json:"public"`
+	URL          string `json:"url"`
+}
+
+func validateShare(share ShareConfig) error {
+	switch share.Backend {
+	case shareGist:
+	case sharePastebin:
+		if !strings.HasPrefix(share.URL, "http://") && !strings.HasPrefix(share.URL, "https://") {
+			return fmt.Errorf("error: share needs the url of the pastebin")
+		}
+	default:
+		return fmt.Errorf("error: share backend must be %s or %s", shareGist, sharePastebin)
+	}
+	return nil
+}
+
+// The GitHub token from the config, its token_command or GITHUB_TOKEN
+func (share ShareConfig) token() (string, error) {
+	if share.Token != "" {
+		return share.Token, nil
+	}
+	if share.TokenCommand != "" {
+		output, err := shellCommand(share.TokenCommand).Output()
+		if err != nil {
+			return "", fmt.Errorf("error running the share token_command: %v", err)
+		}
+		return strings.TrimSpace(string(output)), nil
+	}
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+		return token, nil
+	}
+	return "", userErr{"No GitHub token, set token or token_command in the share config"}
+}
+
+// Create a gist with the note as its only file, returning its page
+func uploadGist(share ShareConfig, name string, content []byte) (string, error) {
+	token, err := share.token()
+	if err != nil {
+		return "", err
+	}
+	body, err := json.Marshal(map[string]any{
+		"description": name,
+		"public":      share.Public,
+		"files":       map[string]any{name: map[string]string{"content": string(content)}},
+	})
+	if err != nil {
+		return "", fmt.Errorf("error encoding gist: %v", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, gistsURL, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("error creating gist request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error creating gist: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return "", userErr{"Creating the gist failed: " + resp.Status}
+	}
+	var gist struct {
+		HTMLURL string `json:"html_url"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&gist); err != nil {
+		return "", fmt.Errorf("error decoding gist: %v", err)
+	}
+	return gist.HTMLURL, nil
+}
+
+// Post the note to the pastebin, returning the URL it answered with
+func uploadPaste(share ShareConfig, content []byte) (string, error) {
+	resp, err := httpClient.Post(share.URL, "text/plain; charset=utf-8", bytes.NewReader(content))
+	if err != nil {
+		return "", fmt.Errorf("error uploading to %s: %v", share.URL, err)
+	}
+	defer resp.Body.Close()
+	answer, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return "", fmt.Errorf("error uploading to %s: %v", share.URL, err)
+	}
+	url := strings.TrimSpace(strings.Split(strings.TrimSpace(string(answer)), "\n")[0])
+	if resp.StatusCode >= 300 || !strings.HasPrefix(url, "http") {
+		return "", userErr{fmt.Sprintf("Uploading to the pastebin failed: %s", resp.Status)}
+	}
+	return url, nil
+}
+
+// Upload the selected note to the configured gist or pastebin after
+// confirming, returning its URL
+func (app *App) uploadSelected() (string, bool, error) {
+	share := app.config.Share
+	if share.Backend == "" {
+		return "", false, userErr{"Set up sharing with the share config first"}
+	}
+	path := app.selectedItem().Path
+	if !isFile(path) {
+		return "", false, nil
+	}
+	if isEncryptedFile(path) {
+		return "", false, userErr{"Can't share an encrypted note"}
+	}
+	name := app.relativePath(path)
+	where := "a secret gist"
+	switch {
+	case share.Backend == sharePastebin:
+		where = share.URL
+	case share.Public:
+		where = "a public gist"
+	}
+	if !getConfirmation(fmt.Sprintf("Upload %s to %s? (y/N): ", name, where), app.screen) {
+		return "", false, nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", false, fmt.Errorf("error reading %s: %v", path, err)
+	}
+	app.recordOperation("share", path)
+	var url string
+	if share.Backend == shareGist {
+		url, err = uploadGist(share, filepath.Base(path), content)
+	} else {
+		url, err = uploadPaste(share, content)
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return url, true, nil
+}
+
+// Share the selected note and copy its URL to the clipboard
+func (app *App) shareNote() error {
+	url, ok, err := app.uploadSelected()
+	if !ok {
+		return err
+	}
+	if err := copyToClipboard(url); err != nil {
+		renderMessage("Shared at "+url, app.screen)
+		return nil
+	}
+	app.toast = "Copied " + url
+	return nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const snippetsDirName = ".snippets"
+
+// Vault relative paths of the snippets, subdirectories of the snippets
+// directory grouping them
+func listSnippets(rootItemPath string) []string {
+	dir := filepath.Join(rootItemPath, snippetsDirName)
+	var names []string
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if rel, err := filepath.Rel(dir, path); err == nil {
+			names = append(names, rel)
+		}
+		return nil
+	})
+	return names
+}
+
+// Pick a snippet and append it to the selected note, or to the inbox note
+// when a directory is selected, without opening the editor. Snippets are
+// expanded like templates.
+func (app *App) insertSnippet() error {
+	snippets := listSnippets(app.rootItem.Path)
+	if len(snippets) == 0 {
+		return userErr{fmt.Sprintf("No snippets in %s", snippetsDirName)}
+	}
+	path := app.selectedItem().Path
+	if !isFile(path) {
+		path = filepath.Join(app.rootItem.Path, app.config.Inbox)
+	}
+	if isEncryptedFile(path) {
+		return userErr{"Can't insert a snippet into an encrypted note"}
+	}
+
+	name, ok := pickFromList("Insert snippet into "+app.relativePath(path), "snippets", snippets, false, app.screen)
+	if !ok {
+		return nil
+	}
+	snippetPath := filepath.Join(app.rootItem.Path, snippetsDirName, name)
+	source, err := os.ReadFile(snippetPath)
+	if err != nil {
+		return fmt.Errorf("error reading snippet %s: %v", snippetPath, err)
+	}
+	body, variables := parseTemplateHeader(string(source))
+	values, ok := promptTemplateVariables(variables, app.screen)
+	if !ok {
+		return nil
+	}
+	snippet, err := expandTemplateVariables(expandUserVariables(body, values), time.Now())
+	if err != nil {
+		return err
+	}
+	snippet = strings.ReplaceAll(snippet, cursorMarker, "")
+
+	content, err := os.ReadFile(path)
+	created := os.IsNotExist(err)
+	if err != nil && !created {
+		return fmt.Errorf("error reading %s: %v", path, err)
+	}
+	if len(content) > 0 && !strings.HasSuffix(string(content), "\n") {
+		content = append(content, '\n')
+	}
+	content = append(content, snippet...)
+	if !strings.HasSuffix(snippet, "\n") {
+		content = append(content, '\n')
+	}
+
+	app.recordOperation("insert-snippet", path)
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return fmt.Errorf("error creating directory %s: %v", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		return fmt.Errorf("error writing %s: %v", path, err)
+	}
+	if created {
+		app.fireHook(hookNoteCreated, path, "")
+		app.rebuild()
+	} else {
+		app.fireHook(hookNoteEdited, path, "")
+	}
+	return nil
+}
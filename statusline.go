@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	defaultStatusLine = "{hints}"
+	// The git state in the vault status line is refreshed after changes to
+	// the tree and at most this often otherwise
+	vaultStatusInterval = 10 * time.Second
+)
+
+var statusPlaceholderRegex = regexp.MustCompile(`\{([a-z]+)\}`)
+
+// Placeholders the status line templates can use
+var statusPlaceholders = []string{"path", "name", "wordcount", "modified", "sort", "filter", "notes", "vault", "branch", "sync", "hints"}
+
+// VaultStatus is the git state of the vault shown in the status lines
+type VaultStatus struct {
+	branch  string
+	sync    string
+	checked time.Time
+}
+
+type wordCount struct {
+	path    string
+	modTime time.Time
+	count   int
+}
+
+// Check a status line template only uses known placeholders
+func validateStatusLine(template string) error {
+	for _, match := range statusPlaceholderRegex.FindAllStringSubmatch(template, -1) {
+		if !slices.Contains(statusPlaceholders, match[1]) {
+			return fmt.Errorf("error: unknown placeholder {%s} in status line, use one of {%s}", match[1], strings.Join(statusPlaceholders, "}, {"))
+		}
+	}
+	return nil
+}
+
+// Fill in the placeholders of a status line template, with hints being the
+// key hints of the focused pane
+func (app *App) expandStatusLine(template string, hints string) string {
+	return statusPlaceholderRegex.ReplaceAllStringFunc(template, func(placeholder string) string {
+		switch strings.Trim(placeholder, "{}") {
+		case "path":
+			if app.selectedItem().Path == app.rootItem.Path {
+				return app.vaultTitle()
+			}
+			return app.relativePath(app.selectedItem().Path)
+		case "name":
+			return filepath.Base(app.selectedItem().Path)
+		case "wordcount":
+			if count, ok := app.selectedWordCount(); ok {
+				return fmt.Sprintf("%d words", count)
+			}
+			return ""
+		case "modified":
+			if info, err := os.Stat(app.selectedItem().Path); err == nil {
+				return info.ModTime().Format("2006-01-02 15:04")
+			}
+			return ""
+		case "sort":
+			if app.sortField == "" {
+				return "name"
+			}
+			if app.sortDescending {
+				return app.sortField + " ↓"
+			}
+			return app.sortField + " ↑"
+		case "filter":
+			return app.treeStatus()
+		case "notes":
+			return fmt.Sprintf("%d notes", app.noteCount())
+		case "vault":
+			return app.vaultTitle()
+		case "branch":
+			return app.currentVaultStatus().branch
+		case "sync":
+			return app.currentVaultStatus().sync
+		case "hints":
+			return hints
+		}
+		return placeholder
+	})
+}
+
+// Words in the selected note, counted again only when it changes. Folders
+// and encrypted notes have none.
+func (app *App) selectedWordCount() (int, bool) {
+	path := app.selectedItem().Path
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() || isEncryptedFile(path) {
+		return 0, false
+	}
+	if app.wordCount.path == path && app.wordCount.modTime.Equal(info.ModTime()) {
+		return app.wordCount.count, true
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+	app.wordCount = wordCount{path, info.ModTime(), len(strings.Fields(string(content)))}
+	return app.wordCount.count, true
+}
+
+func (app *App) noteCount() int {
+	count := 0
+	for _, item := range app.flatTree {
+		if !item.IsDir {
+			count++
+		}
+	}
+	return count
+}
+
+func (app *App) currentVaultStatus() VaultStatus {
+	if time.Since(app.vaultStatus.checked) > vaultStatusInterval {
+		app.vaultStatus = readVaultStatus(app.rootItem.Path, app.hasTool("git"))
+	}
+	return app.vaultStatus
+}
+
+// The branch and pending changes of the vault when it's a git repository: the
+// number of uncommitted files and commits ahead of or behind the upstream
+func readVaultStatus(rootItemPath string, hasGit bool) VaultStatus {
+	status := VaultStatus{checked: time.Now()}
+	if !hasGit {
+		return status
+	}
+	output, err := exec.Command("git", "-C", rootItemPath, "status", "--porcelain", "--branch").Output()
+	if err != nil {
+		return status
+	}
+	lines := strings.Split(strings.TrimRight(string(output), "\n"), "\n")
+	header := strings.TrimPrefix(lines[0], "## ")
+	header = strings.TrimPrefix(header, "No commits yet on ")
+	branch, tracking, _ := strings.Cut(header, "...")
+	if fields := strings.Fields(branch); len(fields) > 0 {
+		status.branch = fields[0]
+	}
+
+	var pending []string
+	if changed := len(lines) - 1; changed > 0 {
+		pending = append(pending, fmt.Sprintf("%d changed", changed))
+	}
+	if _, counts, ok := strings.Cut(tracking, "["); ok {
+		for _, count := range strings.Split(strings.TrimSuffix(counts, "]"), ", ") {
+			count = strings.Replace(count, "ahead ", "↑", 1)
+			pending = append(pending, strings.Replace(count, "behind ", "↓", 1))
+		}
+	}
+	status.sync = strings.Join(pending, " ")
+	if status.sync == "" {
+		status.sync = "clean"
+	}
+	return status
+}
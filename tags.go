@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const tagsField = "tags"
+
+type tagChange struct {
+	path    string
+	content []byte
+	count   int
+}
+
+// Replace tag from with to in the frontmatter tags list and in inline #tags of
+// the body, returning the new content and the number of replacements. Tags
+// match case-insensitively and a list already holding to isn't duplicated.
+func renameTag(content []byte, from string, to string) ([]byte, int) {
+	count := 0
+	lines, body, hasFrontmatter := splitFrontmatter(content)
+	prefix := content[:len(content)-len(body)]
+
+	var out []byte
+	last := 0
+	for _, m := range inlineTagRegex.FindAllSubmatchIndex(body, -1) {
+		if !strings.EqualFold(string(body[m[2]:m[3]]), from) {
+			continue
+		}
+		out = append(out, body[last:m[2]]...)
+		out = append(out, to...)
+		last = m[3]
+		count++
+	}
+	out = append(out, body[last:]...)
+	content = append(append([]byte{}, prefix...), out...)
+
+	if hasFrontmatter {
+		tags := frontmatterList(parseFrontmatter(lines), tagsField)
+		var renamed []string
+		seen := make(map[string]bool)
+		replaced := false
+		for _, tag := range tags {
+			if strings.EqualFold(tag, from) {
+				tag = to
+				replaced = true
+				count++
+			}
+			if !seen[strings.ToLower(tag)] {
+				seen[strings.ToLower(tag)] = true
+				renamed = append(renamed, tag)
+			}
+		}
+		if replaced {
+			content = setFrontmatterField(content, tagsField, renamed)
+		}
+	}
+	return content, count
+}
+
+// Rename a tag across the vault, or merge it into another one when the new tag
+// is already in use, after confirming a summary of the changes
+func runRenameTag(app *App, args []string) error {
+	if len(args) != 2 {
+		return userErr{"Usage: rename-tag <old> <new>"}
+	}
+	from := strings.ToLower(strings.TrimPrefix(args[0], "#"))
+	to := strings.TrimPrefix(args[1], "#")
+	if from == "" || to == "" || strings.EqualFold(from, to) {
+		return userErr{"Give two different tags"}
+	}
+	if app.index == nil {
+		return userErr{"The index isn't available"}
+	}
+
+	var changes []tagChange
+	occurrences := 0
+	merge := false
+	for _, rel := range sortedFiles(app.index.Files) {
+		path := filepath.Join(app.index.Root, rel)
+		if app.index.hasTag(path, strings.ToLower(to)) {
+			merge = true
+		}
+		if !app.index.hasTag(path, from) {
+			continue
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("error reading %s: %v", path, err)
+		}
+		renamed, count := renameTag(content, from, to)
+		if count > 0 {
+			changes = append(changes, tagChange{path, renamed, count})
+			occurrences += count
+		}
+	}
+	if len(changes) == 0 {
+		return userErr{fmt.Sprintf("No notes tagged #%s", from)}
+	}
+
+	verb := "Rename"
+	if merge {
+		verb = "Merge"
+	}
+	prompt := fmt.Sprintf("%s #%s into #%s in %d notes (%d occurrences)? (y/N): ", verb, from, to, len(changes), occurrences)
+	if !getConfirmation(prompt, app.screen) {
+		return nil
+	}
+
+	app.recordOperation("rename-tag", "#"+from+" #"+to)
+	var paths []string
+	for _, change := range changes {
+		paths = append(paths, change.path)
+	}
+	if err := app.backup("rename-tag", paths...); err != nil {
+		return err
+	}
+	defer app.rebuild()
+	for _, change := range changes {
+		info, err := os.Stat(change.path)
+		if err != nil {
+			return fmt.Errorf("error reading %s: %v", change.path, err)
+		}
+		if err := os.WriteFile(change.path, change.content, info.Mode()); err != nil {
+			return fmt.Errorf("error writing %s: %v", change.path, err)
+		}
+	}
+	return nil
+}
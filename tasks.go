@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Count the tasks of a markdown note outside fenced code blocks and how many
+// of them are done, none for other files
+func countTasks(path string) (int, int) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown":
+	default:
+		return 0, 0
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, 0
+	}
+	defer file.Close()
+
+	total, done := 0, 0
+	inFence := false
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if trimmed := strings.TrimSpace(line); strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+			continue
+		}
+		if m := boardTaskRegex.FindStringSubmatch(line); m != nil && !inFence {
+			total++
+			if m[2] == "x" || m[2] == "X" {
+				done++
+			}
+		}
+	}
+	return total, done
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"github.com/gdamore/tcell/v2"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	templatesDirName = ".templates"
+	cursorMarker     = "{{cursor}}"
+)
+
+type templateCursor struct {
+	line int
+	col  int
+}
+
+// Vim argument placing the cursor at the marker position
+func (c templateCursor) vimArg() string {
+	return fmt.Sprintf("+call cursor(%d, %d)", c.line, c.col)
+}
+
+func listTemplates(rootItemPath string) []string {
+	entries, err := os.ReadDir(filepath.Join(rootItemPath, templatesDirName))
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Offer the vault templates for a newly created note and fill it with the chosen
+// one. Returns the cursor position to open the editor at, or nil when no
+// template was applied.
+func handleTemplate(path string, rootItemPath string, screen tcell.Screen) (*templateCursor, error) {
+	templates := listTemplates(rootItemPath)
+	if len(templates) == 0 {
+		return nil, nil
+	}
+
+	prompt := "Template (" + strings.Join(templates, ", ") + ", empty for none): "
+	name, ok := getUserInput(prompt, "", screen)
+	if !ok || name == "" {
+		return nil, nil
+	}
+	templatePath := filepath.Join(rootItemPath, templatesDirName, name)
+	if !isFile(templatePath) {
+		return nil, userErr{fmt.Sprintf("Unknown template: %s", name)}
+	}
+
+	source, err := os.ReadFile(templatePath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading template %s: %v", templatePath, err)
+	}
+	body, variables := parseTemplateHeader(string(source))
+	values, ok := promptTemplateVariables(variables, screen)
+	if !ok {
+		return nil, nil
+	}
+	body = expandUserVariables(body, values)
+	content, err := expandTemplateVariables(body, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	content, cursor := extractCursor(content)
+	// Keep the title the note got when it was named, unless the template sets one
+	if title := frontmatterString(readFrontmatter(path), "title"); title != "" {
+		lines, _, _ := splitFrontmatter([]byte(content))
+		if frontmatterString(parseFrontmatter(lines), "title") == "" {
+			titled := string(setFrontmatterField([]byte(content), "title", title))
+			cursor.line += strings.Count(titled, "\n") - strings.Count(content, "\n")
+			content = titled
+		}
+	}
+
+	err = os.WriteFile(path, []byte(content), 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("error writing template to %s: %v", path, err)
+	}
+	return &cursor, nil
+}
+
+var (
+	dateVariableRegex     = regexp.MustCompile(`\{\{date(?::([^}]*))?\}\}`)
+	userVariableRegex     = regexp.MustCompile(`\{\{([A-Za-z_][A-Za-z0-9_-]*)\}\}`)
+	templateHeaderRegex   = regexp.MustCompile(`\A<!--\s*variables\s*\n((?s:.*?))-->[ \t]*\n?`)
+	builtinTemplateFields = []string{"date", "time", "cursor"}
+)
+
+// templateVariable is a value asked for when a template is applied, declared
+// in its header or just used in it
+type templateVariable struct {
+	name         string
+	defaultValue string
+}
+
+// Split the variables header off a template, e.g.
+//
+//	<!-- variables
+//	project: Untitled
+//	client:
+//	-->
+//
+// returning the body and the variables, declared ones first followed by the
+// ones only used in the body
+func parseTemplateHeader(source string) (string, []templateVariable) {
+	var variables []templateVariable
+	declared := make(map[string]bool)
+	if m := templateHeaderRegex.FindStringSubmatchIndex(source); m != nil {
+		for _, line := range strings.Split(source[m[2]:m[3]], "\n") {
+			name, value, _ := strings.Cut(line, ":")
+			name = strings.TrimSpace(name)
+			if name == "" || declared[name] {
+				continue
+			}
+			declared[name] = true
+			variables = append(variables, templateVariable{name, strings.TrimSpace(value)})
+		}
+		source = source[m[1]:]
+	}
+	for _, m := range userVariableRegex.FindAllStringSubmatch(source, -1) {
+		if name := m[1]; !declared[name] && !slices.Contains(builtinTemplateFields, name) {
+			declared[name] = true
+			variables = append(variables, templateVariable{name: name})
+		}
+	}
+	return source, variables
+}
+
+// Ask for the value of each variable, offering its default. Returns false
+// when a prompt is dismissed.
+func promptTemplateVariables(variables []templateVariable, screen tcell.Screen) (map[string]string, bool) {
+	values := make(map[string]string)
+	for _, v := range variables {
+		value, ok := getUserInput(v.name+": ", v.defaultValue, screen)
+		if !ok {
+			return nil, false
+		}
+		values[v.name] = value
+	}
+	return values, true
+}
+
+func expandUserVariables(content string, values map[string]string) string {
+	return userVariableRegex.ReplaceAllStringFunc(content, func(match string) string {
+		if value, ok := values[userVariableRegex.FindStringSubmatch(match)[1]]; ok {
+			return value
+		}
+		return match
+	})
+}
+
+// Substitute {{date}} with today's date, {{date:EXPR}} with the date the
+// expression resolves to, e.g. {{date:next mon}}, and {{time}} with the
+// current time
+func expandTemplateVariables(content string, now time.Time) (string, error) {
+	content = strings.ReplaceAll(content, "{{time}}", now.Format("15:04"))
+	var expandErr error
+	content = dateVariableRegex.ReplaceAllStringFunc(content, func(match string) string {
+		date, err := parseDateExpr(dateVariableRegex.FindStringSubmatch(match)[1], now)
+		if err != nil {
+			expandErr = err
+			return match
+		}
+		return date.Format(dateLayout)
+	})
+	return content, expandErr
+}
+
+// Remove the cursor marker from content, returning its 1-based line and column.
+// Without a marker the cursor is placed at the end of the content.
+func extractCursor(content string) (string, templateCursor) {
+	index := strings.Index(content, cursorMarker)
+	if index == -1 {
+		index = len(content)
+	} else {
+		content = content[:index] + content[index+len(cursorMarker):]
+	}
+	before := content[:index]
+	line := strings.Count(before, "\n") + 1
+	col := len(before) - strings.LastIndex(before, "\n")
+	return content, templateCursor{line: line, col: col}
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"fmt"
+	"github.com/gdamore/tcell/v2"
+	"golang.org/x/term"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultThemeName = "default"
+	// Picks the default or light theme by the terminal background
+	autoThemeName          = "auto"
+	backgroundQueryTimeout = 200 * time.Millisecond
+)
+
+// Reply to the OSC 11 background color query, e.g. "\x1b]11;rgb:ffff/ffff/ffff"
+var backgroundReplyRegex = regexp.MustCompile(`\]11;rgba?:([0-9a-fA-F]{1,4})/([0-9a-fA-F]{1,4})/([0-9a-fA-F]{1,4})`)
+
+// Theme holds the styles of the interface. Note content keeps the colors of
+// the markdown renderer and previewers.
+type Theme struct {
+	Selection         tcell.Style
+	SelectionInactive tcell.Style
+	Marked            tcell.Style
+	Separator         tcell.Style
+	SeparatorFocus    tcell.Style
+	FooterLabel       tcell.Style
+	Footer            tcell.Style
+	Heading           tcell.Style
+	Accent            tcell.Style
+	Match             tcell.Style
+	Error             tcell.Style
+	Notice            tcell.Style
+	Added             tcell.Style
+	Removed           tcell.Style
+	// Tree entries by kind, see fileKind, and by age
+	TreeKinds map[string]tcell.Style
+	TreeFresh tcell.Style
+	TreeStale tcell.Style
+}
+
+// Built-in themes as style specs per element, see parseStyle
+var themes = map[string]map[string]string{
+	"default": {
+		"selection":          "white on blue",
+		"selection_inactive": "white on gray",
+		"marked":             "yellow",
+		"separator":          "",
+		"separator_focus":    "blue",
+		"footer_label":       "reverse",
+		"footer":             "",
+		"heading":            "bold",
+		"accent":             "teal",
+		"match":              "bold yellow",
+		"error":              "red",
+		"notice":             "yellow",
+		"added":              "green",
+		"removed":            "red",
+		"tree_directory":     "bold blue",
+		"tree_markdown":      "",
+		"tree_image":         "purple",
+		"tree_code":          "green",
+		"tree_encrypted":     "red",
+		"tree_file":          "gray",
+		"tree_fresh":         "bold white",
+		"tree_stale":         "gray",
+	},
+	"light": {
+		"selection":          "white on navy",
+		"selection_inactive": "black on silver",
+		"marked":             "olive",
+		"separator":          "gray",
+		"separator_focus":    "navy",
+		"footer_label":       "white on navy",
+		"footer":             "",
+		"heading":            "bold navy",
+		"accent":             "teal",
+		"match":              "bold maroon",
+		"error":              "maroon",
+		"notice":             "olive",
+		"added":              "green",
+		"removed":            "maroon",
+		"tree_directory":     "bold navy",
+		"tree_markdown":      "",
+		"tree_image":         "purple",
+		"tree_code":          "green",
+		"tree_encrypted":     "maroon",
+		"tree_file":          "gray",
+		"tree_fresh":         "bold black",
+		"tree_stale":         "gray",
+	},
+	"high-contrast": {
+		"selection":          "black on yellow",
+		"selection_inactive": "black on white",
+		"marked":             "bold aqua",
+		"separator":          "white",
+		"separator_focus":    "yellow",
+		"footer_label":       "black on white",
+		"footer":             "white",
+		"heading":            "bold white",
+		"accent":             "aqua",
+		"match":              "black on aqua",
+		"error":              "bold red",
+		"notice":             "bold yellow",
+		"added":              "lime",
+		"removed":            "bold red",
+		"tree_directory":     "bold aqua",
+		"tree_markdown":      "white",
+		"tree_image":         "fuchsia",
+		"tree_code":          "lime",
+		"tree_encrypted":     "bold red",
+		"tree_file":          "silver",
+		"tree_fresh":         "bold yellow",
+		"tree_stale":         "gray",
+	},
+	"monochrome": {
+		"selection":          "reverse",
+		"selection_inactive": "underline",
+		"marked":             "bold",
+		"separator":          "",
+		"separator_focus":    "bold",
+		"footer_label":       "reverse",
+		"footer":             "",
+		"heading":            "bold",
+		"accent":             "underline",
+		"match":              "reverse",
+		"error":              "bold",
+		"notice":             "bold",
+		"added":              "bold",
+		"removed":            "dim",
+		"tree_directory":     "bold",
+		"tree_markdown":      "",
+		"tree_image":         "italic",
+		"tree_code":          "",
+		"tree_encrypted":     "underline",
+		"tree_file":          "dim",
+		"tree_fresh":         "bold",
+		"tree_stale":         "dim",
+	},
+}
+
+// Set from the config at startup, like the encryption settings
+var theme = mustTheme(defaultThemeName, nil)
+
+func themeNames() []string {
+	names := make([]string, 0, len(themes))
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Parse a style written as color names or #rrggbb values and attributes,
+// e.g. "white on blue", "bold yellow" or "reverse". An empty spec is the
+// terminal's default style.
+func parseStyle(spec string) (tcell.Style, error) {
+	style := tcell.StyleDefault
+	fields := strings.Fields(strings.ToLower(spec))
+	for i := 0; i < len(fields); i++ {
+		switch field := fields[i]; field {
+		case "bold":
+			style = style.Bold(true)
+		case "dim":
+			style = style.Dim(true)
+		case "italic":
+			style = style.Italic(true)
+		case "underline":
+			style = style.Underline(true)
+		case "reverse":
+			style = style.Reverse(true)
+		case "on":
+			if i+1 == len(fields) {
+				return style, fmt.Errorf("missing background color in %q", spec)
+			}
+			i++
+			color, err := parseColor(fields[i])
+			if err != nil {
+				return style, err
+			}
+			style = style.Background(color)
+		default:
+			color, err := parseColor(field)
+			if err != nil {
+				return style, err
+			}
+			style = style.Foreground(color)
+		}
+	}
+	return style, nil
+}
+
+func parseColor(name string) (tcell.Color, error) {
+	if name == "default" {
+		return tcell.ColorDefault, nil
+	}
+	if color := tcell.GetColor(name); color != tcell.ColorDefault {
+		return color, nil
+	}
+	return tcell.ColorDefault, fmt.Errorf("unknown color %q", name)
+}
+
+// Build a built-in theme with the styles in colors overriding its elements
+func buildTheme(name string, colors map[string]string) (Theme, error) {
+	specs, ok := themes[name]
+	if !ok {
+		return Theme{}, fmt.Errorf("error: theme must be %s or one of %s", autoThemeName, strings.Join(themeNames(), ", "))
+	}
+	var t Theme
+	fields := map[string]*tcell.Style{
+		"selection":          &t.Selection,
+		"selection_inactive": &t.SelectionInactive,
+		"marked":             &t.Marked,
+		"separator":          &t.Separator,
+		"separator_focus":    &t.SeparatorFocus,
+		"footer_label":       &t.FooterLabel,
+		"footer":             &t.Footer,
+		"heading":            &t.Heading,
+		"accent":             &t.Accent,
+		"match":              &t.Match,
+		"error":              &t.Error,
+		"notice":             &t.Notice,
+		"added":              &t.Added,
+		"removed":            &t.Removed,
+		"tree_fresh":         &t.TreeFresh,
+		"tree_stale":         &t.TreeStale,
+	}
+	kinds := make(map[string]*tcell.Style)
+	for _, kind := range iconKinds {
+		kinds[kind] = new(tcell.Style)
+		fields["tree_"+kind] = kinds[kind]
+	}
+	for element := range colors {
+		if _, ok := fields[element]; !ok {
+			return Theme{}, fmt.Errorf("error: unknown element %q in colors", element)
+		}
+	}
+	for element, field := range fields {
+		spec := specs[element]
+		if override, ok := colors[element]; ok {
+			spec = override
+		}
+		style, err := parseStyle(spec)
+		if err != nil {
+			return Theme{}, fmt.Errorf("error in %s color: %v", element, err)
+		}
+		*field = style
+	}
+	t.TreeKinds = make(map[string]tcell.Style)
+	for kind, style := range kinds {
+		t.TreeKinds[kind] = *style
+	}
+	return t, nil
+}
+
+func mustTheme(name string, colors map[string]string) Theme {
+	t, err := buildTheme(name, colors)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// Apply the colors and attributes set in top over base, e.g. to highlight a
+// match in a selected row
+func overlayStyle(base tcell.Style, top tcell.Style) tcell.Style {
+	fg, bg, attrs := top.Decompose()
+	if fg != tcell.ColorDefault {
+		base = base.Foreground(fg)
+	}
+	if bg != tcell.ColorDefault {
+		base = base.Background(bg)
+	}
+	_, _, baseAttrs := base.Decompose()
+	return base.Attributes(baseAttrs | attrs)
+}
+
+// The theme to use for the theme setting, resolving auto by the terminal
+// background
+func resolveThemeName(name string) string {
+	if name != autoThemeName {
+		return name
+	}
+	if light, ok := queryBackground(); ok {
+		return themeForBackground(light)
+	}
+	if light, ok := colorFgBgBackground(os.Getenv("COLORFGBG")); ok {
+		return themeForBackground(light)
+	}
+	return defaultThemeName
+}
+
+func themeForBackground(light bool) string {
+	if light {
+		return "light"
+	}
+	return defaultThemeName
+}
+
+// Ask the terminal for its background color with OSC 11, before the screen
+// is set up. The query is followed by a device attributes request which all
+// terminals answer, so ones that don't know OSC 11 don't hold up startup
+// until the timeout.
+func queryBackground() (bool, bool) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return false, false
+	}
+	defer tty.Close()
+	if !term.IsTerminal(int(tty.Fd())) || tty.SetReadDeadline(time.Now().Add(backgroundQueryTimeout)) != nil {
+		return false, false
+	}
+	state, err := term.MakeRaw(int(tty.Fd()))
+	if err != nil {
+		return false, false
+	}
+	defer term.Restore(int(tty.Fd()), state)
+	if _, err := tty.WriteString("\x1b]11;?\x1b\\\x1b[c"); err != nil {
+		return false, false
+	}
+
+	var reply []byte
+	buf := make([]byte, 64)
+	for !strings.Contains(string(reply), "\x1b[?") || !strings.HasSuffix(string(reply), "c") {
+		n, err := tty.Read(buf)
+		if err != nil {
+			break
+		}
+		reply = append(reply, buf[:n]...)
+	}
+	return parseBackgroundReply(string(reply))
+}
+
+// Whether the color in an OSC 11 reply is light, by its perceived brightness
+func parseBackgroundReply(reply string) (bool, bool) {
+	match := backgroundReplyRegex.FindStringSubmatch(reply)
+	if match == nil {
+		return false, false
+	}
+	var rgb [3]float64
+	for i, hex := range match[1:] {
+		value, _ := strconv.ParseUint(hex, 16, 16)
+		rgb[i] = float64(value) / float64(uint64(1)<<(4*len(hex))-1)
+	}
+	return 0.299*rgb[0]+0.587*rgb[1]+0.114*rgb[2] > 0.5, true
+}
+
+// Whether the background in COLORFGBG, e.g. "15;0" as set by rxvt and
+// Konsole, is light. The background is the last field, an ANSI color index.
+func colorFgBgBackground(value string) (bool, bool) {
+	fields := strings.Split(value, ";")
+	bg, err := strconv.Atoi(fields[len(fields)-1])
+	if err != nil || bg < 0 || bg > 15 {
+		return false, false
+	}
+	return bg == 7 || bg > 8, true
+}
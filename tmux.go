@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"github.com/gdamore/tcell/v2"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Where the editor opens inside tmux, set by tmux_editor
+const (
+	tmuxEditorOff    = "off"
+	tmuxEditorSplit  = "split"
+	tmuxEditorWindow = "window"
+)
+
+const (
+	// How often a note open in a tmux pane is checked for saves
+	tmuxWatchInterval = 500 * time.Millisecond
+	// Edits waiting to be collected before the watchers wait for the run loop
+	maxTmuxEdits = 16
+)
+
+// Handed back through tmuxEdits while a note is edited in a tmux pane: when
+// it was saved, so the preview is redrawn, and once the editor closed
+type tmuxEditEvent struct {
+	path   string
+	stamp  FileStamp
+	closed bool
+}
+
+var tmuxChannels int
+
+// Whether to edit in a tmux pane, only when tmux_editor is on and notes
+// runs inside tmux
+func (app *App) useTmuxEditor() bool {
+	return app.config.TmuxEditor != tmuxEditorOff && os.Getenv("TMUX") != ""
+}
+
+// Open the editor command in a new tmux split or window next to the TUI, which
+// keeps running and redraws the preview whenever the note is saved
+func (app *App) openTmuxEditor(cmd *exec.Cmd, path string, stamp FileStamp) error {
+	if _, err := exec.LookPath(cmd.Args[0]); err != nil {
+		return userErr{fmt.Sprintf("Editor not found: %s", cmd.Args[0])}
+	}
+	tmuxChannels++
+	channel := fmt.Sprintf("notes-edit-%d-%d", os.Getpid(), tmuxChannels)
+	args := []string{"split-window", "-h"}
+	if app.config.TmuxEditor == tmuxEditorWindow {
+		args = []string{"new-window"}
+	}
+	// The editor runs through sh so tmux is told once it exits
+	args = append(args, "-c", app.rootItem.Path, "sh", "-c", `"$@"; tmux wait-for -S `+channel, "sh")
+	args = append(args, cmd.Args...)
+	if output, err := exec.Command("tmux", args...).CombinedOutput(); err != nil {
+		message := strings.TrimSpace(string(output))
+		if message == "" {
+			message = err.Error()
+		}
+		return userErr{"tmux: " + strings.Split(message, "\n")[0]}
+	}
+
+	screen, edits := app.screen, app.tmuxEdits
+	done := make(chan struct{})
+	go func() {
+		_ = exec.Command("tmux", "wait-for", channel).Run()
+		close(done)
+		edits <- tmuxEditEvent{path, stamp, true}
+		_ = screen.PostEvent(tcell.NewEventInterrupt(wakeEvent{}))
+	}()
+	go func() {
+		ticker := time.NewTicker(tmuxWatchInterval)
+		defer ticker.Stop()
+		last := stampFile(path)
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if last.changed() {
+					last = stampFile(path)
+					edits <- tmuxEditEvent{path, stamp, false}
+					_ = screen.PostEvent(tcell.NewEventInterrupt(wakeEvent{}))
+				}
+			}
+		}
+	}()
+	app.toast = "Editing " + app.relativePath(path) + " in tmux"
+	return nil
+}
+
+// Pick up the saves and ends of edits in tmux panes
+func (app *App) collectTmuxEdits() {
+	for {
+		select {
+		case event := <-app.tmuxEdits:
+			app.collectTmuxEdit(event)
+		default:
+			return
+		}
+	}
+}
+
+func (app *App) collectTmuxEdit(event tmuxEditEvent) {
+	if !event.closed {
+		app.rebuildTree()
+		return
+	}
+	if event.stamp.changed() {
+		if err := app.formatNote(event.path); err != nil {
+			handleError(err, app.screen)
+		}
+		app.fireHook(hookNoteEdited, event.path, "")
+	}
+	app.rebuild()
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	markdown "github.com/MichaelMure/go-term-markdown"
+	"strings"
+)
+
+// Notes with at least this many headings get a table of contents at the top
+// of the preview, none when 0. Set from the config at startup.
+var tocMinHeadings int
+
+// The rendered table of contents of a note with enough headings, nil for
+// other notes. The note itself isn't changed.
+func renderTOC(headings []Heading, width int) []byte {
+	if tocMinHeadings == 0 || len(headings) < tocMinHeadings {
+		return nil
+	}
+	top := headings[0].Level
+	for _, heading := range headings {
+		top = min(top, heading.Level)
+	}
+	var b strings.Builder
+	b.WriteString("**Contents**\n\n")
+	for _, heading := range headings {
+		b.WriteString(strings.Repeat("  ", heading.Level-top) + "- " + heading.Text + "\n")
+	}
+	b.WriteString("\n---\n")
+	return markdown.Render(b.String(), width, 0)
+}
+
+// Rendered line of each heading, matched in order from line start on since
+// the renderer prefixes headings with section numbers
+func headingLines(rendered []string, headings []Heading, start int) []int {
+	lines := make([]int, len(headings))
+	line := start
+	for i, heading := range headings {
+		for j := line; j < len(rendered); j++ {
+			if strings.HasSuffix(strings.TrimSpace(plainText(rendered[j])), heading.Text) {
+				line = j
+				break
+			}
+		}
+		lines[i] = line
+		line++
+	}
+	return lines
+}
+
+// Scroll the preview to the heading of the table of contents entry shown on
+// row of the preview, doing nothing for rows outside the table
+func (app *App) followTOCEntry(row int) {
+	path := app.selectedItem().Path
+	if !isFile(path) {
+		return
+	}
+	source, err := readNote(path)
+	if err != nil {
+		return
+	}
+	width := app.layout().Preview.Width
+	headings := parseHeadings(source)
+	toc := strings.Count(string(renderTOC(headings, width)), "\n")
+	line := app.previewScroll + row
+	if line >= toc {
+		return
+	}
+	content, err := renderNote(path, width)
+	if err != nil {
+		return
+	}
+	rendered := strings.Split(string(content), "\n")
+	entries := headingLines(rendered[:toc], headings, 0)
+	for i, entry := range entries {
+		if entry == line {
+			app.previewScroll = headingLines(rendered, headings, toc)[i]
+			return
+		}
+	}
+}
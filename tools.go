@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// Optional external programs and the features that need them
+var optionalTools = []struct {
+	name    string
+	feature string
+}{
+	{"vim", "editing notes in vim"},
+	{"rg", "ripgrep search backend"},
+	{"ag", "ag search backend"},
+	{"pandoc", "document conversion"},
+	{"git", "version control"},
+	{"age", "age encrypted notes"},
+	{"gpg", "GPG encrypted notes"},
+	{"xdg-open", "opening files in other applications"},
+	{"lp", "printing notes"},
+}
+
+// Look up the optional tools on PATH, mapping each found name to its path
+func detectTools() map[string]string {
+	tools := make(map[string]string)
+	for _, tool := range optionalTools {
+		if path, err := exec.LookPath(tool.name); err == nil {
+			tools[tool.name] = path
+		} else {
+			logger.Printf("%s not found, %s unavailable", tool.name, tool.feature)
+		}
+	}
+	return tools
+}
+
+func (app *App) hasTool(name string) bool {
+	_, ok := app.tools[name]
+	return ok
+}
+
+// Describe the missing tools with the features turned off, empty when all of
+// them are installed
+func missingToolsNotice(tools map[string]string) string {
+	var missing []string
+	for _, tool := range optionalTools {
+		if _, ok := tools[tool.name]; !ok {
+			missing = append(missing, fmt.Sprintf("%s (%s)", tool.name, tool.feature))
+		}
+	}
+	if len(missing) == 0 {
+		return ""
+	}
+	return "Not found: " + strings.Join(missing, ", ")
+}
+
+func writeToolDiagnostics(w io.Writer, tools map[string]string) {
+	for _, tool := range optionalTools {
+		if path, ok := tools[tool.name]; ok {
+			fmt.Fprintf(w, "%s: %s\n", tool.name, path)
+		} else {
+			fmt.Fprintf(w, "%s: not found (%s unavailable)\n", tool.name, tool.feature)
+		}
+	}
+}
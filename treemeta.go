@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"github.com/gdamore/tcell/v2"
+	"os"
+	"time"
+)
+
+// Width of the size and age column shown right-aligned in the tree
+const treeMetadataWidth = 10
+
+// What the count after directories in the tree includes
+const (
+	folderCountsNone  = "none"
+	folderCountsNotes = "notes"
+	folderCountsAll   = "all"
+)
+
+// Set from the config at startup, like the icons
+var folderCounts = folderCountsNotes
+
+// What tree entries are colored by
+const (
+	treeColorsNone = "none"
+	treeColorsAge  = "age"
+	treeColorsType = "type"
+)
+
+// Notes modified within freshAge are shown as fresh, ones unchanged for
+// staleAge as stale
+const (
+	freshAge = 24 * time.Hour
+	staleAge = 90 * 24 * time.Hour
+)
+
+// The theme style of a tree entry by its kind or the age of a note
+func treeEntryStyle(item TreeItem, colors string, now time.Time) tcell.Style {
+	switch colors {
+	case treeColorsType:
+		return theme.TreeKinds[fileKind(item)]
+	case treeColorsAge:
+		info, err := os.Stat(item.Path)
+		if err != nil || info.IsDir() {
+			break
+		}
+		if age := now.Sub(info.ModTime()); age < freshAge {
+			return theme.TreeFresh
+		} else if age > staleAge {
+			return theme.TreeStale
+		}
+	}
+	return tcell.StyleDefault
+}
+
+// The count of notes below a directory, with its subdirectories when all
+// are counted, e.g. " (42)" or " (42, 3 dirs)"
+func folderCount(item TreeItem) string {
+	switch {
+	case folderCounts == folderCountsNone:
+		return ""
+	case folderCounts == folderCountsAll && item.Subdirs == 1:
+		return fmt.Sprintf(" (%d, 1 dir)", item.Notes)
+	case folderCounts == folderCountsAll && item.Subdirs > 1:
+		return fmt.Sprintf(" (%d, %d dirs)", item.Notes, item.Subdirs)
+	}
+	return fmt.Sprintf(" (%d)", item.Notes)
+}
+
+// Size of a file in at most four characters, e.g. 812B, 4.1K or 13M
+func formatSize(size int64) string {
+	const units = "KMGT"
+	if size < 1000 {
+		return fmt.Sprintf("%dB", size)
+	}
+	value := float64(size)
+	for _, unit := range units {
+		value /= 1024
+		if value < 9.95 {
+			return fmt.Sprintf("%.1f%c", value, unit)
+		}
+		if value < 999.5 || unit == 'T' {
+			return fmt.Sprintf("%.0f%c", value, unit)
+		}
+	}
+	return ""
+}
+
+// Time since a file was modified in its largest unit, e.g. 5m, 3h, 2d or 4mo
+func formatAge(modTime time.Time, now time.Time) string {
+	age := now.Sub(modTime)
+	switch {
+	case age < time.Minute:
+		return "now"
+	case age < time.Hour:
+		return fmt.Sprintf("%dm", int(age.Minutes()))
+	case age < 24*time.Hour:
+		return fmt.Sprintf("%dh", int(age.Hours()))
+	case age < 14*24*time.Hour:
+		return fmt.Sprintf("%dd", int(age.Hours()/24))
+	case age < 60*24*time.Hour:
+		return fmt.Sprintf("%dw", int(age.Hours()/24/7))
+	case age < 365*24*time.Hour:
+		return fmt.Sprintf("%dmo", int(age.Hours()/24/30))
+	}
+	return fmt.Sprintf("%dy", int(age.Hours()/24/365))
+}
+
+// The size and age column of a tree entry, with only the age for directories
+func treeMetadata(path string, now time.Time) string {
+	info, err := os.Stat(path)
+	if err != nil {
+		return ""
+	}
+	size := ""
+	if !info.IsDir() {
+		size = formatSize(info.Size())
+	}
+	return fmt.Sprintf("%5s %4s", size, formatAge(info.ModTime(), now))
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"sort"
+	"strings"
+)
+
+// TreeSort is how entries of a directory are ordered in the tree, by name as
+// the file system lists them unless set
+type TreeSort struct {
+	DirsFirst  bool `json:"dirs_first"`
+	Natural    bool `json:"natural"`
+	IgnoreCase bool `json:"ignore_case"`
+}
+
+func sortEntries(entries []os.DirEntry, order TreeSort) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		if order.DirsFirst && entries[i].IsDir() != entries[j].IsDir() {
+			return entries[i].IsDir()
+		}
+		return lessName(entries[i].Name(), entries[j].Name(), order)
+	})
+}
+
+func lessName(a string, b string, order TreeSort) bool {
+	if order.IgnoreCase {
+		if la, lb := strings.ToLower(a), strings.ToLower(b); la != lb {
+			a, b = la, lb
+		}
+	}
+	if order.Natural {
+		return naturalLess(a, b)
+	}
+	return a < b
+}
+
+// Compare names with runs of digits compared by their value, so note2 comes
+// before note10
+func naturalLess(a string, b string) bool {
+	ra, rb := []rune(a), []rune(b)
+	i, j := 0, 0
+	for i < len(ra) && j < len(rb) {
+		if isDigit(ra[i]) && isDigit(rb[j]) {
+			si, sj := i, j
+			for i < len(ra) && isDigit(ra[i]) {
+				i++
+			}
+			for j < len(rb) && isDigit(rb[j]) {
+				j++
+			}
+			na := strings.TrimLeft(string(ra[si:i]), "0")
+			nb := strings.TrimLeft(string(rb[sj:j]), "0")
+			if len(na) != len(nb) {
+				return len(na) < len(nb)
+			}
+			if na != nb {
+				return na < nb
+			}
+			// Equal values, fewer leading zeros first
+			if i-si != j-sj {
+				return i-si < j-sj
+			}
+			continue
+		}
+		if ra[i] != rb[j] {
+			return ra[i] < rb[j]
+		}
+		i++
+		j++
+	}
+	return len(ra)-i < len(rb)-j
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
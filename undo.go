@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const maxUndo = 100
+
+// FileMove is a rename done by an operation, undone by renaming back
+type FileMove struct {
+	From string
+	To   string
+}
+
+// UndoStep is a delete, rename or move, recorded as the renames it was made of
+type UndoStep struct {
+	Name  string
+	Moves []FileMove
+}
+
+// Rename from to to, moving an existing file at to into the backups first so
+// overwriting it can be undone too
+func renameUndoable(from string, to string, rootItemPath string) ([]FileMove, error) {
+	var moves []FileMove
+	// On case-insensitive file systems to may only differ from from in case
+	if toInfo, err := os.Lstat(to); err == nil && !sameFile(from, toInfo) {
+		move, err := moveToBackups(rootItemPath, "overwrite", to)
+		if err != nil {
+			return nil, err
+		}
+		moves = append(moves, move)
+	}
+	if err := os.Rename(from, to); err != nil {
+		return moves, fmt.Errorf("error renaming %s to %s: %v", from, to, err)
+	}
+	return append(moves, FileMove{from, to}), nil
+}
+
+func sameFile(path string, info os.FileInfo) bool {
+	pathInfo, err := os.Lstat(path)
+	return err == nil && os.SameFile(pathInfo, info)
+}
+
+// Apply the renames of a step, backwards to undo it. Nothing is renamed when
+// files changed since in a way that one of them can't be done.
+func applyMoves(moves []FileMove, undo bool) error {
+	steps := make([]FileMove, len(moves))
+	for i, move := range moves {
+		if undo {
+			steps[len(moves)-1-i] = FileMove{move.To, move.From}
+		} else {
+			steps[i] = move
+		}
+	}
+	for i, step := range steps {
+		// An earlier rename may free the destination of a later one
+		freed := false
+		for _, earlier := range steps[:i] {
+			if earlier.From == step.To {
+				freed = true
+			}
+		}
+		if _, err := os.Lstat(step.From); err != nil {
+			return userErr{fmt.Sprintf("%s no longer exists", step.From)}
+		}
+		if _, err := os.Lstat(step.To); err == nil && !freed {
+			return userErr{fmt.Sprintf("%s already exists", step.To)}
+		}
+	}
+	for _, step := range steps {
+		if err := os.MkdirAll(filepath.Dir(step.To), os.ModePerm); err != nil {
+			return fmt.Errorf("error creating directory %s: %v", filepath.Dir(step.To), err)
+		}
+		if err := os.Rename(step.From, step.To); err != nil {
+			return fmt.Errorf("error renaming %s to %s: %v", step.From, step.To, err)
+		}
+	}
+	return nil
+}
+
+// Remember a step to undo, forgetting what was undone before it
+func (app *App) pushUndo(step UndoStep) {
+	if len(step.Moves) == 0 {
+		return
+	}
+	app.updateBackupSets(step.Moves, step.Name)
+	app.undoStack = append(app.undoStack, step)
+	if len(app.undoStack) > maxUndo {
+		app.undoStack = app.undoStack[len(app.undoStack)-maxUndo:]
+	}
+	app.redoStack = nil
+}
+
+func (app *App) undo() error {
+	if len(app.undoStack) == 0 {
+		return userErr{"Nothing to undo"}
+	}
+	step := app.undoStack[len(app.undoStack)-1]
+	app.recordOperation("undo", step.Name+" "+step.Moves[len(step.Moves)-1].To)
+	if err := applyMoves(step.Moves, true); err != nil {
+		return err
+	}
+	app.updateBackupSets(step.Moves, step.Name)
+	app.undoStack = app.undoStack[:len(app.undoStack)-1]
+	app.redoStack = append(app.redoStack, step)
+	app.rebuild()
+	app.selectPath(step.Moves[len(step.Moves)-1].From)
+	return nil
+}
+
+func (app *App) redo() error {
+	if len(app.redoStack) == 0 {
+		return userErr{"Nothing to redo"}
+	}
+	step := app.redoStack[len(app.redoStack)-1]
+	app.recordOperation("redo", step.Name+" "+step.Moves[len(step.Moves)-1].From)
+	if err := applyMoves(step.Moves, false); err != nil {
+		return err
+	}
+	app.updateBackupSets(step.Moves, step.Name)
+	app.redoStack = app.redoStack[:len(app.redoStack)-1]
+	app.undoStack = append(app.undoStack, step)
+	app.rebuild()
+	app.selectPath(step.Moves[len(step.Moves)-1].To)
+	return nil
+}
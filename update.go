@@ -0,0 +1,279 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/gdamore/tcell/v2"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const latestReleaseURL = "https://api.github.com/repos/davidkastanek/notes/releases/latest"
+
+type Release struct {
+	TagName string         `json:"tag_name"`
+	Body    string         `json:"body"`
+	Assets  []ReleaseAsset `json:"assets"`
+}
+
+type ReleaseAsset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+type updateEvent struct {
+	release Release
+}
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+func fetchLatestRelease() (Release, error) {
+	var release Release
+	resp, err := httpClient.Get(latestReleaseURL)
+	if err != nil {
+		return release, fmt.Errorf("error querying releases: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return release, fmt.Errorf("error querying releases: %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return release, fmt.Errorf("error decoding release: %v", err)
+	}
+	return release, nil
+}
+
+// Compare dotted version numbers, ignoring a leading v and any pre-release
+// suffix. Returns -1, 0 or 1.
+func compareVersions(a string, b string) int {
+	parse := func(v string) []int {
+		v = strings.TrimPrefix(v, "v")
+		v, _, _ = strings.Cut(v, "-")
+		var parts []int
+		for _, p := range strings.Split(v, ".") {
+			n, _ := strconv.Atoi(p)
+			parts = append(parts, n)
+		}
+		return parts
+	}
+	pa, pb := parse(a), parse(b)
+	for i := 0; i < max(len(pa), len(pb)); i++ {
+		var x, y int
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if i < len(pb) {
+			y = pb[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func isNewerRelease(release Release) bool {
+	return version == "dev" || compareVersions(release.TagName, version) > 0
+}
+
+// Extensions of the assets describing builds rather than being one
+var releaseMetadataExts = []string{".sha256", ".sha512", ".sig", ".asc", ".pem", ".sbom", ".json", ".txt"}
+
+// Pick the release asset built for this platform, named like
+// notes_1.2.0_linux_amd64.tar.gz, so arm doesn't pick arm64
+func platformAsset(release Release) (ReleaseAsset, bool) {
+	platform := "_" + runtime.GOOS + "_" + runtime.GOARCH
+	for _, asset := range release.Assets {
+		name := strings.ToLower(asset.Name)
+		if slices.ContainsFunc(releaseMetadataExts, func(ext string) bool { return strings.HasSuffix(name, ext) }) {
+			continue
+		}
+		if strings.HasSuffix(name, platform) || strings.Contains(name, platform+".") {
+			return asset, true
+		}
+	}
+	return ReleaseAsset{}, false
+}
+
+// Check for a newer release in the background and post it to the screen
+func checkForUpdate(screen tcell.Screen) {
+	go func() {
+		release, err := fetchLatestRelease()
+		if err != nil {
+			logger.Printf("update check failed: %v", err)
+			return
+		}
+		if version != "dev" && isNewerRelease(release) {
+			_ = screen.PostEvent(tcell.NewEventInterrupt(updateEvent{release}))
+		}
+	}()
+}
+
+func runSelfUpdateSubcommand(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("usage: notes self-update")
+	}
+	release, err := fetchLatestRelease()
+	if err != nil {
+		return err
+	}
+	if !isNewerRelease(release) {
+		fmt.Printf("notes %s is up to date\n", version)
+		return nil
+	}
+	asset, ok := platformAsset(release)
+	if !ok {
+		return fmt.Errorf("error: release %s has no build for %s/%s", release.TagName, runtime.GOOS, runtime.GOARCH)
+	}
+
+	checksum, err := assetChecksum(release, asset)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("notes %s is available (installed %s)\n\n%s\n\n", release.TagName, version, strings.TrimSpace(release.Body))
+	fmt.Print("Install it? (y/N): ")
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	if strings.ToLower(strings.TrimSpace(answer)) != "y" {
+		return nil
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("error locating executable: %v", err)
+	}
+	executable, err = filepath.EvalSymlinks(executable)
+	if err != nil {
+		return fmt.Errorf("error resolving executable: %v", err)
+	}
+	if err := replaceExecutable(executable, asset, checksum); err != nil {
+		return err
+	}
+	fmt.Printf("Updated %s to %s\n", executable, release.TagName)
+	return nil
+}
+
+func downloadAsset(asset ReleaseAsset) ([]byte, error) {
+	resp, err := httpClient.Get(asset.URL)
+	if err != nil {
+		return nil, fmt.Errorf("error downloading %s: %v", asset.Name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error downloading %s: %s", asset.Name, resp.Status)
+	}
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error downloading %s: %v", asset.Name, err)
+	}
+	return content, nil
+}
+
+// The SHA-256 of the asset listed in the checksums file of the release, in
+// the sha256sum format of "<hex>  <name>" lines. Updating without one isn't
+// safe, so a missing file or entry is an error.
+func assetChecksum(release Release, asset ReleaseAsset) (string, error) {
+	for _, candidate := range release.Assets {
+		name := strings.ToLower(candidate.Name)
+		if !strings.Contains(name, "checksums") && !strings.Contains(name, "sha256sums") {
+			continue
+		}
+		checksums, err := downloadAsset(candidate)
+		if err != nil {
+			return "", err
+		}
+		for _, line := range strings.Split(string(checksums), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == asset.Name {
+				return strings.ToLower(fields[0]), nil
+			}
+		}
+		return "", fmt.Errorf("error: %s has no checksum for %s", candidate.Name, asset.Name)
+	}
+	return "", fmt.Errorf("error: release %s has no checksums, not updating", release.TagName)
+}
+
+// Download the asset next to the executable and rename it over the original
+// so a failed download never leaves a broken binary behind. The asset must
+// match the checksum from the release.
+func replaceExecutable(executable string, asset ReleaseAsset, checksum string) error {
+	content, err := downloadAsset(asset)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(content)
+	if hex.EncodeToString(sum[:]) != checksum {
+		return fmt.Errorf("error: checksum of %s doesn't match the release, not updating", asset.Name)
+	}
+
+	var binary io.Reader = bytes.NewReader(content)
+	if strings.HasSuffix(asset.Name, ".tar.gz") || strings.HasSuffix(asset.Name, ".tgz") {
+		binary, err = extractBinary(binary)
+		if err != nil {
+			return err
+		}
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(executable), ".notes-update-*")
+	if err != nil {
+		return fmt.Errorf("error creating temporary file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, binary); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("error writing %s: %v", tmp.Name(), err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error closing %s: %v", tmp.Name(), err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o755); err != nil {
+		return fmt.Errorf("error making %s executable: %v", tmp.Name(), err)
+	}
+	if err := os.Rename(tmp.Name(), executable); err != nil {
+		return fmt.Errorf("error replacing %s: %v", executable, err)
+	}
+	return nil
+}
+
+// Return the notes executable in a gzipped tar archive, wherever in the
+// archive it is
+func extractBinary(r io.Reader) (io.Reader, error) {
+	name := "notes"
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("error reading archive: %v", err)
+	}
+	archive := tar.NewReader(gz)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("error: archive contains no %s executable", name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading archive: %v", err)
+		}
+		if header.Typeflag == tar.TypeReg && path.Base(header.Name) == name {
+			return archive, nil
+		}
+	}
+}
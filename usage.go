@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"github.com/gdamore/tcell/v2"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Size and number of files below a directory
+type diskUsage struct {
+	size  int64
+	files int
+}
+
+func (u *diskUsage) add(size int64) {
+	u.size += size
+	u.files++
+}
+
+// Attachments are the files in assets directories and images and PDFs
+// anywhere else
+func isAttachment(rel string) bool {
+	ext := strings.ToLower(filepath.Ext(rel))
+	if slices.Contains(imageExtensions, ext) || ext == ".pdf" {
+		return true
+	}
+	return slices.Contains(strings.Split(filepath.ToSlash(filepath.Dir(rel)), "/"), assetsDirName)
+}
+
+// Sizes of the vault, its attachments and each directory by vault relative
+// path. Hidden directories such as .backups are counted but not broken down.
+func vaultUsage(root string) (diskUsage, diskUsage, map[string]*diskUsage) {
+	var total, attachments diskUsage
+	dirs := map[string]*diskUsage{".": {}}
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if rel != "." {
+				dirs[rel] = &diskUsage{}
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			return nil
+		}
+		total.add(info.Size())
+		if isAttachment(rel) {
+			attachments.add(info.Size())
+		}
+		for dir := filepath.Dir(rel); ; dir = filepath.Dir(dir) {
+			if usage, ok := dirs[dir]; ok {
+				usage.add(info.Size())
+			}
+			if dir == "." {
+				break
+			}
+		}
+		return nil
+	})
+	for rel := range dirs {
+		if hiddenBelow(rel) {
+			delete(dirs, rel)
+		}
+	}
+	return total, attachments, dirs
+}
+
+// Whether a directory is inside a hidden one, which is reported as a whole
+func hiddenBelow(rel string) bool {
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	for _, part := range parts[:len(parts)-1] {
+		if strings.HasPrefix(part, ".") && part != "." {
+			return true
+		}
+	}
+	return false
+}
+
+// Warnings for the limits of the config the vault is over
+func sizeWarnings(total diskUsage, attachments diskUsage, config Config) []string {
+	var warnings []string
+	const mb = 1024 * 1024
+	if limit := int64(config.VaultWarningMB) * mb; limit > 0 && total.size > limit {
+		warnings = append(warnings, fmt.Sprintf("The vault is %s, over its %dM limit", formatSize(total.size), config.VaultWarningMB))
+	}
+	if limit := int64(config.AttachmentsWarningMB) * mb; limit > 0 && attachments.size > limit {
+		warnings = append(warnings, fmt.Sprintf("Attachments are %s, over their %dM limit", formatSize(attachments.size), config.AttachmentsWarningMB))
+	}
+	return warnings
+}
+
+// The sizes and file counts of the directories of the vault like du, in tree
+// order and indented by depth, followed by the totals and warnings
+func usageReport(root string, config Config) string {
+	total, attachments, dirs := vaultUsage(root)
+	paths := make([]string, 0, len(dirs))
+	for rel := range dirs {
+		paths = append(paths, rel)
+	}
+	// Sorted by path components, so directories come right before their
+	// subdirectories
+	slices.SortFunc(paths, func(a, b string) int {
+		return strings.Compare(strings.ReplaceAll(filepath.ToSlash(a), "/", "\x00"), strings.ReplaceAll(filepath.ToSlash(b), "/", "\x00"))
+	})
+	var b strings.Builder
+	fmt.Fprintf(&b, "%6s %7s  %s\n", "SIZE", "FILES", "DIRECTORY")
+	for _, rel := range paths {
+		name := filepath.Base(root)
+		depth := 0
+		if rel != "." {
+			depth = strings.Count(filepath.ToSlash(rel), "/") + 1
+			name = filepath.Base(rel)
+		}
+		fmt.Fprintf(&b, "%6s %7d  %s%s\n", formatSize(dirs[rel].size), dirs[rel].files, strings.Repeat("  ", depth), name)
+	}
+	fmt.Fprintf(&b, "\nVault: %s in %d files\n", formatSize(total.size), total.files)
+	fmt.Fprintf(&b, "Attachments: %s in %d files\n", formatSize(attachments.size), attachments.files)
+	for _, warning := range sizeWarnings(total, attachments, config) {
+		b.WriteString("\nWarning: " + warning)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func runDiskUsage(app *App, args []string) error {
+	if len(args) > 0 {
+		return userErr{"Usage: disk-usage"}
+	}
+	showOutput("Disk usage: "+app.vaultTitle(), usageReport(app.rootItem.Path, app.config), app.screen)
+	return nil
+}
+
+type sizeEvent struct {
+	warning string
+}
+
+// Measure the vault in the background at startup and post the first limit
+// it's over to the screen
+func checkVaultSize(dir string, config Config, screen tcell.Screen) {
+	if config.VaultWarningMB == 0 && config.AttachmentsWarningMB == 0 {
+		return
+	}
+	go func() {
+		total, attachments, _ := vaultUsage(dir)
+		if warnings := sizeWarnings(total, attachments, config); len(warnings) > 0 {
+			_ = screen.PostEvent(tcell.NewEventInterrupt(sizeEvent{warnings[0]}))
+		}
+	}()
+}
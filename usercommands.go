@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+	"runtime"
+	"strings"
+)
+
+// UserCommand is a shell command from the config bound to a key in the tree,
+// e.g. {"key": "g", "command": "git -C {root} pull"}
+type UserCommand struct {
+	Key     string `json:"key"`
+	Command string `json:"command"`
+}
+
+func shellQuote(s string) string {
+	if runtime.GOOS == "windows" {
+		return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// Replace {path}, {dir} and {root} with the quoted selected path, the
+// directory holding it and the vault root
+func (app *App) expandUserCommand(command string) string {
+	return strings.NewReplacer(
+		"{path}", shellQuote(app.selectedItem().Path),
+		"{dir}", shellQuote(app.selectedDir()),
+		"{root}", shellQuote(app.rootItem.Path),
+	).Replace(command)
+}
+
+// Run a user command in the vault directory, show its output and re-scan the
+// tree since the command may have changed files
+func (app *App) runUserCommand(command string) error {
+	if app.config.ReadOnly {
+		return errReadOnly
+	}
+	expanded := app.expandUserCommand(command)
+	app.recordOperation("user-command", expanded)
+	width, height := app.screen.Size()
+	renderClearArea(0, height-1, width, height, app.screen)
+	renderText(0, height-1, "Running "+expanded, tcell.StyleDefault, app.screen)
+	app.screen.Show()
+
+	cmd := shellCommand(expanded)
+	cmd.Dir = app.rootItem.Path
+	output, err := cmd.CombinedOutput()
+	app.rebuild()
+	text := strings.TrimRight(string(output), "\n")
+	if err != nil {
+		text += fmt.Sprintf("\n\n%v", err)
+	}
+	if strings.TrimSpace(text) == "" {
+		text = "Done, no output"
+	}
+	showOutput(expanded, text, app.screen)
+	return nil
+}
+
+// Bind the user commands from the config to their keys in the tree, taking
+// precedence over the default keys
+func bindUserCommands(keymaps map[Focus]Keymap, commands []UserCommand) error {
+	for _, uc := range commands {
+		key, err := parseKey(uc.Key)
+		if err != nil {
+			return fmt.Errorf("error in user_commands: %v", err)
+		}
+		command := uc.Command
+		keymaps[FocusTree].bind(Action{command, func(app *App) error {
+			return app.runUserCommand(command)
+		}}, key)
+	}
+	return nil
+}
+
+// Show text in a box over the screen until Esc, q or Enter is pressed, with
+// the arrow and page keys scrolling it
+func showOutput(title string, text string, screen tcell.Screen) {
+	lines := strings.Split(strings.ReplaceAll(text, "\t", "    "), "\n")
+	scroll := 0
+	for {
+		width, height := screen.Size()
+		boxWidth, boxHeight := max(width-4, 10), max(height-4, 5)
+		x, y := (width-boxWidth)/2, (height-boxHeight)/2
+		rows := boxHeight - 2
+		scroll = max(min(scroll, len(lines)-rows), 0)
+
+		renderClearArea(x, y, x+boxWidth, y+boxHeight, screen)
+		border := tcell.StyleDefault.Dim(true)
+		for i := x; i < x+boxWidth; i++ {
+			screen.SetContent(i, y, '─', nil, border)
+			screen.SetContent(i, y+boxHeight-1, '─', nil, border)
+		}
+		renderText(x+1, y, " "+runewidth.Truncate(title, boxWidth-4, "…")+" ", tcell.StyleDefault.Bold(true), screen)
+		for i, line := range lines[scroll:] {
+			if i >= rows {
+				break
+			}
+			renderText(x+1, y+1+i, runewidth.Truncate(line, boxWidth-2, "…"), tcell.StyleDefault, screen)
+		}
+		hint := " Esc: Close "
+		if len(lines) > rows {
+			hint = fmt.Sprintf(" %d-%d/%d  ↑/↓: Scroll | Esc: Close ", scroll+1, min(scroll+rows, len(lines)), len(lines))
+		}
+		renderText(x+boxWidth-1-runewidth.StringWidth(hint), y+boxHeight-1, hint, border, screen)
+		screen.Show()
+
+		switch ev := screen.PollEvent().(type) {
+		case *tcell.EventResize:
+			screen.Sync()
+		case *tcell.EventKey:
+			switch {
+			case ev.Key() == tcell.KeyUp || ev.Rune() == 'k':
+				scroll--
+			case ev.Key() == tcell.KeyDown || ev.Rune() == 'j':
+				scroll++
+			case ev.Key() == tcell.KeyPgUp:
+				scroll -= rows
+			case ev.Key() == tcell.KeyPgDn || ev.Rune() == ' ':
+				scroll += rows
+			case ev.Key() == tcell.KeyEscape, ev.Key() == tcell.KeyEnter, ev.Rune() == 'q':
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"github.com/gdamore/tcell/v2"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// The check file holds known text encrypted with the vault passphrase, so a
+// wrong passphrase is caught at unlock rather than on the first note
+const (
+	lockCheckFileName = ".notes-lock" + gpgExt
+	lockCheckText     = "notes vault"
+	maxUnlockAttempts = 3
+)
+
+type lockEvent struct{}
+
+// Whether path is a note of the passphrase protected directory
+func inLockedDir(path string) bool {
+	if encryption.lockedDir == "" {
+		return false
+	}
+	rel, err := filepath.Rel(encryption.lockedDir, path)
+	return err == nil && !strings.HasPrefix(rel, "..") && strings.ToLower(filepath.Ext(path)) == gpgExt
+}
+
+// Run gpg with the vault passphrase handed over on file descriptor 3, so it
+// never appears in the process list or the environment. The passphrase is
+// written while gpg runs, as a long one wouldn't fit the pipe buffer.
+func runGPGWithPassphrase(input []byte, args ...string) ([]byte, error) {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("error creating passphrase pipe: %v", err)
+	}
+	// Closing the read end also stops the writer if gpg never reads it
+	defer reader.Close()
+	passphrase := encryption.passphrase
+	go func() {
+		_, _ = writer.Write(passphrase)
+		writer.Close()
+	}()
+	args = append([]string{"--batch", "--quiet", "--pinentry-mode", "loopback", "--passphrase-fd", "3"}, args...)
+	cmd := exec.Command("gpg", args...)
+	cmd.ExtraFiles = []*os.File{reader}
+	return runCrypt(cmd, input)
+}
+
+// Ask for the vault passphrase, creating the check file with a newly chosen
+// one the first time. Returns false when the user gives up. In read-only mode
+// no passphrase is chosen, the directory stays without one.
+func (app *App) unlockVault() bool {
+	checkPath := filepath.Join(encryption.lockedDir, lockCheckFileName)
+	if !isFile(checkPath) && app.config.ReadOnly {
+		renderMessage("Read-only mode, the vault passphrase can't be set", app.screen)
+		app.vaultLocked = false
+		return true
+	}
+	if !isFile(checkPath) {
+		passphrase, ok := getPassword("New vault passphrase: ", app.screen)
+		if !ok || passphrase == "" {
+			return false
+		}
+		confirmation, ok := getPassword("Repeat the passphrase: ", app.screen)
+		if !ok || confirmation != passphrase {
+			renderMessage("The passphrases don't match", app.screen)
+			return false
+		}
+		encryption.passphrase = []byte(passphrase)
+		if err := os.MkdirAll(encryption.lockedDir, os.ModePerm); err != nil {
+			handleError(fmt.Errorf("error creating directory %s: %v", encryption.lockedDir, err), app.screen)
+			return false
+		}
+		if err := encryptFile(checkPath, []byte(lockCheckText)); err != nil {
+			handleError(err, app.screen)
+			return false
+		}
+		app.vaultLocked = false
+		return true
+	}
+
+	for attempt := 0; attempt < maxUnlockAttempts; attempt++ {
+		passphrase, ok := getPassword("Vault passphrase: ", app.screen)
+		if !ok {
+			return false
+		}
+		encryption.passphrase = []byte(passphrase)
+		if content, err := decryptFile(checkPath); err == nil && string(content) == lockCheckText {
+			app.vaultLocked = false
+			return true
+		}
+		encryption.passphrase = nil
+		logger.Printf("wrong vault passphrase")
+	}
+	renderMessage("Wrong passphrase", app.screen)
+	return false
+}
+
+// Forget the passphrase and every decrypted note until the vault is unlocked
+func (app *App) lockVault() {
+	for i := range encryption.passphrase {
+		encryption.passphrase[i] = 0
+	}
+	encryption.passphrase = nil
+	for path := range decryptedNotes {
+		delete(decryptedNotes, path)
+	}
+	app.vaultLocked = true
+	// Views may show decrypted content
+	app.reader = nil
+	app.outline = nil
+	app.search = nil
+	app.board = nil
+	app.setFocus(FocusTree)
+}
+
+func renderLocked(area Rect, screen tcell.Screen) {
+	renderClearArea(area.X, area.Y, area.X+area.Width, area.Y+area.Height, screen)
+	renderText(area.X, area.Y, "Vault locked, press any key to unlock", tcell.StyleDefault.Dim(true), screen)
+}
+
+// Restart the countdown to locking the vault. Like the idle timer it fires
+// once and posts an interrupt to the current screen.
+func (app *App) resetLockTimer() {
+	if app.lockTimer != nil {
+		app.lockTimer.Stop()
+	}
+	if encryption.lockedDir == "" || app.config.LockTimeout <= 0 {
+		return
+	}
+	screen := app.screen
+	app.lockTimer = time.AfterFunc(time.Duration(app.config.LockTimeout)*time.Second, func() {
+		_ = screen.PostEvent(tcell.NewEventInterrupt(lockEvent{}))
+	})
+}
+
+func (app *App) lockElapsed() bool {
+	timeout := time.Duration(app.config.LockTimeout) * time.Second
+	return timeout > 0 && time.Since(app.lastInput) >= timeout
+}
+
+// Encrypt plain files that were created in the locked directory, e.g. new
+// notes once the editor closes, and remove the plain copies
+func (app *App) sealLockedDir() error {
+	if encryption.lockedDir == "" || encryption.passphrase == nil || app.config.ReadOnly {
+		return nil
+	}
+	return walkNotes(encryption.lockedDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if !d.Type().IsRegular() || isFile(path+gpgExt) {
+			return nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("error reading %s: %v", path, err)
+		}
+		if err := encryptFile(path+gpgExt, content); err != nil {
+			return err
+		}
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("error removing %s: %v", path, err)
+		}
+		app.recordOperation("seal", path)
+		return nil
+	})
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// Vault is a named notes directory, or a WebDAV folder, that can be switched
+// to at runtime
+type Vault struct {
+	Name            string `json:"name"`
+	Path            string `json:"path"`
+	URL             string `json:"url"`
+	User            string `json:"user"`
+	PasswordCommand string `json:"password_command"`
+}
+
+func validateVaults(vaults []Vault) error {
+	names := make(map[string]bool)
+	for _, vault := range vaults {
+		if vault.Name == "" || (vault.Path == "") == (vault.URL == "") {
+			return fmt.Errorf("error: vaults need a name and either a path or a url")
+		}
+		if names[vault.Name] {
+			return fmt.Errorf("error: vault %s is defined twice", vault.Name)
+		}
+		names[vault.Name] = true
+	}
+	return nil
+}
+
+func findVault(vaults []Vault, name string) (Vault, bool) {
+	for _, vault := range vaults {
+		if vault.Name == name {
+			return vault, true
+		}
+	}
+	return Vault{}, false
+}
+
+// The directory to open for a vault, for a WebDAV vault its local mirror
+func openVault(vault Vault) (string, *RemoteVault, error) {
+	if vault.URL == "" {
+		return expandHome(vault.Path), nil, nil
+	}
+	remote, err := openRemoteVault(vault)
+	if err != nil {
+		return "", nil, err
+	}
+	return remote.dir, remote, nil
+}
+
+// Pick one of the configured vaults in an overlay and switch to it
+func (app *App) pickVault() error {
+	if len(app.config.Vaults) == 0 {
+		return userErr{"No vaults in the config"}
+	}
+	names := make([]string, len(app.config.Vaults))
+	for i, vault := range app.config.Vaults {
+		names[i] = vault.Name
+	}
+	name, ok := pickFromList("Switch vault", "vaults", names, false, app.screen)
+	if !ok {
+		return nil
+	}
+	return app.switchVault(name)
+}
+
+// Replace the open vault with a configured one, dropping what belongs to the
+// old vault: filters, marks, undo history, the index and, for a passphrase
+// protected directory, the passphrase
+func (app *App) switchVault(name string) error {
+	vault, ok := findVault(app.config.Vaults, name)
+	if !ok {
+		return userErr{fmt.Sprintf("Unknown vault: %s", name)}
+	}
+	dir, remote, err := openVault(vault)
+	if err != nil {
+		return err
+	}
+	if !isDir(dir) {
+		return userErr{fmt.Sprintf("Not a directory: %s", dir)}
+	}
+	vaultConfig, err := loadVaultConfig(dir)
+	if err != nil {
+		return err
+	}
+	if err := app.sealLockedDir(); err != nil {
+		return err
+	}
+	if encryption.lockedDir != "" {
+		app.lockVault()
+		app.vaultLocked = false
+		encryption.lockedDir = ""
+	}
+
+	app.recordOperation("switch-vault", dir)
+	app.dir, app.vaultName = dir, vault.Name
+	// A sync still running for the old vault is ignored when it finishes
+	app.remote, app.remoteSyncing, app.remotePending = remote, false, false
+	app.fieldFilters = vaultConfig.fieldFilters()
+	app.sortField, app.sortDescending = vaultConfig.SortField, vaultConfig.SortDescending
+	app.labelFilter, app.tagFilter = "", ""
+	app.marked = make(map[string]bool)
+	app.undoStack, app.redoStack = nil, nil
+	// A refresh still running for the old vault hands its index to the old channel
+	app.index = loadIndex(dir)
+	app.indexDone = make(chan *Index, 1)
+	app.indexing, app.indexPending, app.indexProgress = false, false, nil
+	app.currentSelection, app.treeOffset, app.treeScroll, app.previewScroll = 0, 0, 0, 0
+	app.reader, app.outline, app.search, app.board, app.backups, app.revisions = nil, nil, nil, nil, nil, nil
+	app.setFocus(FocusTree)
+
+	if app.config.LockedDir != "" {
+		encryption.lockedDir = filepath.Join(dir, app.config.LockedDir)
+		app.vaultLocked = true
+	}
+	app.rebuild()
+	app.toast = "Switched to " + vault.Name
+	return nil
+}
+
+// Switch to the vault given, or pick one when no name is given
+func runVault(app *App, args []string) error {
+	if len(args) == 0 {
+		return app.pickVault()
+	}
+	return app.switchVault(args[0])
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+const watchInterval = time.Second
+
+// Modification times of the notes and other files of a collected site
+func siteModTimes(site *publishSite) map[string]time.Time {
+	times := make(map[string]time.Time)
+	for _, rel := range append(slices.Clone(site.notes), site.files...) {
+		if info, err := os.Stat(filepath.Join(site.root, filepath.FromSlash(rel))); err == nil {
+			times[rel] = info.ModTime()
+		}
+	}
+	return times
+}
+
+func sameKeys(a map[string]bool, b map[string]bool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key := range a {
+		if !b[key] {
+			return false
+		}
+	}
+	return true
+}
+
+// Export the changes between two collections of the vault. Saved notes and
+// files are exported on their own; added or removed notes and directories
+// change listings and links, so the whole vault is exported again.
+func exportChanges(previous *publishSite, current *publishSite, before map[string]time.Time, after map[string]time.Time) error {
+	for rel := range before {
+		if _, ok := after[rel]; ok {
+			continue
+		}
+		dest := rel
+		if slices.Contains(previous.notes, rel) {
+			dest = htmlPath(rel)
+		}
+		if err := os.Remove(filepath.Join(current.out, filepath.FromSlash(dest))); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("error removing %s: %v", dest, err)
+		}
+		fmt.Printf("%s Removed %s\n", time.Now().Format(time.TimeOnly), dest)
+	}
+	if !slices.Equal(previous.notes, current.notes) || !sameKeys(previous.dirs, current.dirs) {
+		count, err := publishVault(current.root, current.out)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s Exported %d notes\n", time.Now().Format(time.TimeOnly), count)
+		return nil
+	}
+	for rel, modTime := range after {
+		if old, ok := before[rel]; ok && old.Equal(modTime) {
+			continue
+		}
+		var err error
+		if slices.Contains(current.notes, rel) {
+			_, err = current.publishNote(rel)
+		} else {
+			err = copyFile(filepath.Join(current.root, filepath.FromSlash(rel)), filepath.Join(current.out, filepath.FromSlash(rel)))
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s Exported %s\n", time.Now().Format(time.TimeOnly), rel)
+	}
+	return nil
+}
+
+// Export the vault to out and keep exporting what changes until interrupted
+func watchExport(root string, out string) error {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return fmt.Errorf("error resolving %s: %v", root, err)
+	}
+	out, err = filepath.Abs(out)
+	if err != nil {
+		return fmt.Errorf("error resolving %s: %v", out, err)
+	}
+	count, err := publishVault(root, out)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Exported %d notes to %s, watching for changes (Ctrl+C to stop)\n", count, out)
+
+	site := &publishSite{root: root, out: out}
+	if err := site.collect(); err != nil {
+		return err
+	}
+	times := siteModTimes(site)
+	for range time.Tick(watchInterval) {
+		current := &publishSite{root: root, out: out}
+		if err := current.collect(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			continue
+		}
+		currentTimes := siteModTimes(current)
+		// Failed exports are retried on the next change
+		if err := exportChanges(site, current, times, currentTimes); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			continue
+		}
+		site, times = current, currentTimes
+	}
+	return nil
+}
+
+func runWatchSubcommand(args []string) error {
+	flags := flag.NewFlagSet("watch", flag.ContinueOnError)
+	d := flags.String("d", "", "Path to directory with notes")
+	exportHTML := flags.String("export-html", "", "Directory to export the notes to as HTML")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *d == "" || *exportHTML == "" || flags.NArg() != 0 {
+		return fmt.Errorf("usage: notes watch -d dir --export-html <outdir>")
+	}
+	return watchExport(*d, *exportHTML)
+}
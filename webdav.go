@@ -0,0 +1,530 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"github.com/gdamore/tcell/v2"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Directories of the vault kept on this machine only
+var localOnlyDirs = []string{backupsDirName, revisionsDirName}
+
+const webdavTimeout = 30 * time.Second
+
+// webdavClient talks to a WebDAV collection, e.g. a Nextcloud folder, with
+// paths relative to it
+type webdavClient struct {
+	base     *url.URL
+	user     string
+	password string
+	http     *http.Client
+}
+
+type davMultistatus struct {
+	Responses []struct {
+		Href     string `xml:"href"`
+		Propstat []struct {
+			Prop struct {
+				ResourceType struct {
+					Collection *struct{} `xml:"collection"`
+				} `xml:"resourcetype"`
+				ETag string `xml:"getetag"`
+			} `xml:"prop"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?><d:propfind xmlns:d="DAV:"><d:prop><d:resourcetype/><d:getetag/></d:prop></d:propfind>`
+
+func newWebdavClient(rawURL string, user string, password string) (*webdavClient, error) {
+	base, err := url.Parse(rawURL)
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") {
+		return nil, fmt.Errorf("error: invalid WebDAV url %s", rawURL)
+	}
+	base.Path = strings.TrimSuffix(base.Path, "/") + "/"
+	return &webdavClient{base, user, password, &http.Client{Timeout: webdavTimeout}}, nil
+}
+
+func (c *webdavClient) url(rel string, collection bool) string {
+	u := c.base.JoinPath(strings.Split(rel, "/")...)
+	if collection && !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+	return u.String()
+}
+
+func (c *webdavClient) do(method string, rel string, collection bool, body []byte, headers map[string]string) (*http.Response, error) {
+	req, err := http.NewRequest(method, c.url(rel, collection), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("error creating WebDAV request: %v", err)
+	}
+	if c.user != "" {
+		req.SetBasicAuth(c.user, c.password)
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error: WebDAV %s %s: %v", method, rel, err)
+	}
+	if resp.StatusCode >= 300 {
+		resp.Body.Close()
+		return resp, fmt.Errorf("error: WebDAV %s %s: %s", method, rel, resp.Status)
+	}
+	return resp, nil
+}
+
+// The files below the collection with their ETags, listed one directory at a
+// time since servers often refuse infinite depth
+func (c *webdavClient) list() (map[string]string, error) {
+	files := make(map[string]string)
+	pending := []string{""}
+	for len(pending) > 0 {
+		dir := pending[0]
+		pending = pending[1:]
+		resp, err := c.do("PROPFIND", dir, true, []byte(propfindBody), map[string]string{"Depth": "1", "Content-Type": "application/xml"})
+		if err != nil {
+			return nil, err
+		}
+		var status davMultistatus
+		err = xml.NewDecoder(resp.Body).Decode(&status)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error parsing WebDAV listing of %s: %v", dir, err)
+		}
+		for _, r := range status.Responses {
+			rel, ok := c.relative(r.Href)
+			if !ok || rel == dir || slices.Contains(localOnlyDirs, strings.Split(rel, "/")[0]) {
+				continue
+			}
+			collection, etag := false, ""
+			for _, propstat := range r.Propstat {
+				collection = collection || propstat.Prop.ResourceType.Collection != nil
+				etag += propstat.Prop.ETag
+			}
+			if collection {
+				pending = append(pending, rel)
+			} else {
+				files[rel] = etag
+			}
+		}
+	}
+	return files, nil
+}
+
+// Path of a listed href relative to the collection. Hrefs outside of it,
+// e.g. with .. segments, and the collection itself aren't relative paths.
+func (c *webdavClient) relative(href string) (string, bool) {
+	u, err := url.Parse(href)
+	if err != nil {
+		return "", false
+	}
+	rel, ok := strings.CutPrefix(u.Path, c.base.Path)
+	if !ok {
+		return "", false
+	}
+	rel = path.Clean(strings.Trim(rel, "/"))
+	if rel == "." || path.IsAbs(rel) || rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", false
+	}
+	return rel, true
+}
+
+func (c *webdavClient) get(rel string) ([]byte, error) {
+	resp, err := c.do("GET", rel, false, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error: WebDAV GET %s: %v", rel, err)
+	}
+	return content, nil
+}
+
+// Upload a file, creating its parent collections, returning its new ETag
+// when the server gives one
+func (c *webdavClient) put(rel string, content []byte, created map[string]bool) (string, error) {
+	parts := strings.Split(rel, "/")
+	for i := 1; i < len(parts); i++ {
+		dir := strings.Join(parts[:i], "/")
+		if created[dir] {
+			continue
+		}
+		// Collections that already exist answer 405
+		if resp, err := c.do("MKCOL", dir, true, nil, nil); err != nil && (resp == nil || resp.StatusCode != http.StatusMethodNotAllowed) {
+			return "", err
+		}
+		created[dir] = true
+	}
+	resp, err := c.do("PUT", rel, false, content, nil)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	return resp.Header.Get("ETag"), nil
+}
+
+func (c *webdavClient) move(from string, to string) error {
+	resp, err := c.do("MOVE", from, false, nil, map[string]string{"Destination": c.url(to, false), "Overwrite": "F"})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (c *webdavClient) delete(rel string) error {
+	resp, err := c.do("DELETE", rel, false, nil, nil)
+	if err != nil && (resp == nil || resp.StatusCode != http.StatusNotFound) {
+		return err
+	}
+	if err == nil {
+		resp.Body.Close()
+	}
+	return nil
+}
+
+// syncedFile is the state of a file after the last sync, to tell which side
+// changed it since
+type syncedFile struct {
+	ETag string `json:"etag"`
+	Hash string `json:"hash"`
+}
+
+// RemoteVault is a vault on a WebDAV server worked on in a local mirror,
+// synced both ways
+type RemoteVault struct {
+	client   *webdavClient
+	dir      string
+	manifest string
+}
+
+// The mirror directory and last sync state of a WebDAV vault in the user
+// cache directory, keyed by its url like the index
+func openRemoteVault(vault Vault) (*RemoteVault, error) {
+	password := ""
+	if vault.PasswordCommand != "" {
+		output, err := shellCommand(vault.PasswordCommand).Output()
+		if err != nil {
+			return nil, fmt.Errorf("error running password_command of vault %s: %v", vault.Name, err)
+		}
+		password = strings.TrimRight(string(output), "\r\n")
+	}
+	client, err := newWebdavClient(vault.URL, vault.User, password)
+	if err != nil {
+		return nil, err
+	}
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return nil, fmt.Errorf("error finding the cache directory: %v", err)
+	}
+	sum := sha1.Sum([]byte(vault.URL))
+	base := filepath.Join(cacheDir, "notes", fmt.Sprintf("webdav-%x", sum[:6]))
+	if err := os.MkdirAll(base, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("error creating directory %s: %v", base, err)
+	}
+	return &RemoteVault{client, base, base + ".json"}, nil
+}
+
+func hashContent(content []byte) string {
+	sum := sha1.Sum(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// Hashes of the files of the mirror by their slash separated relative paths
+func (r *RemoteVault) localFiles() (map[string]string, error) {
+	files := make(map[string]string)
+	err := filepath.WalkDir(r.dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(r.dir, p)
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			if slices.Contains(localOnlyDirs, rel) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		files[rel] = hashContent(content)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", r.dir, err)
+	}
+	return files, nil
+}
+
+func (r *RemoteVault) loadManifest() map[string]syncedFile {
+	manifest := make(map[string]syncedFile)
+	if content, err := os.ReadFile(r.manifest); err == nil {
+		_ = json.Unmarshal(content, &manifest)
+	}
+	return manifest
+}
+
+func (r *RemoteVault) saveManifest(manifest map[string]syncedFile) error {
+	content, err := json.Marshal(manifest)
+	if err != nil {
+		return fmt.Errorf("error encoding %s: %v", r.manifest, err)
+	}
+	if err := os.WriteFile(r.manifest, content, 0o600); err != nil {
+		return fmt.Errorf("error writing %s: %v", r.manifest, err)
+	}
+	return nil
+}
+
+func (r *RemoteVault) download(rel string) (string, error) {
+	local := filepath.Join(r.dir, filepath.FromSlash(rel))
+	if inside, err := filepath.Rel(r.dir, local); err != nil || inside == ".." || strings.HasPrefix(inside, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("error: WebDAV path %s is outside of the vault", rel)
+	}
+	content, err := r.client.get(rel)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(local), os.ModePerm); err != nil {
+		return "", fmt.Errorf("error creating directory %s: %v", filepath.Dir(local), err)
+	}
+	if err := os.WriteFile(local, content, 0o644); err != nil {
+		return "", fmt.Errorf("error writing %s: %v", local, err)
+	}
+	return hashContent(content), nil
+}
+
+// Whether the server's copy of a file differs from the content it had at the
+// last sync, for servers that send no ETags
+func (r *RemoteVault) remoteDiffers(rel string, hash string) (bool, error) {
+	content, err := r.client.get(rel)
+	if err != nil {
+		return false, err
+	}
+	return hashContent(content) != hash, nil
+}
+
+func (r *RemoteVault) upload(rel string, created map[string]bool) (string, error) {
+	content, err := os.ReadFile(filepath.Join(r.dir, filepath.FromSlash(rel)))
+	if err != nil {
+		return "", fmt.Errorf("error reading %s: %v", rel, err)
+	}
+	return r.client.put(rel, content, created)
+}
+
+// Bring the mirror and the server in line: changes made on one side since
+// the last sync are copied to the other, renames in the mirror become MOVEs,
+// and a file changed on both sides keeps the mirror's version as a conflict
+// copy next to the server's. Returns whether files of the mirror changed.
+func (r *RemoteVault) sync() (bool, error) {
+	manifest := r.loadManifest()
+	remote, err := r.client.list()
+	if err != nil {
+		return false, err
+	}
+	local, err := r.localFiles()
+	if err != nil {
+		return false, err
+	}
+
+	// Renames in the mirror, a file gone that reappears under a new name
+	for rel, synced := range manifest {
+		if _, ok := local[rel]; ok || remote[rel] != synced.ETag {
+			continue
+		}
+		for newRel, hash := range local {
+			_, known := manifest[newRel]
+			_, onServer := remote[newRel]
+			if known || onServer || hash != synced.Hash {
+				continue
+			}
+			if err := r.client.move(rel, newRel); err != nil {
+				break
+			}
+			manifest[newRel], remote[newRel] = synced, synced.ETag
+			delete(manifest, rel)
+			delete(remote, rel)
+			break
+		}
+	}
+
+	paths := make(map[string]bool)
+	for _, files := range []map[string]string{remote, local} {
+		for rel := range files {
+			paths[rel] = true
+		}
+	}
+	for rel := range manifest {
+		paths[rel] = true
+	}
+	sorted := make([]string, 0, len(paths))
+	for rel := range paths {
+		sorted = append(sorted, rel)
+	}
+	sort.Strings(sorted)
+
+	next := make(map[string]syncedFile)
+	created := make(map[string]bool)
+	changed := false
+	var syncErr error
+	for _, rel := range sorted {
+		synced, inManifest := manifest[rel]
+		etag, onServer := remote[rel]
+		hash, inMirror := local[rel]
+		localChanged := inMirror && (!inManifest || hash != synced.Hash)
+		remoteChanged := onServer && (!inManifest || etag != synced.ETag)
+		var err error
+		if onServer && inManifest && etag == "" {
+			// Without ETags only the content tells whether the server's copy
+			// changed since the last sync
+			remoteChanged, err = r.remoteDiffers(rel, synced.Hash)
+		}
+		if err == nil && onServer && inMirror && inManifest && !localChanged && !remoteChanged {
+			next[rel] = synced
+			continue
+		}
+
+		switch {
+		case err != nil:
+			// Checking the server's copy failed, handled below
+		case localChanged && remoteChanged:
+			var remoteHash string
+			content, readErr := os.ReadFile(filepath.Join(r.dir, filepath.FromSlash(rel)))
+			if readErr != nil {
+				err = readErr
+				break
+			}
+			if remoteHash, err = r.download(rel); err != nil {
+				break
+			}
+			changed = true
+			next[rel] = syncedFile{etag, remoteHash}
+			if remoteHash == hash {
+				break
+			}
+			copyPath := conflictPath(filepath.Join(r.dir, filepath.FromSlash(rel)))
+			if err = os.WriteFile(copyPath, content, 0o644); err != nil {
+				break
+			}
+			copyRel, _ := filepath.Rel(r.dir, copyPath)
+			copyRel = filepath.ToSlash(copyRel)
+			var copyETag string
+			if copyETag, err = r.client.put(copyRel, content, created); err == nil {
+				next[copyRel] = syncedFile{copyETag, hash}
+			}
+		case remoteChanged:
+			var newHash string
+			if newHash, err = r.download(rel); err == nil {
+				next[rel] = syncedFile{etag, newHash}
+				changed = true
+			}
+		case localChanged:
+			var newETag string
+			if newETag, err = r.upload(rel, created); err == nil {
+				next[rel] = syncedFile{newETag, hash}
+			}
+		case inManifest && !inMirror && onServer:
+			err = r.client.delete(rel)
+		case inManifest && inMirror && !onServer:
+			err = os.Remove(filepath.Join(r.dir, filepath.FromSlash(rel)))
+			changed = true
+		}
+		if err != nil {
+			logger.Printf("%v", err)
+			syncErr = err
+			// Keep what was known so the file is tried again next time
+			if inManifest {
+				next[rel] = synced
+			}
+		}
+	}
+	if err := r.saveManifest(next); err != nil {
+		return changed, err
+	}
+	return changed, syncErr
+}
+
+// Handed back through remoteDone when a background sync finished
+type remoteEvent struct {
+	remote  *RemoteVault
+	changed bool
+	err     error
+}
+
+// Sync the WebDAV vault in the background, again once it's done when files
+// changed meanwhile
+func (app *App) syncRemote() {
+	// Syncing uploads and deletes on the server
+	if app.remote == nil || app.config.ReadOnly {
+		return
+	}
+	if app.remoteSyncing {
+		app.remotePending = true
+		return
+	}
+	app.remoteSyncing = true
+	remote, screen, done := app.remote, app.screen, app.remoteDone
+	go func() {
+		changed, err := remote.sync()
+		done <- remoteEvent{remote, changed, err}
+		_ = screen.PostEvent(tcell.NewEventInterrupt(wakeEvent{}))
+	}()
+}
+
+// Pick up a finished sync, syncing again when files changed meanwhile
+func (app *App) collectRemoteSync() {
+	var event remoteEvent
+	select {
+	case event = <-app.remoteDone:
+	default:
+		return
+	}
+	// A sync of the vault open before switching vaults
+	if event.remote != app.remote {
+		return
+	}
+	app.remoteSyncing = false
+	if event.err != nil {
+		app.toast = fmt.Sprintf("WebDAV sync failed: %v", event.err)
+	}
+	if event.changed {
+		app.rebuildTree()
+	}
+	if app.remotePending {
+		app.remotePending = false
+		app.syncRemote()
+	}
+}
+
+// Sync the WebDAV vault now
+func runSync(app *App, args []string) error {
+	if app.remote == nil {
+		return userErr{"The vault isn't on a WebDAV server"}
+	}
+	if app.config.ReadOnly {
+		return errReadOnly
+	}
+	app.syncRemote()
+	app.toast = "Syncing " + app.vaultTitle()
+	return nil
+}